@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/leocomelli/ght/pkg/ght"
+)
+
+// Exit codes of the ght process
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitConfig  = 2
+	ExitAuth    = 3
+	ExitPartial = 4
+	ExitDrift   = 5
+)
+
+// ErrDriftDetected is returned in plan mode when the repository differs from the template
+var ErrDriftDetected = errors.New("drift detected, the repository differs from the template")
+
+// ExitError is an error that carries the exit code of the process
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code for the error
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return ExitFailure
+}
+
+// RunError classifies the outcome of Run into an error with the matching exit code
+func RunError(res *ght.RepoResponse, err error, opts *ght.RepoOptions) error {
+	switch {
+	case err == nil && opts.Plan && res != nil && res.Changed():
+		return &ExitError{Code: ExitDrift, Err: ErrDriftDetected}
+	case err == nil:
+		return nil
+	case ght.IsAuthError(err):
+		return &ExitError{Code: ExitAuth, Err: err}
+	case res == nil || ght.IsConfigError(err):
+		// Run only returns no response when the template can not be loaded
+		return &ExitError{Code: ExitConfig, Err: err}
+	case !opts.Plan && res.Changed():
+		return &ExitError{Code: ExitPartial, Err: err}
+	default:
+		return &ExitError{Code: ExitFailure, Err: err}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght"
+	"github.com/stretchr/testify/assert"
+)
+
+func apiError(status int) error {
+	return fmt.Errorf("failed |→ %w", &github.ErrorResponse{Response: &http.Response{StatusCode: status}})
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, ExitOK, ExitCode(nil))
+	assert.Equal(t, ExitFailure, ExitCode(errors.New("unknown")))
+	assert.Equal(t, ExitAuth, ExitCode(fmt.Errorf("wrapped |→ %w", &ExitError{Code: ExitAuth, Err: errors.New("auth")})))
+}
+
+func TestRunErrorSuccess(t *testing.T) {
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionCreated}}}
+	assert.Nil(t, RunError(res, nil, &ght.RepoOptions{}))
+}
+
+func TestRunErrorDrift(t *testing.T) {
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionUnchanged}, {Action: ght.ActionUpdated}}}
+	assert.Equal(t, ExitDrift, ExitCode(RunError(res, nil, &ght.RepoOptions{Plan: true})))
+
+	res = &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionUnchanged}}}
+	assert.Nil(t, RunError(res, nil, &ght.RepoOptions{Plan: true}))
+}
+
+func TestRunErrorConfig(t *testing.T) {
+	_, err := ght.Run(nil, &ght.RepoOptions{Template: "./pkg/ght/testdata/invalid-syntax.json"})
+	assert.Equal(t, ExitConfig, ExitCode(RunError(nil, err, &ght.RepoOptions{})))
+
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionFailed}}}
+	assert.Equal(t, ExitConfig, ExitCode(RunError(res, ght.ErrRepoConfigNotFound, &ght.RepoOptions{})))
+}
+
+func TestRunErrorAuth(t *testing.T) {
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionFailed}}}
+	assert.Equal(t, ExitAuth, ExitCode(RunError(res, apiError(http.StatusUnauthorized), &ght.RepoOptions{})))
+	assert.Equal(t, ExitAuth, ExitCode(RunError(res, errors.Join(apiError(http.StatusBadRequest), apiError(http.StatusForbidden)), &ght.RepoOptions{})))
+}
+
+func TestRunErrorPartial(t *testing.T) {
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionUpdated}, {Action: ght.ActionFailed}}}
+	assert.Equal(t, ExitPartial, ExitCode(RunError(res, apiError(http.StatusBadRequest), &ght.RepoOptions{})))
+}
+
+func TestRunErrorFailure(t *testing.T) {
+	res := &ght.RepoResponse{Steps: []ght.StepResult{{Action: ght.ActionUnchanged}, {Action: ght.ActionFailed}}}
+	assert.Equal(t, ExitFailure, ExitCode(RunError(res, apiError(http.StatusBadRequest), &ght.RepoOptions{})))
+}
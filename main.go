@@ -1,37 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-// RepoOptions is the options for creating a new repository
-type RepoOptions struct {
-	Name        string
-	Owner       string
-	Description string
-	Topics      []string
-	Branches    []string
-	Template    string
-	Debug       bool
-}
-
-// Config is the configuration for the repository
-type Config struct {
-	Repository            *github.Repository          `json:"repository"`
-	BranchProtection      *github.ProtectionRequest   `json:"branch_protection"`
-	TemplateRepo          *github.TemplateRepoRequest `json:"template_repo"`
-	RequiredSignedCommits bool                        `json:"required_signed_commits"`
-	PullRequestTemplate   string                      `json:"pull_request_template"`
-	IssueTemplate         string                      `json:"issue_template"`
-}
-
 const tmpl = `
 Version: %s
 BuildDate: %s
@@ -47,7 +32,7 @@ var (
 	GitHash = ""
 
 	logger zerolog.Logger
-	opts   *RepoOptions
+	opts   *ght.RepoOptions
 )
 
 func main() {
@@ -55,39 +40,75 @@ func main() {
 
 	if err := cmd.Execute(); err != nil {
 		log.Error().Err(err).Msg("error executing command")
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
 
 func command() *cobra.Command {
-	opts = &RepoOptions{}
+	opts = &ght.RepoOptions{}
 
 	root := &cobra.Command{
 		Use:   "ght",
 		Short: "ght is a CLI tool for creating a new repository based on the template",
+		// errors are logged by main along with the exit code
+		SilenceErrors: true,
 	}
 
 	repo := &cobra.Command{
 		Use:     "repo",
 		Aliases: []string{"r", "repository"},
 		Short:   "Create a new repository based on the template",
+		// the workflow inputs must be read before the required flags are checked
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if ght.InGitHubActions() {
+				return actionInputs(cmd)
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			debugMode(opts)
+			cmd.SilenceUsage = true
 
-			var (
-				rt  *RepoTemplate
-				err error
-			)
+			if err := ght.ValidateOutput(opts.Output); err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
 
-			if rt, err = NewRepoTemplate(); err != nil {
-				return err
+			cassette, err := ght.NewCassette(opts)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
 			}
 
-			if _, err := Run(rt, opts); err != nil {
-				logger.Error().Err(err).Msg("")
+			fetcher, err := ght.NewFetcher(opts, ght.WithLogger(logger), ght.WithCassette(cassette))
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
 			}
 
-			return nil
+			audit, err := auditLog(opts.AuditLog)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+			defer audit.Close()
+
+			rt, err := ght.NewRepoTemplate(ght.WithLogger(logger), ght.WithFetcher(fetcher), ght.WithCassette(cassette), ght.WithAuditLog(audit))
+			if err != nil {
+				return &ExitError{Code: ExitAuth, Err: err}
+			}
+
+			res, err := ght.Run(rt, opts)
+			err = withAuditError(err, audit)
+			if res != nil {
+				if err := ght.WriteResult(os.Stdout, res, opts.Output); err != nil {
+					return err
+				}
+			}
+
+			if ght.InGitHubActions() {
+				if err := ght.WriteActionReport(os.Stdout, res, err, opts); err != nil {
+					return err
+				}
+			}
+
+			return RunError(res, err, opts)
 		},
 	}
 
@@ -98,11 +119,203 @@ func command() *cobra.Command {
 	repo.Flags().StringSliceVarP(&opts.Branches, "branches", "b", []string{}, "the names of the branches to which the protection rules will be applied")
 	repo.Flags().StringVarP(&opts.Template, "template", "t", "", "the name of the JSON file contains the template, can be a local or remote file")
 	repo.Flags().BoolVarP(&opts.Debug, "debug", "v", false, "enable debug mode")
+	repo.Flags().StringVar(&opts.Output, "output", ght.OutputTable, "the format of the run results: table, json or yaml")
+	repo.Flags().BoolVar(&opts.Plan, "plan", false, "report the changes without applying them, exits with code 5 when there are pending changes")
+	repo.Flags().BoolVar(&opts.ContinueOnError, "continue-on-error", false, "keep applying the remaining sections when one fails, reporting all the failures")
+	repo.Flags().StringVar(&opts.Lockfile, "lockfile", "", "the lockfile with the digests the remote files must match")
+	repo.Flags().StringVar(&opts.Record, "record", "", "record the sanitized HTTP interactions of the run into the directory")
+	repo.Flags().StringVar(&opts.Replay, "replay", "", "replay the HTTP interactions recorded in the directory instead of reaching the network")
+	repo.Flags().StringVar(&opts.AuditLog, "audit-log", "", auditLogUsage)
+	repo.Flags().StringToStringVar(&opts.Vars, "var", nil, "a value of the ${name} variables of the template, such as --var project=ACME, can be repeated")
+	repo.Flags().BoolVar(&opts.AllowRemoteSecretSources, "allow-remote-secret-sources", false, allowRemoteSecretSourcesUsage)
+	repo.Flags().StringVar(&opts.Snapshot, "snapshot", "", "write the settings about to change to the file before any write, restore them using ght rollback")
+	fetchFlags(repo)
 
 	_ = repo.MarkFlagRequired("owner")
 	_ = repo.MarkFlagRequired("name")
 	_ = repo.MarkFlagRequired("template")
 
+	var lockOutput string
+	lock := &cobra.Command{
+		Use:   "lock",
+		Short: "Resolve the template and the files it references into a lockfile",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debugMode(opts)
+
+			// the existing lockfile is the output, its pins are not enforced
+			fetcher, err := ght.NewFetcher(opts, ght.WithLogger(logger))
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			l, err := fetcher.Lock(opts)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			if err := l.Write(lockOutput); err != nil {
+				return err
+			}
+
+			logger.Info().Msgf("%d sources locked in %s", len(l.Sources), lockOutput)
+
+			return nil
+		},
+	}
+
+	lock.Flags().StringVarP(&opts.Template, "template", "t", "", "the name of the JSON file contains the template, can be a local or remote file")
+	lock.Flags().StringVar(&lockOutput, "lockfile", "ght.lock", "the name of the lockfile to write")
+	lock.Flags().BoolVarP(&opts.Debug, "debug", "v", false, "enable debug mode")
+	fetchFlags(lock)
+
+	_ = lock.MarkFlagRequired("template")
+
+	rollback := &cobra.Command{
+		Use:   "rollback <snapshot>",
+		Short: "Restore the repository settings recorded in a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debugMode(opts)
+			cmd.SilenceUsage = true
+
+			if err := ght.ValidateOutput(opts.Output); err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			snap, err := ght.ReadSnapshot(args[0])
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			audit, err := auditLog(opts.AuditLog)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+			defer audit.Close()
+
+			rt, err := ght.NewRepoTemplate(ght.WithLogger(logger), ght.WithAuditLog(audit))
+			if err != nil {
+				return &ExitError{Code: ExitAuth, Err: err}
+			}
+
+			res, err := ght.Rollback(rt, snap, opts)
+			err = withAuditError(err, audit)
+			if err := ght.WriteResult(os.Stdout, res, opts.Output); err != nil {
+				return err
+			}
+
+			return RunError(res, err, opts)
+		},
+	}
+
+	rollback.Flags().BoolVarP(&opts.Debug, "debug", "v", false, "enable debug mode")
+	rollback.Flags().StringVar(&opts.Output, "output", ght.OutputTable, "the format of the rollback results: table, json or yaml")
+	rollback.Flags().BoolVar(&opts.Plan, "plan", false, "report the settings that would be restored without restoring them")
+	rollback.Flags().StringVar(&opts.AuditLog, "audit-log", "", auditLogUsage)
+
+	audit := &cobra.Command{
+		Use:   "audit",
+		Short: "Manage the audit log of the writes made to GitHub",
+	}
+
+	verify := &cobra.Command{
+		Use:   "verify <audit-log>",
+		Short: "Verify the HMAC chain of an audit log, using the key in the " + ght.AuditKeyEnv + " env var",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debugMode(opts)
+			cmd.SilenceUsage = true
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+			defer f.Close()
+
+			n, err := ght.VerifyAuditLog(f, []byte(os.Getenv(ght.AuditKeyEnv)))
+			if err != nil {
+				return err
+			}
+
+			logger.Info().Msgf("%d entries verified in %s", n, args[0])
+
+			return nil
+		},
+	}
+
+	audit.AddCommand(verify)
+
+	var serveAddr, serveConfig string
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a GitHub webhook applying the templates to the repositories created or transferred",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debugMode(opts)
+			cmd.SilenceUsage = true
+
+			secret := os.Getenv(ght.WebhookSecretEnv)
+			if secret == "" {
+				return &ExitError{Code: ExitConfig, Err: fmt.Errorf("%s is not set", ght.WebhookSecretEnv)}
+			}
+
+			cfg, err := ght.ReadServeConfig(serveConfig)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			fetcher, err := ght.NewFetcher(opts, ght.WithLogger(logger))
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+
+			audit, err := auditLog(opts.AuditLog)
+			if err != nil {
+				return &ExitError{Code: ExitConfig, Err: err}
+			}
+			defer audit.Close()
+
+			rt, err := ght.NewRepoTemplate(ght.WithLogger(logger), ght.WithFetcher(fetcher), ght.WithAuditLog(audit))
+			if err != nil {
+				return &ExitError{Code: ExitAuth, Err: err}
+			}
+
+			wh := ght.NewWebhookServer(rt, cfg, []byte(secret), opts)
+			wh.Start()
+
+			srv := &http.Server{Addr: serveAddr, Handler: wh, ReadHeaderTimeout: 10 * time.Second}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			go func() {
+				<-ctx.Done()
+				logger.Info().Msg("shutting down, waiting for the queued jobs")
+				shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				_ = srv.Shutdown(shutdown)
+			}()
+
+			logger.Info().Msgf("serving the webhook on %s", serveAddr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+
+			wh.Stop()
+
+			return nil
+		},
+	}
+
+	serve.Flags().StringVar(&serveAddr, "addr", ":8080", "the address the webhook is served on")
+	serve.Flags().StringVarP(&serveConfig, "config", "c", "", "the JSON file with the rules selecting the template of each repository")
+	serve.Flags().BoolVarP(&opts.Debug, "debug", "v", false, "enable debug mode")
+	serve.Flags().BoolVar(&opts.ContinueOnError, "continue-on-error", false, "keep applying the remaining sections when one fails, reporting all the failures")
+	serve.Flags().StringVar(&opts.AuditLog, "audit-log", "", auditLogUsage)
+	serve.Flags().BoolVar(&opts.AllowRemoteSecretSources, "allow-remote-secret-sources", false, allowRemoteSecretSourcesUsage)
+	fetchFlags(serve)
+
+	_ = serve.MarkFlagRequired("config")
+
 	version := &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number of ght",
@@ -113,12 +326,67 @@ func command() *cobra.Command {
 	}
 
 	root.AddCommand(repo)
+	root.AddCommand(lock)
+	root.AddCommand(rollback)
+	root.AddCommand(audit)
+	root.AddCommand(serve)
 	root.AddCommand(version)
 
 	return root
 }
 
-func debugMode(opts *RepoOptions) {
+// allowRemoteSecretSourcesUsage is the usage of the --allow-remote-secret-sources flag
+const allowRemoteSecretSourcesUsage = "let a remote template read its secrets from local files and commands, only for templates you trust"
+
+// auditLogUsage is the usage of the --audit-log flag
+const auditLogUsage = "append every write made to GitHub to the JSON lines file, - for stderr as stdout carries the results; the entries are chained using an HMAC when the " + ght.AuditKeyEnv + " env var is set"
+
+// withAuditError adds to the error of a run the first write the audit log failed to record
+func withAuditError(err error, audit *ght.AuditLog) error {
+	if aerr := audit.Err(); aerr != nil {
+		return errors.Join(err, fmt.Errorf("failed to record the audit log |→ %w", aerr))
+	}
+
+	return err
+}
+
+// auditLog opens the audit log, nil when the path is empty
+func auditLog(path string) (*ght.AuditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	return ght.OpenAuditLog(path, []byte(os.Getenv(ght.AuditKeyEnv)))
+}
+
+// actionInputs sets the flags not given on the command line from the workflow
+// inputs of the same name, e.g. INPUT_TEMPLATE or INPUT_CONTINUE-ON-ERROR
+func actionInputs(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		if v := ght.ActionInput(f.Name); v != "" {
+			if serr := cmd.Flags().Set(f.Name, v); serr != nil {
+				err = &ExitError{Code: ExitConfig, Err: fmt.Errorf("invalid input %s |→ %w", f.Name, serr)}
+			}
+		}
+	})
+
+	return err
+}
+
+// fetchFlags adds the flags used to fetch remote files
+func fetchFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", ght.DefaultFetchTimeout, "the maximum time spent fetching a remote file")
+	cmd.Flags().StringVar(&opts.CABundle, "ca-bundle", "", "the PEM file with additional CA certificates used to fetch remote files")
+	cmd.Flags().StringVar(&opts.Proxy, "proxy", "", "the proxy url used to fetch remote files, defaults to the HTTPS_PROXY env var")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "the directory where remote files are cached, defaults to ght under the user cache dir")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "use only cached remote files, never reaching the network")
+}
+
+func debugMode(opts *ght.RepoOptions) {
 	level := zerolog.InfoLevel
 	if opts.Debug {
 		level = zerolog.DebugLevel
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoWithoutLockfile(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+
+	t.Setenv("GITHUB_TOKEN", "token")
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv(ght.ActionsEnv, "")
+
+	dir := t.TempDir()
+	template := filepath.Join(dir, "template.json")
+	assert.Nil(t, os.WriteFile(template, []byte(`{"repository": {"description": "ght"}}`), 0o600))
+
+	cmd := command()
+	cmd.SetArgs([]string{"repo", "--owner", "acme", "--name", "ght", "--template", template, "--cache-dir", dir})
+	assert.Nil(t, cmd.Execute())
+
+	// the lockfile written by lock is not read by the next repo run
+	lockfile := filepath.Join(dir, "ght.lock")
+	cmd = command()
+	cmd.SetArgs([]string{"lock", "--template", template, "--lockfile", lockfile, "--cache-dir", dir})
+	assert.Nil(t, cmd.Execute())
+	assert.FileExists(t, lockfile)
+
+	cmd = command()
+	cmd.SetArgs([]string{"repo", "--owner", "acme", "--name", "ght", "--template", template, "--cache-dir", dir})
+	assert.Nil(t, cmd.Execute())
+}
@@ -0,0 +1,257 @@
+package ght
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Env vars set by the GitHub Actions runner
+const (
+	ActionsEnv     = "GITHUB_ACTIONS"
+	StepSummaryEnv = "GITHUB_STEP_SUMMARY"
+	OutputEnv      = "GITHUB_OUTPUT"
+)
+
+// InGitHubActions reports whether ght runs in a GitHub Actions workflow
+func InGitHubActions() bool {
+	return os.Getenv(ActionsEnv) == "true"
+}
+
+// ActionInput returns the value of the workflow input, read from its INPUT_* env var
+func ActionInput(name string) string {
+	name = strings.ToUpper(strings.ReplaceAll(name, " ", "_"))
+	if v, ok := os.LookupEnv("INPUT_" + name); ok {
+		return strings.TrimSpace(v)
+	}
+
+	// the runner keeps the hyphens, which most shells can't set
+	return strings.TrimSpace(os.Getenv("INPUT_" + strings.ReplaceAll(name, "-", "_")))
+}
+
+// WriteActionReport appends the run results to the job summary and to the step
+// outputs, and writes the annotations of the failed and pending steps to w.
+// The template is read to point the annotations at its lines, when it is a local file.
+func WriteActionReport(w io.Writer, res *RepoResponse, runErr error, opts *RepoOptions) error {
+	template, _ := os.ReadFile(opts.Template)
+	WriteAnnotations(w, res, runErr, opts, template)
+
+	if path := os.Getenv(StepSummaryEnv); path != "" {
+		if err := appendFile(path, func(f io.Writer) error { return WriteStepSummary(f, res, runErr, opts) }); err != nil {
+			return err
+		}
+	}
+
+	if path := os.Getenv(OutputEnv); path != "" {
+		if err := appendFile(path, func(f io.Writer) error { return WriteActionOutputs(f, res, runErr) }); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendFile appends what fn writes to the file
+func appendFile(path string, fn func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s |→ %w", path, err)
+	}
+
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteStepSummary writes the run results as Markdown, for the job summary
+func WriteStepSummary(w io.Writer, res *RepoResponse, runErr error, opts *RepoOptions) error {
+	mode := "run"
+	if opts.Plan {
+		mode = "plan"
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "### ght %s: `%s/%s`\n\n", mode, opts.Owner, opts.Name)
+
+	if res == nil {
+		fmt.Fprintf(buf, ":x: %s\n\n", markdownCell(runErr.Error()))
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	counts := map[Action]int{}
+	changes := 0
+	for _, step := range res.Steps {
+		counts[step.Action]++
+		if step.Action.changes() {
+			changes++
+		}
+	}
+
+	switch {
+	case runErr != nil:
+		fmt.Fprintf(buf, ":x: %d step(s) failed\n\n", counts[ActionFailed])
+	case opts.Plan && res.Changed():
+		fmt.Fprintf(buf, ":warning: %d change(s) pending\n\n", changes)
+	case res.Changed():
+		fmt.Fprintf(buf, ":white_check_mark: %d change(s) applied\n\n", changes)
+	default:
+		fmt.Fprintf(buf, ":white_check_mark: the repository matches the template\n\n")
+	}
+
+	fmt.Fprintf(buf, "| Step | Action | Error |\n| --- | --- | --- |\n")
+	for _, step := range res.Steps {
+		fmt.Fprintf(buf, "| `%s` | %s | %s |\n", step.Name, step.Action, markdownCell(step.Error))
+	}
+	fmt.Fprintln(buf)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write step summary |→ %w", err)
+	}
+
+	return nil
+}
+
+// markdownCell escapes the text so that it fits in a Markdown table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// WriteActionOutputs writes the step outputs: changed, created, failed (the
+// number of failed steps) and result (the run results as JSON)
+func WriteActionOutputs(w io.Writer, res *RepoResponse, runErr error) error {
+	if res == nil {
+		res = &RepoResponse{}
+	}
+
+	failed := 0
+	for _, step := range res.Steps {
+		if step.Action == ActionFailed {
+			failed++
+		}
+	}
+	if runErr != nil && failed == 0 {
+		failed = 1
+	}
+
+	result, err := json.Marshal(res)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result |→ %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "changed=%t\ncreated=%t\nfailed=%d\nresult=%s\n", res.Changed(), res.Created, failed, result)
+	if err != nil {
+		return fmt.Errorf("failed to write step outputs |→ %w", err)
+	}
+
+	return nil
+}
+
+// WriteAnnotations writes an error annotation for each failed step, a warning
+// annotation for each step warning and, in plan mode, for each pending change.
+// The annotations point at the line of the template section of the step, when
+// it is found.
+func WriteAnnotations(w io.Writer, res *RepoResponse, runErr error, opts *RepoOptions, template []byte) {
+	file := ""
+	if template != nil {
+		file = strings.TrimPrefix(opts.Template, "./")
+	}
+
+	if res == nil {
+		line := 0
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(runErr, &syntaxErr):
+			line = lineAt(template, syntaxErr.Offset)
+		case errors.As(runErr, &typeErr):
+			line = lineAt(template, typeErr.Offset)
+		}
+		annotation(w, "error", file, line, runErr.Error())
+		return
+	}
+
+	for _, step := range res.Steps {
+		line := sectionLine(template, templateSection(step.Name))
+		switch {
+		case step.Action == ActionFailed:
+			annotation(w, "error", file, line, fmt.Sprintf("%s: %s", step.Name, step.Error))
+		case opts.Plan && step.Action.changes():
+			annotation(w, "warning", file, line, fmt.Sprintf("%s would be %s", step.Name, step.Action))
+		}
+		if step.Warning != "" {
+			annotation(w, "warning", file, line, fmt.Sprintf("%s: %s", step.Name, step.Warning))
+		}
+	}
+}
+
+// templateSection returns the template key a step comes from
+func templateSection(step string) string {
+	switch step {
+	case PullRequestTemplate:
+		return "pull_request_template"
+	case IssueTemplate:
+		return "issue_template"
+	}
+
+	// the steps per branch are named <section>:<branch>
+	section, _, _ := strings.Cut(step, ":")
+	return section
+}
+
+// sectionLine returns the line of the first "section": key in the template, 0 when it is not found
+func sectionLine(template []byte, section string) int {
+	re := regexp.MustCompile(`"` + regexp.QuoteMeta(section) + `"\s*:`)
+	loc := re.FindIndex(template)
+	if loc == nil {
+		return 0
+	}
+
+	return lineAt(template, int64(loc[0]))
+}
+
+// lineAt returns the line of the byte offset, 0 when it is out of the data
+func lineAt(data []byte, offset int64) int {
+	if offset <= 0 || offset > int64(len(data)) {
+		return 0
+	}
+
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// annotation writes a workflow command creating an annotation
+func annotation(w io.Writer, level, file string, line int, message string) {
+	var props []string
+	if file != "" {
+		props = append(props, "file="+annotationProperty(file))
+		if line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", line))
+		}
+	}
+	props = append(props, "title=ght")
+
+	fmt.Fprintf(w, "::%s %s::%s\n", level, strings.Join(props, ","), annotationData(message))
+}
+
+// annotationData escapes the message of a workflow command
+func annotationData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// annotationProperty escapes a property of a workflow command
+func annotationProperty(s string) string {
+	s = annotationData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
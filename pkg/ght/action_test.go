@@ -0,0 +1,98 @@
+package ght
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func actionResponse() *RepoResponse {
+	return &RepoResponse{
+		Fullname: "acme/ght",
+		Steps: []StepResult{
+			{Name: "repository", Action: ActionUnchanged},
+			{Name: PullRequestTemplate, Action: ActionUpdated},
+			{Name: "branch_protection:main", Action: ActionFailed, Error: "422 Validation Failed | checks"},
+		},
+	}
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	opts := e2eOptions()
+	opts.Plan = true
+	template, _ := os.ReadFile(opts.Template)
+
+	out := &bytes.Buffer{}
+	WriteAnnotations(out, actionResponse(), errors.New("failed"), opts, template)
+
+	assert.Equal(t, "::warning file=testdata/full-repo.json,line=23,title=ght::.github/pull_request_template.md would be updated\n"+
+		"::error file=testdata/full-repo.json,line=10,title=ght::branch_protection:main: 422 Validation Failed | checks\n", out.String())
+
+	// the error of a template that can't be parsed points at its line
+	template = []byte("{\n  \"repository\": {\n    \"private\": yes\n  }\n}\n")
+	err := json.Unmarshal(template, &Config{})
+
+	out.Reset()
+	WriteAnnotations(out, nil, err, opts, template)
+	assert.True(t, strings.HasPrefix(out.String(), "::error file=testdata/full-repo.json,line=3,title=ght::"))
+
+	// remote templates are not annotated with a file
+	out.Reset()
+	WriteAnnotations(out, actionResponse(), nil, opts, nil)
+	assert.Contains(t, out.String(), "::error title=ght::branch_protection:main")
+}
+
+func TestWriteStepSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteStepSummary(out, actionResponse(), errors.New("failed"), e2eOptions()))
+
+	assert.Contains(t, out.String(), "### ght run: `acme/ght`")
+	assert.Contains(t, out.String(), ":x: 1 step(s) failed")
+	assert.Contains(t, out.String(), "| `.github/pull_request_template.md` | updated |  |")
+	assert.Contains(t, out.String(), `| 422 Validation Failed \| checks |`)
+
+	opts := e2eOptions()
+	opts.Plan = true
+	res := actionResponse()
+	res.Steps = res.Steps[:2]
+
+	out.Reset()
+	assert.Nil(t, WriteStepSummary(out, res, nil, opts))
+	assert.Contains(t, out.String(), "### ght plan: `acme/ght`")
+	assert.Contains(t, out.String(), ":warning: 1 change(s) pending")
+}
+
+func TestWriteActionReport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(StepSummaryEnv, filepath.Join(dir, "summary.md"))
+	t.Setenv(OutputEnv, filepath.Join(dir, "output"))
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteActionReport(out, actionResponse(), errors.New("failed"), e2eOptions()))
+	assert.Contains(t, out.String(), "::error file=testdata/full-repo.json,line=10,")
+
+	summary, err := os.ReadFile(filepath.Join(dir, "summary.md"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(summary), "### ght run")
+
+	outputs, err := os.ReadFile(filepath.Join(dir, "output"))
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(outputs)), "\n")
+	assert.Equal(t, []string{"changed=true", "created=false", "failed=1"}, lines[:3])
+	assert.True(t, strings.HasPrefix(lines[3], `result={"fullname":"acme/ght"`))
+}
+
+func TestActionInput(t *testing.T) {
+	t.Setenv("INPUT_TEMPLATE", " ./template.json ")
+	t.Setenv("INPUT_CONTINUE_ON_ERROR", "true")
+
+	assert.Equal(t, "./template.json", ActionInput("template"))
+	assert.Equal(t, "true", ActionInput("continue-on-error"))
+	assert.Equal(t, "", ActionInput("plan"))
+}
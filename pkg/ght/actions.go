@@ -0,0 +1,448 @@
+package ght
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// ActionsConfig is the GitHub Actions configuration of the repository
+type ActionsConfig struct {
+	Secrets     map[string]*SecretSource `json:"secrets,omitempty"`
+	Variables   map[string]string        `json:"variables,omitempty"`
+	Permissions *ActionsPermissions      `json:"permissions,omitempty"`
+}
+
+// SecretSource tells where the value of a secret is read from, one of Env, File or Command.
+//
+// GitHub never returns the value of a secret, so a secret is only updated when
+// it is missing or when its source changed after the secret was last updated:
+// the modification time of the file, or UpdatedAt when set.
+type SecretSource struct {
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+	// Command is run by sh, its output without the trailing newlines is the value
+	Command string `json:"command,omitempty"`
+	// UpdatedAt is when the value last changed, to be bumped when rotating a secret
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// validate checks that a single source is set, nil-safe as a secret may be null in the template
+func (s *SecretSource) validate(name string) error {
+	if s == nil {
+		return fmt.Errorf("secret %s has no source", name)
+	}
+
+	n := 0
+	for _, v := range []string{s.Env, s.File, s.Command} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("secret %s must have exactly one of env, file or command", name)
+	}
+
+	return nil
+}
+
+// local reports whether the value is read from a file or a command of the machine running ght
+func (s *SecretSource) local() bool {
+	return s != nil && (s.File != "" || s.Command != "")
+}
+
+// checkSecretSources fails when a secret is read from a local file or command
+func (c *Config) checkSecretSources() error {
+	var names []string
+	if c.Actions != nil {
+		for name, s := range c.Actions.Secrets {
+			if s.local() {
+				names = append(names, name)
+			}
+		}
+	}
+	for env, e := range c.Environments {
+		if e == nil {
+			continue
+		}
+		for name, s := range e.Secrets {
+			if s.local() {
+				names = append(names, env+"/"+name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("secrets %s use a file or command, set --allow-remote-secret-sources to trust the template |→ %w", strings.Join(names, ", "), ErrRemoteSecretSource)
+}
+
+// value reads the value of the secret from its source
+func (s *SecretSource) value(name string) ([]byte, error) {
+	switch {
+	case s.Env != "":
+		v := os.Getenv(s.Env)
+		if v == "" {
+			return nil, fmt.Errorf("env var %s with the value of secret %s is not set", s.Env, name)
+		}
+		return []byte(v), nil
+	case s.File != "":
+		v, err := os.ReadFile(s.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the value of secret %s |→ %w", name, err)
+		}
+		return v, nil
+	default:
+		stderr := &bytes.Buffer{}
+		cmd := exec.Command("sh", "-c", s.Command)
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run the command of secret %s: %s |→ %w", name, strings.TrimSpace(stderr.String()), err)
+		}
+		return bytes.TrimRight(out, "\r\n"), nil
+	}
+}
+
+// updatedAt returns when the value of the secret last changed, false when it is unknown
+func (s *SecretSource) updatedAt() (time.Time, bool) {
+	if s.UpdatedAt != nil {
+		return *s.UpdatedAt, true
+	}
+
+	if s.File != "" {
+		if info, err := os.Stat(s.File); err == nil {
+			return info.ModTime(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// EncryptSecret seals the value with the public key of the repository or environment, as GitHub requires.
+//
+// GitHub API docs: https://docs.github.com/en/rest/guides/encrypting-secrets-for-the-rest-api
+func EncryptSecret(key *github.PublicKey, name string, value []byte) (*github.EncryptedSecret, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key.GetKey())
+	if err != nil || len(decoded) != 32 {
+		return nil, fmt.Errorf("invalid public key %s", key.GetKeyID())
+	}
+
+	var recipient [32]byte
+	copy(recipient[:], decoded)
+
+	sealed, err := box.SealAnonymous(nil, value, &recipient, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret %s |→ %w", name, err)
+	}
+
+	return &github.EncryptedSecret{
+		Name:           name,
+		KeyID:          key.GetKeyID(),
+		EncryptedValue: base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// GetActionsPublicKey fetches the key used to encrypt the Actions secrets of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#get-a-repository-public-key
+func (r *RepoTemplate) GetActionsPublicKey(owner, repo string) (*github.PublicKey, error) {
+	ctx := context.Background()
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.GetRepoPublicKey(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Actions public key of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// ListActionsSecrets fetches the names and timestamps of the Actions secrets of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#list-repository-secrets
+func (r *RepoTemplate) ListActionsSecrets(owner, repo string) ([]*github.Secret, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching Actions secrets of %s/%s", owner, repo)
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListRepoSecrets(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Actions secrets of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateOrUpdateActionsSecret creates or updates an encrypted Actions secret of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#create-or-update-a-repository-secret
+func (r *RepoTemplate) CreateOrUpdateActionsSecret(owner, repo string, secret *github.EncryptedSecret) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting Actions secret %s on %s/%s", secret.Name, owner, repo)
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.CreateOrUpdateRepoSecret(ctx, owner, repo, secret); err != nil {
+		return fmt.Errorf("failed to set Actions secret %s on %s/%s |→ %w", secret.Name, owner, repo, err)
+	}
+
+	return nil
+}
+
+// ListActionsVariables fetches the Actions variables of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#list-repository-variables
+func (r *RepoTemplate) ListActionsVariables(owner, repo string) ([]*github.ActionsVariable, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching Actions variables of %s/%s", owner, repo)
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListRepoVariables(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Actions variables of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateActionsVariable creates an Actions variable in a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#create-a-repository-variable
+func (r *RepoTemplate) CreateActionsVariable(owner, repo string, variable *github.ActionsVariable) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating Actions variable %s on %s/%s", variable.Name, owner, repo)
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.CreateRepoVariable(ctx, owner, repo, variable); err != nil {
+		return fmt.Errorf("failed to create Actions variable %s on %s/%s |→ %w", variable.Name, owner, repo, err)
+	}
+
+	return nil
+}
+
+// UpdateActionsVariable updates an Actions variable of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#update-a-repository-variable
+func (r *RepoTemplate) UpdateActionsVariable(owner, repo string, variable *github.ActionsVariable) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("updating Actions variable %s on %s/%s", variable.Name, owner, repo)
+
+	api, err := extension[ActionsSecretsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.UpdateRepoVariable(ctx, owner, repo, variable); err != nil {
+		return fmt.Errorf("failed to update Actions variable %s on %s/%s |→ %w", variable.Name, owner, repo, err)
+	}
+
+	return nil
+}
+
+// ActionsSecrets creates the Actions secrets missing in the repository and
+// updates the ones whose source changed since. Only the names and timestamps
+// of the secrets are part of the steps.
+func (r *RepoTemplate) ActionsSecrets(opts *RepoOptions, secrets map[string]*SecretSource, missing bool) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+
+	var current []*github.Secret
+	if !missing {
+		var err error
+		current, err = r.ListActionsSecrets(owner, repo)
+		if err != nil {
+			return []StepResult{{Name: "secrets", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	return reconcileSecrets(opts, "secrets:", secrets, current,
+		func() (*github.PublicKey, error) { return r.GetActionsPublicKey(owner, repo) },
+		func(secret *github.EncryptedSecret) error { return r.CreateOrUpdateActionsSecret(owner, repo, secret) },
+	)
+}
+
+// ActionsVariables creates or updates the Actions variables of the repository
+func (r *RepoTemplate) ActionsVariables(opts *RepoOptions, variables map[string]string, missing bool) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+
+	var current []*github.ActionsVariable
+	if !missing {
+		var err error
+		current, err = r.ListActionsVariables(owner, repo)
+		if err != nil {
+			return []StepResult{{Name: "variables", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	return reconcileVariables(opts, "variables:", variables, current,
+		func(v *github.ActionsVariable) error { return r.CreateActionsVariable(owner, repo, v) },
+		func(v *github.ActionsVariable) error { return r.UpdateActionsVariable(owner, repo, v) },
+	)
+}
+
+// reconcileSecrets compares the secrets to the current ones by name and
+// timestamp, and writes the missing and outdated ones, encrypted with the key
+func reconcileSecrets(opts *RepoOptions, prefix string, secrets map[string]*SecretSource, current []*github.Secret,
+	publicKey func() (*github.PublicKey, error), put func(*github.EncryptedSecret) error) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+		key   *github.PublicKey
+	)
+
+	byName := map[string]*github.Secret{}
+	for _, s := range current {
+		byName[s.Name] = s
+	}
+
+	for _, name := range sortedKeys(secrets) {
+		source := secrets[name]
+		step := StepResult{Name: prefix + name}
+
+		err := source.validate(name)
+		if err == nil {
+			existing := byName[name]
+			outdated := false
+			if existing != nil {
+				step.Before = existing.UpdatedAt
+				if t, ok := source.updatedAt(); ok {
+					step.After = t.UTC()
+					outdated = t.After(existing.UpdatedAt.Time)
+				}
+			}
+			step.Action = action(existing == nil, outdated)
+
+			if step.Action != ActionUnchanged && !opts.Plan {
+				err = putSecret(name, source, &key, publicKey, put)
+			}
+		}
+
+		if err != nil {
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// putSecret reads, encrypts and writes a secret, fetching the public key once
+func putSecret(name string, source *SecretSource, key **github.PublicKey, publicKey func() (*github.PublicKey, error), put func(*github.EncryptedSecret) error) error {
+	value, err := source.value(name)
+	if err != nil {
+		return err
+	}
+
+	if *key == nil {
+		k, err := publicKey()
+		if err != nil {
+			return err
+		}
+		*key = k
+	}
+
+	encrypted, err := EncryptSecret(*key, name, value)
+	if err != nil {
+		return err
+	}
+
+	return put(encrypted)
+}
+
+// reconcileVariables compares the variables to the current ones by name and
+// value, creating the missing ones and updating the changed ones
+func reconcileVariables(opts *RepoOptions, prefix string, variables map[string]string, current []*github.ActionsVariable,
+	create, update func(*github.ActionsVariable) error) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	byName := map[string]*github.ActionsVariable{}
+	for _, v := range current {
+		// variable names are case insensitive and returned in upper case
+		byName[strings.ToUpper(v.Name)] = v
+	}
+
+	for _, name := range sortedKeys(variables) {
+		value := variables[name]
+		existing := byName[strings.ToUpper(name)]
+
+		step := StepResult{Name: prefix + name, After: value}
+		if existing != nil {
+			step.Before = existing.Value
+		}
+		step.Action = action(existing == nil, existing != nil && existing.Value != value)
+
+		var err error
+		switch {
+		case step.Action == ActionUnchanged || opts.Plan:
+		case existing == nil:
+			err = create(&github.ActionsVariable{Name: name, Value: value})
+		default:
+			err = update(&github.ActionsVariable{Name: existing.Name, Value: value})
+		}
+
+		if err != nil {
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// sortedKeys returns the keys of the map sorted, so that the steps are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
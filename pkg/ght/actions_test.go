@@ -0,0 +1,184 @@
+package ght
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionsSecretsAndVariables(t *testing.T) {
+	t.Setenv("SONAR_TOKEN_VALUE", "sonar-s3cr3t")
+	password := filepath.Join(t.TempDir(), "password")
+	assert.Nil(t, os.WriteFile(password, []byte("registry-s3cr3t"), 0o600))
+
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{
+		Secrets: map[string]*SecretSource{
+			"SONAR_TOKEN":       {Env: "SONAR_TOKEN_VALUE"},
+			"REGISTRY_PASSWORD": {File: password},
+			"REGISTRY_USER":     {Command: "echo robot"},
+		},
+		Variables: map[string]string{"REGISTRY": "ghcr.io/acme"},
+	}})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	names := []string{}
+	for _, step := range res.Steps[1:] {
+		names = append(names, step.Name)
+		assert.Equal(t, ActionCreated, step.Action, step.Name)
+	}
+	assert.Equal(t, []string{"secrets:REGISTRY_PASSWORD", "secrets:REGISTRY_USER", "secrets:SONAR_TOKEN", "variables:REGISTRY"}, names)
+
+	for name, want := range map[string]string{"SONAR_TOKEN": "sonar-s3cr3t", "REGISTRY_PASSWORD": "registry-s3cr3t", "REGISTRY_USER": "robot"} {
+		got, ok := srv.Secret("acme", "ght", name)
+		assert.True(t, ok)
+		assert.Equal(t, want, got)
+	}
+	v, _ := srv.Variable("acme", "ght", "REGISTRY")
+	assert.Equal(t, "ghcr.io/acme", v)
+
+	// the file is older than the secret now, so nothing changes
+	old := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(password, old, old))
+
+	assertNoOp(t, srv, rt, opts)
+
+	out, _ := json.Marshal(res)
+	for _, secret := range []string{"sonar-s3cr3t", "registry-s3cr3t", "robot"} {
+		assert.False(t, strings.Contains(string(out), secret))
+	}
+}
+
+func TestActionsSecretsPlanComparesTimestamps(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	updated := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSecret("acme", "ght", "ROTATED", "old", updated)
+	srv.AddSecret("acme", "ght", "STABLE", "old", updated)
+	srv.AddVariable("acme", "ght", "REGISTRY", "docker.io")
+
+	rotated := updated.Add(24 * time.Hour)
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{
+		Secrets: map[string]*SecretSource{
+			"ROTATED": {Command: "exit 1", UpdatedAt: &rotated},
+			"STABLE":  {Command: "exit 1"},
+			"NEW":     {Command: "exit 1"},
+		},
+		Variables: map[string]string{"REGISTRY": "ghcr.io/acme"},
+	}})
+
+	// the commands would fail, the plan never reads the values
+	res := plan(t, srv, rt, opts)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps[1:] {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"secrets:NEW":        ActionCreated,
+		"secrets:ROTATED":    ActionUpdated,
+		"secrets:STABLE":     ActionUnchanged,
+		"variables:REGISTRY": ActionUpdated,
+	}, actions)
+
+	opts.Plan = false
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Contains(t, res.Steps[1].Error, "secret NEW")
+}
+
+func TestActionsSecretsEncryptedWithOneKey(t *testing.T) {
+	t.Setenv("SONAR_TOKEN_VALUE", "sonar-s3cr3t")
+	srv, rt := newTestServer(t, "ght")
+	srv.AddVariable("acme", "ght", "REGISTRY", "docker.io")
+
+	// the variable names are case insensitive, the existing one is updated under its own name
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{
+		Secrets: map[string]*SecretSource{
+			"SONAR_TOKEN":   {Env: "SONAR_TOKEN_VALUE"},
+			"REGISTRY_USER": {Command: "echo robot"},
+		},
+		Variables: map[string]string{"registry": "ghcr.io/acme"},
+	}})
+
+	srv.Reset()
+	_, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	keys := 0
+	for _, req := range srv.Requests() {
+		if req == "GET /repos/acme/ght/actions/secrets/public-key" {
+			keys++
+		}
+	}
+	assert.Equal(t, 1, keys)
+
+	body := srv.Bodies("PUT /repos/acme/ght/actions/secrets/SONAR_TOKEN")[0]
+	secret := struct {
+		KeyID          string `json:"key_id"`
+		EncryptedValue string `json:"encrypted_value"`
+	}{}
+	assert.Nil(t, json.Unmarshal([]byte(body), &secret))
+	assert.NotEmpty(t, secret.KeyID)
+	assert.NotEmpty(t, secret.EncryptedValue)
+	assert.NotContains(t, body, "sonar-s3cr3t")
+	assert.NotContains(t, srv.Bodies("PUT /repos/acme/ght/actions/secrets/REGISTRY_USER")[0], "robot")
+
+	assert.Equal(t, []string{`{"name":"REGISTRY","value":"ghcr.io/acme"}`}, srv.Bodies("PATCH /repos/acme/ght/actions/variables/REGISTRY"))
+	assert.Empty(t, srv.Bodies("POST /repos/acme/ght/actions/variables"))
+}
+
+func TestSecretSourceValidate(t *testing.T) {
+	assert.NotNil(t, (&SecretSource{}).validate("TOKEN"))
+	assert.NotNil(t, (&SecretSource{Env: "A", File: "b"}).validate("TOKEN"))
+	assert.Nil(t, (&SecretSource{Env: "A"}).validate("TOKEN"))
+	assert.EqualError(t, (*SecretSource)(nil).validate("TOKEN"), "secret TOKEN has no source")
+}
+
+func TestActionsSecretWithoutSource(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	// "secrets": {"TOKEN": null}
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{Secrets: map[string]*SecretSource{"TOKEN": nil}}})
+	opts.ContinueOnError = true
+
+	res, err := Run(rt, opts)
+	assert.ErrorContains(t, err, "secret TOKEN has no source")
+	assert.Equal(t, "secrets:TOKEN", res.Steps[1].Name)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	_, ok := srv.Secret("acme", "ght", "TOKEN")
+	assert.False(t, ok)
+}
+
+func TestRemoteTemplateSecretSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"actions": {"secrets": {"TOKEN": {"env": "TOKEN"}, "KEY": {"file": "/etc/shadow"}}},
+			"environments": {"production": {"secrets": {"DEPLOY": {"command": "cat ~/.ssh/id_rsa"}}}}
+		}`))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), apiURL: srv.URL, maxSize: DefaultMaxFetchSize})
+
+	opts := &RepoOptions{Template: "github://platform/repo-standards/templates/service.json"}
+	_, err := LoadRepoConfig(opts)
+	assert.ErrorIs(t, err, ErrRemoteSecretSource)
+	assert.ErrorContains(t, err, "secrets KEY, production/DEPLOY use a file or command")
+	assert.True(t, IsConfigError(err))
+
+	opts.AllowRemoteSecretSources = true
+	cfg, err := LoadRepoConfig(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "/etc/shadow", cfg.Actions.Secrets["KEY"].File)
+}
@@ -0,0 +1,917 @@
+package ght
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// ErrUnsupportedAPI is returned when the GitHubAPI implementation does not
+// implement the interface of a feature
+var ErrUnsupportedAPI = errors.New("feature not supported by the GitHub API implementation")
+
+// GitHubAPI is the subset of the GitHub REST API used by RepoTemplate.
+//
+// Errors returned by the API must keep the *github.ErrorResponse in their chain,
+// so that missing resources (404 Not Found) can be told apart from failures.
+//
+// The features added later have their own interfaces, that an implementation
+// may leave out: the sections using them then fail with ErrUnsupportedAPI.
+// This keeps GitHubAPI stable when features are added.
+type GitHubAPI interface {
+	GetOrganization(ctx context.Context, org string) (*github.Organization, error)
+	GetRepository(ctx context.Context, owner, repo string) (*github.Repository, error)
+	CreateRepository(ctx context.Context, org string, repo *github.Repository) (*github.Repository, error)
+	CreateRepositoryFromTemplate(ctx context.Context, owner, repo string, req *github.TemplateRepoRequest) (*github.Repository, error)
+	ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, error)
+
+	GetBranch(ctx context.Context, owner, repo, branch string) (*github.Branch, error)
+	GetBranchProtection(ctx context.Context, owner, repo, branch string) (*github.Protection, error)
+	UpdateBranchProtection(ctx context.Context, owner, repo, branch string, req *github.ProtectionRequest) (*github.Protection, error)
+	GetSignaturesProtectedBranch(ctx context.Context, owner, repo, branch string) (*github.SignaturesProtectedBranch, error)
+	RequireSignaturesOnProtectedBranch(ctx context.Context, owner, repo, branch string) (*github.SignaturesProtectedBranch, error)
+	OptionalSignaturesOnProtectedBranch(ctx context.Context, owner, repo, branch string) error
+
+	GetContents(ctx context.Context, owner, repo, path string) (*github.RepositoryContent, error)
+	CreateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error
+	UpdateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error
+}
+
+// RepositoryEditAPI updates the settings of an existing repository
+type RepositoryEditAPI interface {
+	EditRepository(ctx context.Context, owner, repo string, req *github.Repository) (*github.Repository, error)
+}
+
+// RollbackAPI deletes the settings created by a run, for ght rollback
+type RollbackAPI interface {
+	RemoveBranchProtection(ctx context.Context, owner, repo, branch string) error
+	DeleteFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error
+}
+
+// TeamsAPI checks the team memberships, for the serve rules matching the team of the sender
+type TeamsAPI interface {
+	GetTeamMembership(ctx context.Context, org, team, user string) (*github.Membership, error)
+}
+
+// HooksAPI manages the repository webhooks
+type HooksAPI interface {
+	ListHooks(ctx context.Context, owner, repo string) ([]*github.Hook, error)
+	CreateHook(ctx context.Context, owner, repo string, hook *github.Hook) (*github.Hook, error)
+	EditHook(ctx context.Context, owner, repo string, id int64, hook *github.Hook) (*github.Hook, error)
+	DeleteHook(ctx context.Context, owner, repo string, id int64) error
+}
+
+// ActionsSecretsAPI manages the Actions secrets and variables of a repository
+type ActionsSecretsAPI interface {
+	GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, error)
+	ListRepoSecrets(ctx context.Context, owner, repo string) ([]*github.Secret, error)
+	CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, secret *github.EncryptedSecret) error
+	ListRepoVariables(ctx context.Context, owner, repo string) ([]*github.ActionsVariable, error)
+	CreateRepoVariable(ctx context.Context, owner, repo string, variable *github.ActionsVariable) error
+	UpdateRepoVariable(ctx context.Context, owner, repo string, variable *github.ActionsVariable) error
+}
+
+// EnvironmentsAPI manages the deployment environments, their reviewers, policies, secrets and variables
+type EnvironmentsAPI interface {
+	GetUser(ctx context.Context, login string) (*github.User, error)
+	GetTeamBySlug(ctx context.Context, org, slug string) (*github.Team, error)
+	GetEnvironment(ctx context.Context, owner, repo, name string) (*github.Environment, error)
+	CreateUpdateEnvironment(ctx context.Context, owner, repo, name string, env *github.CreateUpdateEnvironment) (*github.Environment, error)
+	ListDeploymentPolicies(ctx context.Context, owner, repo, env string) ([]*DeploymentPolicy, error)
+	CreateDeploymentPolicy(ctx context.Context, owner, repo, env string, policy *DeploymentPolicy) (*DeploymentPolicy, error)
+	DeleteDeploymentPolicy(ctx context.Context, owner, repo, env string, id int64) error
+	GetEnvPublicKey(ctx context.Context, repoID int64, env string) (*github.PublicKey, error)
+	ListEnvSecrets(ctx context.Context, repoID int64, env string) ([]*github.Secret, error)
+	CreateOrUpdateEnvSecret(ctx context.Context, repoID int64, env string, secret *github.EncryptedSecret) error
+	ListEnvVariables(ctx context.Context, repoID int64, env string) ([]*github.ActionsVariable, error)
+	CreateEnvVariable(ctx context.Context, repoID int64, env string, variable *github.ActionsVariable) error
+	UpdateEnvVariable(ctx context.Context, repoID int64, env string, variable *github.ActionsVariable) error
+}
+
+// SecurityAPI toggles the security features that have their own endpoints
+type SecurityAPI interface {
+	GetVulnerabilityAlerts(ctx context.Context, owner, repo string) (bool, error)
+	SetVulnerabilityAlerts(ctx context.Context, owner, repo string, enabled bool) error
+	GetAutomatedSecurityFixes(ctx context.Context, owner, repo string) (bool, error)
+	SetAutomatedSecurityFixes(ctx context.Context, owner, repo string, enabled bool) error
+	GetPrivateVulnerabilityReporting(ctx context.Context, owner, repo string) (bool, error)
+	SetPrivateVulnerabilityReporting(ctx context.Context, owner, repo string, enabled bool) error
+}
+
+// CodeScanningAPI configures the CodeQL default setup
+type CodeScanningAPI interface {
+	ListLanguages(ctx context.Context, owner, repo string) (map[string]int, error)
+	GetCodeScanningDefaultSetup(ctx context.Context, owner, repo string) (*CodeScanningSetup, error)
+	UpdateCodeScanningDefaultSetup(ctx context.Context, owner, repo string, setup *CodeScanningSetup) error
+}
+
+// ActionsPermissionsAPI manages the Actions permissions and workflow policy
+type ActionsPermissionsAPI interface {
+	GetActionsPermissions(ctx context.Context, owner, repo string) (*github.ActionsPermissionsRepository, error)
+	EditActionsPermissions(ctx context.Context, owner, repo string, permissions *github.ActionsPermissionsRepository) error
+	GetActionsAllowed(ctx context.Context, owner, repo string) (*github.ActionsAllowed, error)
+	EditActionsAllowed(ctx context.Context, owner, repo string, allowed *github.ActionsAllowed) error
+	GetWorkflowPermissions(ctx context.Context, owner, repo string) (*WorkflowPermissions, error)
+	EditWorkflowPermissions(ctx context.Context, owner, repo string, permissions *WorkflowPermissions) error
+	GetForkPRApproval(ctx context.Context, owner, repo string) (string, error)
+	EditForkPRApproval(ctx context.Context, owner, repo, policy string) error
+	GetRetentionDays(ctx context.Context, owner, repo string) (int, error)
+	EditRetentionDays(ctx context.Context, owner, repo string, days int) error
+}
+
+// DeployKeysAPI manages the deploy keys
+type DeployKeysAPI interface {
+	ListKeys(ctx context.Context, owner, repo string) ([]*github.Key, error)
+	CreateKey(ctx context.Context, owner, repo string, key *github.Key) (*github.Key, error)
+	DeleteKey(ctx context.Context, owner, repo string, id int64) error
+}
+
+// AutolinksAPI manages the autolink references
+type AutolinksAPI interface {
+	ListAutolinks(ctx context.Context, owner, repo string) ([]*github.Autolink, error)
+	AddAutolink(ctx context.Context, owner, repo string, link *github.AutolinkOptions) (*github.Autolink, error)
+	DeleteAutolink(ctx context.Context, owner, repo string, id int64) error
+}
+
+// PagesAPI manages the GitHub Pages site
+type PagesAPI interface {
+	GetPages(ctx context.Context, owner, repo string) (*PagesSite, error)
+	CreatePages(ctx context.Context, owner, repo string, site *PagesSite) error
+	UpdatePages(ctx context.Context, owner, repo string, site *PagesSite) error
+}
+
+// BranchesAPI lists, renames and creates branches
+type BranchesAPI interface {
+	ListBranches(ctx context.Context, owner, repo string) ([]*github.Branch, error)
+	RenameBranch(ctx context.Context, owner, repo, branch, name string) error
+	GetCommitSHA1(ctx context.Context, owner, repo, ref string) (string, error)
+	CreateRef(ctx context.Context, owner, repo string, ref *github.Reference) error
+}
+
+// RulesetsAPI manages the repository rulesets
+type RulesetsAPI interface {
+	ListRulesets(ctx context.Context, owner, repo string) ([]*Ruleset, error)
+	GetRuleset(ctx context.Context, owner, repo string, id int64) (*Ruleset, error)
+	CreateRuleset(ctx context.Context, owner, repo string, rs *Ruleset) error
+	UpdateRuleset(ctx context.Context, owner, repo string, id int64, rs *Ruleset) error
+}
+
+// TagProtectionAPI manages the legacy tag protections
+type TagProtectionAPI interface {
+	ListTagProtection(ctx context.Context, owner, repo string) ([]*github.TagProtection, error)
+	CreateTagProtection(ctx context.Context, owner, repo, pattern string) error
+	DeleteTagProtection(ctx context.Context, owner, repo string, id int64) error
+}
+
+// ReleasesAPI manages the release settings
+type ReleasesAPI interface {
+	GetImmutableReleases(ctx context.Context, owner, repo string) (bool, error)
+	SetImmutableReleases(ctx context.Context, owner, repo string, enabled bool) error
+}
+
+// ChecksAPI lists the checks reported on the recent commits of a branch
+type ChecksAPI interface {
+	ListCommits(ctx context.Context, owner, repo, branch string, count int) ([]*github.RepositoryCommit, error)
+	ListCheckRunsForRef(ctx context.Context, owner, repo, ref string) ([]*github.CheckRun, error)
+	ListStatuses(ctx context.Context, owner, repo, ref string) ([]*github.RepoStatus, error)
+}
+
+// extension returns the api as the feature interface T, ErrUnsupportedAPI when it does not implement it
+func extension[T any](api GitHubAPI) (T, error) {
+	ext, ok := api.(T)
+	if !ok {
+		return ext, fmt.Errorf("%w: %T does not implement %s", ErrUnsupportedAPI, api, reflect.TypeOf((*T)(nil)).Elem())
+	}
+
+	return ext, nil
+}
+
+// githubAPI implements every feature interface
+var (
+	_ RepositoryEditAPI     = (*githubAPI)(nil)
+	_ RollbackAPI           = (*githubAPI)(nil)
+	_ TeamsAPI              = (*githubAPI)(nil)
+	_ HooksAPI              = (*githubAPI)(nil)
+	_ ActionsSecretsAPI     = (*githubAPI)(nil)
+	_ EnvironmentsAPI       = (*githubAPI)(nil)
+	_ SecurityAPI           = (*githubAPI)(nil)
+	_ CodeScanningAPI       = (*githubAPI)(nil)
+	_ ActionsPermissionsAPI = (*githubAPI)(nil)
+	_ DeployKeysAPI         = (*githubAPI)(nil)
+	_ AutolinksAPI          = (*githubAPI)(nil)
+	_ PagesAPI              = (*githubAPI)(nil)
+	_ BranchesAPI           = (*githubAPI)(nil)
+	_ RulesetsAPI           = (*githubAPI)(nil)
+	_ TagProtectionAPI      = (*githubAPI)(nil)
+	_ ReleasesAPI           = (*githubAPI)(nil)
+	_ ChecksAPI             = (*githubAPI)(nil)
+)
+
+// NewGitHubAPI wraps a go-github client into a GitHubAPI
+func NewGitHubAPI(client *github.Client) GitHubAPI {
+	return &githubAPI{client: client}
+}
+
+// githubAPI implements GitHubAPI using go-github
+type githubAPI struct {
+	client *github.Client
+}
+
+func (g *githubAPI) GetOrganization(ctx context.Context, org string) (*github.Organization, error) {
+	res, _, err := g.client.Organizations.Get(ctx, org)
+	return res, err
+}
+
+func (g *githubAPI) GetTeamMembership(ctx context.Context, org, team, user string) (*github.Membership, error) {
+	res, _, err := g.client.Teams.GetTeamMembershipBySlug(ctx, org, team, user)
+	return res, err
+}
+
+func (g *githubAPI) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, error) {
+	res, _, err := g.client.Repositories.Get(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) CreateRepository(ctx context.Context, org string, repo *github.Repository) (*github.Repository, error) {
+	res, _, err := g.client.Repositories.Create(ctx, org, repo)
+	return res, err
+}
+
+func (g *githubAPI) CreateRepositoryFromTemplate(ctx context.Context, owner, repo string, req *github.TemplateRepoRequest) (*github.Repository, error) {
+	res, _, err := g.client.Repositories.CreateFromTemplate(ctx, owner, repo, req)
+	return res, err
+}
+
+func (g *githubAPI) EditRepository(ctx context.Context, owner, repo string, req *github.Repository) (*github.Repository, error) {
+	res, _, err := g.client.Repositories.Edit(ctx, owner, repo, req)
+	return res, err
+}
+
+func (g *githubAPI) ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, error) {
+	res, _, err := g.client.Repositories.ReplaceAllTopics(ctx, owner, repo, topics)
+	return res, err
+}
+
+func (g *githubAPI) GetBranch(ctx context.Context, owner, repo, branch string) (*github.Branch, error) {
+	res, _, err := g.client.Repositories.GetBranch(ctx, owner, repo, branch, true)
+	return res, err
+}
+
+func (g *githubAPI) ListBranches(ctx context.Context, owner, repo string) ([]*github.Branch, error) {
+	var branches []*github.Branch
+	opts := &github.BranchListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		res, resp, err := g.client.Repositories.ListBranches(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, res...)
+		if resp.NextPage == 0 {
+			return branches, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) RenameBranch(ctx context.Context, owner, repo, branch, name string) error {
+	_, _, err := g.client.Repositories.RenameBranch(ctx, owner, repo, branch, name)
+	return err
+}
+
+func (g *githubAPI) GetCommitSHA1(ctx context.Context, owner, repo, ref string) (string, error) {
+	res, _, err := g.client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, "")
+	return res, err
+}
+
+func (g *githubAPI) CreateRef(ctx context.Context, owner, repo string, ref *github.Reference) error {
+	_, _, err := g.client.Git.CreateRef(ctx, owner, repo, ref)
+	return err
+}
+
+func (g *githubAPI) ListCommits(ctx context.Context, owner, repo, branch string, count int) ([]*github.RepositoryCommit, error) {
+	// the most recent commits only, without paginating
+	opts := &github.CommitsListOptions{SHA: branch, ListOptions: github.ListOptions{PerPage: count}}
+	res, _, err := g.client.Repositories.ListCommits(ctx, owner, repo, opts)
+	return res, err
+}
+
+func (g *githubAPI) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string) ([]*github.CheckRun, error) {
+	var runs []*github.CheckRun
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		res, resp, err := g.client.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, opts)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, res.CheckRuns...)
+		if resp.NextPage == 0 {
+			return runs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) ListStatuses(ctx context.Context, owner, repo, ref string) ([]*github.RepoStatus, error) {
+	var statuses []*github.RepoStatus
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Repositories.ListStatuses(ctx, owner, repo, ref, opts)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, res...)
+		if resp.NextPage == 0 {
+			return statuses, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) GetBranchProtection(ctx context.Context, owner, repo, branch string) (*github.Protection, error) {
+	res, _, err := g.client.Repositories.GetBranchProtection(ctx, owner, repo, branch)
+	return res, err
+}
+
+func (g *githubAPI) UpdateBranchProtection(ctx context.Context, owner, repo, branch string, req *github.ProtectionRequest) (*github.Protection, error) {
+	res, _, err := g.client.Repositories.UpdateBranchProtection(ctx, owner, repo, branch, req)
+	return res, err
+}
+
+func (g *githubAPI) RemoveBranchProtection(ctx context.Context, owner, repo, branch string) error {
+	_, err := g.client.Repositories.RemoveBranchProtection(ctx, owner, repo, branch)
+	return err
+}
+
+func (g *githubAPI) GetSignaturesProtectedBranch(ctx context.Context, owner, repo, branch string) (*github.SignaturesProtectedBranch, error) {
+	res, _, err := g.client.Repositories.GetSignaturesProtectedBranch(ctx, owner, repo, branch)
+	return res, err
+}
+
+func (g *githubAPI) RequireSignaturesOnProtectedBranch(ctx context.Context, owner, repo, branch string) (*github.SignaturesProtectedBranch, error) {
+	res, _, err := g.client.Repositories.RequireSignaturesOnProtectedBranch(ctx, owner, repo, branch)
+	return res, err
+}
+
+func (g *githubAPI) OptionalSignaturesOnProtectedBranch(ctx context.Context, owner, repo, branch string) error {
+	_, err := g.client.Repositories.OptionalSignaturesOnProtectedBranch(ctx, owner, repo, branch)
+	return err
+}
+
+func (g *githubAPI) GetContents(ctx context.Context, owner, repo, path string) (*github.RepositoryContent, error) {
+	res, _, _, err := g.client.Repositories.GetContents(ctx, owner, repo, path, nil)
+	return res, err
+}
+
+func (g *githubAPI) CreateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error {
+	_, _, err := g.client.Repositories.CreateFile(ctx, owner, repo, path, opts)
+	return err
+}
+
+func (g *githubAPI) UpdateFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error {
+	_, _, err := g.client.Repositories.UpdateFile(ctx, owner, repo, path, opts)
+	return err
+}
+
+func (g *githubAPI) DeleteFile(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentFileOptions) error {
+	_, _, err := g.client.Repositories.DeleteFile(ctx, owner, repo, path, opts)
+	return err
+}
+
+func (g *githubAPI) ListHooks(ctx context.Context, owner, repo string) ([]*github.Hook, error) {
+	var hooks []*github.Hook
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Repositories.ListHooks(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, res...)
+		if resp.NextPage == 0 {
+			return hooks, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateHook(ctx context.Context, owner, repo string, hook *github.Hook) (*github.Hook, error) {
+	res, _, err := g.client.Repositories.CreateHook(ctx, owner, repo, hook)
+	return res, err
+}
+
+func (g *githubAPI) EditHook(ctx context.Context, owner, repo string, id int64, hook *github.Hook) (*github.Hook, error) {
+	res, _, err := g.client.Repositories.EditHook(ctx, owner, repo, id, hook)
+	return res, err
+}
+
+func (g *githubAPI) DeleteHook(ctx context.Context, owner, repo string, id int64) error {
+	_, err := g.client.Repositories.DeleteHook(ctx, owner, repo, id)
+	return err
+}
+
+func (g *githubAPI) ListKeys(ctx context.Context, owner, repo string) ([]*github.Key, error) {
+	var keys []*github.Key
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Repositories.ListKeys(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, res...)
+		if resp.NextPage == 0 {
+			return keys, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateKey(ctx context.Context, owner, repo string, key *github.Key) (*github.Key, error) {
+	res, _, err := g.client.Repositories.CreateKey(ctx, owner, repo, key)
+	return res, err
+}
+
+func (g *githubAPI) DeleteKey(ctx context.Context, owner, repo string, id int64) error {
+	_, err := g.client.Repositories.DeleteKey(ctx, owner, repo, id)
+	return err
+}
+
+func (g *githubAPI) ListAutolinks(ctx context.Context, owner, repo string) ([]*github.Autolink, error) {
+	var links []*github.Autolink
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Repositories.ListAutolinks(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, res...)
+		if resp.NextPage == 0 {
+			return links, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) AddAutolink(ctx context.Context, owner, repo string, link *github.AutolinkOptions) (*github.Autolink, error) {
+	res, _, err := g.client.Repositories.AddAutolink(ctx, owner, repo, link)
+	return res, err
+}
+
+func (g *githubAPI) DeleteAutolink(ctx context.Context, owner, repo string, id int64) error {
+	_, err := g.client.Repositories.DeleteAutolink(ctx, owner, repo, id)
+	return err
+}
+
+func (g *githubAPI) GetRepoPublicKey(ctx context.Context, owner, repo string) (*github.PublicKey, error) {
+	res, _, err := g.client.Actions.GetRepoPublicKey(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) ListRepoSecrets(ctx context.Context, owner, repo string) ([]*github.Secret, error) {
+	var secrets []*github.Secret
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Actions.ListRepoSecrets(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, res.Secrets...)
+		if resp.NextPage == 0 {
+			return secrets, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateOrUpdateRepoSecret(ctx context.Context, owner, repo string, secret *github.EncryptedSecret) error {
+	_, err := g.client.Actions.CreateOrUpdateRepoSecret(ctx, owner, repo, secret)
+	return err
+}
+
+func (g *githubAPI) ListRepoVariables(ctx context.Context, owner, repo string) ([]*github.ActionsVariable, error) {
+	var variables []*github.ActionsVariable
+	opts := &github.ListOptions{PerPage: 30}
+	for {
+		res, resp, err := g.client.Actions.ListRepoVariables(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, res.Variables...)
+		if resp.NextPage == 0 {
+			return variables, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateRepoVariable(ctx context.Context, owner, repo string, variable *github.ActionsVariable) error {
+	_, err := g.client.Actions.CreateRepoVariable(ctx, owner, repo, variable)
+	return err
+}
+
+func (g *githubAPI) UpdateRepoVariable(ctx context.Context, owner, repo string, variable *github.ActionsVariable) error {
+	_, err := g.client.Actions.UpdateRepoVariable(ctx, owner, repo, variable)
+	return err
+}
+
+func (g *githubAPI) GetUser(ctx context.Context, login string) (*github.User, error) {
+	res, _, err := g.client.Users.Get(ctx, login)
+	return res, err
+}
+
+func (g *githubAPI) GetTeamBySlug(ctx context.Context, org, slug string) (*github.Team, error) {
+	res, _, err := g.client.Teams.GetTeamBySlug(ctx, org, slug)
+	return res, err
+}
+
+func (g *githubAPI) GetEnvironment(ctx context.Context, owner, repo, name string) (*github.Environment, error) {
+	res, _, err := g.client.Repositories.GetEnvironment(ctx, owner, repo, name)
+	return res, err
+}
+
+func (g *githubAPI) CreateUpdateEnvironment(ctx context.Context, owner, repo, name string, env *github.CreateUpdateEnvironment) (*github.Environment, error) {
+	res, _, err := g.client.Repositories.CreateUpdateEnvironment(ctx, owner, repo, name, env)
+	return res, err
+}
+
+// go-github v50 has no deployment policy endpoints, nor the type needed for the tag policies
+
+func (g *githubAPI) ListDeploymentPolicies(ctx context.Context, owner, repo, env string) ([]*DeploymentPolicy, error) {
+	var policies []*DeploymentPolicy
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		u := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies?per_page=%d&page=%d", owner, repo, url.PathEscape(env), opts.PerPage, opts.Page)
+		req, err := g.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res := &struct {
+			BranchPolicies []*DeploymentPolicy `json:"branch_policies"`
+		}{}
+		resp, err := g.client.Do(ctx, req, res)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, res.BranchPolicies...)
+		if resp.NextPage == 0 {
+			return policies, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateDeploymentPolicy(ctx context.Context, owner, repo, env string, policy *DeploymentPolicy) (*DeploymentPolicy, error) {
+	u := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies", owner, repo, url.PathEscape(env))
+	req, err := g.client.NewRequest("POST", u, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DeploymentPolicy{}
+	if _, err := g.client.Do(ctx, req, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (g *githubAPI) DeleteDeploymentPolicy(ctx context.Context, owner, repo, env string, id int64) error {
+	u := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies/%d", owner, repo, url.PathEscape(env), id)
+	req, err := g.client.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.client.Do(ctx, req, nil)
+	return err
+}
+
+func (g *githubAPI) GetEnvPublicKey(ctx context.Context, repoID int64, env string) (*github.PublicKey, error) {
+	res, _, err := g.client.Actions.GetEnvPublicKey(ctx, int(repoID), env)
+	return res, err
+}
+
+func (g *githubAPI) ListEnvSecrets(ctx context.Context, repoID int64, env string) ([]*github.Secret, error) {
+	var secrets []*github.Secret
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		res, resp, err := g.client.Actions.ListEnvSecrets(ctx, int(repoID), env, opts)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, res.Secrets...)
+		if resp.NextPage == 0 {
+			return secrets, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateOrUpdateEnvSecret(ctx context.Context, repoID int64, env string, secret *github.EncryptedSecret) error {
+	_, err := g.client.Actions.CreateOrUpdateEnvSecret(ctx, int(repoID), env, secret)
+	return err
+}
+
+func (g *githubAPI) ListEnvVariables(ctx context.Context, repoID int64, env string) ([]*github.ActionsVariable, error) {
+	var variables []*github.ActionsVariable
+	opts := &github.ListOptions{PerPage: 30}
+	for {
+		res, resp, err := g.client.Actions.ListEnvVariables(ctx, int(repoID), env, opts)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, res.Variables...)
+		if resp.NextPage == 0 {
+			return variables, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) CreateEnvVariable(ctx context.Context, repoID int64, env string, variable *github.ActionsVariable) error {
+	_, err := g.client.Actions.CreateEnvVariable(ctx, int(repoID), env, variable)
+	return err
+}
+
+func (g *githubAPI) UpdateEnvVariable(ctx context.Context, repoID int64, env string, variable *github.ActionsVariable) error {
+	_, err := g.client.Actions.UpdateEnvVariable(ctx, int(repoID), env, variable)
+	return err
+}
+
+func (g *githubAPI) GetVulnerabilityAlerts(ctx context.Context, owner, repo string) (bool, error) {
+	res, _, err := g.client.Repositories.GetVulnerabilityAlerts(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) SetVulnerabilityAlerts(ctx context.Context, owner, repo string, enabled bool) error {
+	var err error
+	if enabled {
+		_, err = g.client.Repositories.EnableVulnerabilityAlerts(ctx, owner, repo)
+	} else {
+		_, err = g.client.Repositories.DisableVulnerabilityAlerts(ctx, owner, repo)
+	}
+	return err
+}
+
+// go-github v50 can't read the automated security fixes nor manage the private vulnerability reporting
+
+func (g *githubAPI) GetAutomatedSecurityFixes(ctx context.Context, owner, repo string) (bool, error) {
+	return g.getEnabled(ctx, fmt.Sprintf("repos/%s/%s/automated-security-fixes", owner, repo))
+}
+
+func (g *githubAPI) SetAutomatedSecurityFixes(ctx context.Context, owner, repo string, enabled bool) error {
+	var err error
+	if enabled {
+		_, err = g.client.Repositories.EnableAutomatedSecurityFixes(ctx, owner, repo)
+	} else {
+		_, err = g.client.Repositories.DisableAutomatedSecurityFixes(ctx, owner, repo)
+	}
+	return err
+}
+
+func (g *githubAPI) GetPrivateVulnerabilityReporting(ctx context.Context, owner, repo string) (bool, error) {
+	return g.getEnabled(ctx, fmt.Sprintf("repos/%s/%s/private-vulnerability-reporting", owner, repo))
+}
+
+func (g *githubAPI) SetPrivateVulnerabilityReporting(ctx context.Context, owner, repo string, enabled bool) error {
+	method := "DELETE"
+	if enabled {
+		method = "PUT"
+	}
+
+	req, err := g.client.NewRequest(method, fmt.Sprintf("repos/%s/%s/private-vulnerability-reporting", owner, repo), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.client.Do(ctx, req, nil)
+	return err
+}
+
+// getEnabled reads the enabled field of a feature
+func (g *githubAPI) getEnabled(ctx context.Context, u string) (bool, error) {
+	req, err := g.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res := &struct {
+		Enabled bool `json:"enabled"`
+	}{}
+	if _, err := g.client.Do(ctx, req, res); err != nil {
+		return false, err
+	}
+
+	return res.Enabled, nil
+}
+
+func (g *githubAPI) ListLanguages(ctx context.Context, owner, repo string) (map[string]int, error) {
+	res, _, err := g.client.Repositories.ListLanguages(ctx, owner, repo)
+	return res, err
+}
+
+// go-github v50 has no code scanning default setup endpoints
+
+func (g *githubAPI) GetCodeScanningDefaultSetup(ctx context.Context, owner, repo string) (*CodeScanningSetup, error) {
+	req, err := g.client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/code-scanning/default-setup", owner, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &CodeScanningSetup{}
+	if _, err := g.client.Do(ctx, req, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (g *githubAPI) UpdateCodeScanningDefaultSetup(ctx context.Context, owner, repo string, setup *CodeScanningSetup) error {
+	req, err := g.client.NewRequest("PATCH", fmt.Sprintf("repos/%s/%s/code-scanning/default-setup", owner, repo), setup)
+	if err != nil {
+		return err
+	}
+
+	// the setup runs in the background, GitHub answers 202 Accepted
+	_, err = g.client.Do(ctx, req, nil)
+	var accepted *github.AcceptedError
+	if errors.As(err, &accepted) {
+		return nil
+	}
+	return err
+}
+
+func (g *githubAPI) GetActionsPermissions(ctx context.Context, owner, repo string) (*github.ActionsPermissionsRepository, error) {
+	res, _, err := g.client.Repositories.GetActionsPermissions(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) EditActionsPermissions(ctx context.Context, owner, repo string, permissions *github.ActionsPermissionsRepository) error {
+	_, _, err := g.client.Repositories.EditActionsPermissions(ctx, owner, repo, *permissions)
+	return err
+}
+
+func (g *githubAPI) GetActionsAllowed(ctx context.Context, owner, repo string) (*github.ActionsAllowed, error) {
+	res, _, err := g.client.Repositories.GetActionsAllowed(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) EditActionsAllowed(ctx context.Context, owner, repo string, allowed *github.ActionsAllowed) error {
+	_, _, err := g.client.Repositories.EditActionsAllowed(ctx, owner, repo, *allowed)
+	return err
+}
+
+// go-github v50 has no build type on the GitHub Pages sites
+
+func (g *githubAPI) GetPages(ctx context.Context, owner, repo string) (*PagesSite, error) {
+	res := &PagesSite{}
+	if err := g.do(ctx, "GET", fmt.Sprintf("repos/%s/%s/pages", owner, repo), nil, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (g *githubAPI) CreatePages(ctx context.Context, owner, repo string, site *PagesSite) error {
+	return g.do(ctx, "POST", fmt.Sprintf("repos/%s/%s/pages", owner, repo), site, nil)
+}
+
+func (g *githubAPI) UpdatePages(ctx context.Context, owner, repo string, site *PagesSite) error {
+	return g.do(ctx, "PUT", fmt.Sprintf("repos/%s/%s/pages", owner, repo), site, nil)
+}
+
+// go-github v50 has no workflow, fork pull request approval nor retention endpoints
+
+func (g *githubAPI) GetWorkflowPermissions(ctx context.Context, owner, repo string) (*WorkflowPermissions, error) {
+	res := &WorkflowPermissions{}
+	if err := g.do(ctx, "GET", fmt.Sprintf("repos/%s/%s/actions/permissions/workflow", owner, repo), nil, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (g *githubAPI) EditWorkflowPermissions(ctx context.Context, owner, repo string, permissions *WorkflowPermissions) error {
+	return g.do(ctx, "PUT", fmt.Sprintf("repos/%s/%s/actions/permissions/workflow", owner, repo), permissions, nil)
+}
+
+func (g *githubAPI) GetForkPRApproval(ctx context.Context, owner, repo string) (string, error) {
+	res := &struct {
+		ApprovalPolicy string `json:"approval_policy"`
+	}{}
+	if err := g.do(ctx, "GET", fmt.Sprintf("repos/%s/%s/actions/permissions/fork-pr-contributor-approval", owner, repo), nil, res); err != nil {
+		return "", err
+	}
+
+	return res.ApprovalPolicy, nil
+}
+
+func (g *githubAPI) EditForkPRApproval(ctx context.Context, owner, repo, policy string) error {
+	body := map[string]string{"approval_policy": policy}
+	return g.do(ctx, "PUT", fmt.Sprintf("repos/%s/%s/actions/permissions/fork-pr-contributor-approval", owner, repo), body, nil)
+}
+
+func (g *githubAPI) GetRetentionDays(ctx context.Context, owner, repo string) (int, error) {
+	res := &struct {
+		Days int `json:"days"`
+	}{}
+	if err := g.do(ctx, "GET", fmt.Sprintf("repos/%s/%s/actions/permissions/artifact-and-log-retention", owner, repo), nil, res); err != nil {
+		return 0, err
+	}
+
+	return res.Days, nil
+}
+
+func (g *githubAPI) EditRetentionDays(ctx context.Context, owner, repo string, days int) error {
+	body := map[string]int{"days": days}
+	return g.do(ctx, "PUT", fmt.Sprintf("repos/%s/%s/actions/permissions/artifact-and-log-retention", owner, repo), body, nil)
+}
+
+// do sends a request to an endpoint go-github does not cover
+func (g *githubAPI) do(ctx context.Context, method, u string, body, res interface{}) error {
+	req, err := g.client.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.client.Do(ctx, req, res)
+	return err
+}
+
+// go-github v50 has no ruleset nor immutable releases endpoints
+
+func (g *githubAPI) ListRulesets(ctx context.Context, owner, repo string) ([]*Ruleset, error) {
+	var rulesets []*Ruleset
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		u := fmt.Sprintf("repos/%s/%s/rulesets?includes_parents=false&per_page=%d&page=%d", owner, repo, opts.PerPage, opts.Page)
+		req, err := g.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res []*Ruleset
+		resp, err := g.client.Do(ctx, req, &res)
+		if err != nil {
+			return nil, err
+		}
+		rulesets = append(rulesets, res...)
+		if resp.NextPage == 0 {
+			return rulesets, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *githubAPI) GetRuleset(ctx context.Context, owner, repo string, id int64) (*Ruleset, error) {
+	res := &Ruleset{}
+	if err := g.do(ctx, "GET", fmt.Sprintf("repos/%s/%s/rulesets/%d", owner, repo, id), nil, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (g *githubAPI) CreateRuleset(ctx context.Context, owner, repo string, rs *Ruleset) error {
+	return g.do(ctx, "POST", fmt.Sprintf("repos/%s/%s/rulesets", owner, repo), rs, nil)
+}
+
+func (g *githubAPI) UpdateRuleset(ctx context.Context, owner, repo string, id int64, rs *Ruleset) error {
+	return g.do(ctx, "PUT", fmt.Sprintf("repos/%s/%s/rulesets/%d", owner, repo, id), rs, nil)
+}
+
+func (g *githubAPI) ListTagProtection(ctx context.Context, owner, repo string) ([]*github.TagProtection, error) {
+	res, _, err := g.client.Repositories.ListTagProtection(ctx, owner, repo)
+	return res, err
+}
+
+func (g *githubAPI) CreateTagProtection(ctx context.Context, owner, repo, pattern string) error {
+	_, _, err := g.client.Repositories.CreateTagProtection(ctx, owner, repo, pattern)
+	return err
+}
+
+func (g *githubAPI) DeleteTagProtection(ctx context.Context, owner, repo string, id int64) error {
+	_, err := g.client.Repositories.DeleteTagProtection(ctx, owner, repo, id)
+	return err
+}
+
+func (g *githubAPI) GetImmutableReleases(ctx context.Context, owner, repo string) (bool, error) {
+	// not found when the immutable releases are disabled
+	on, err := g.getEnabled(ctx, fmt.Sprintf("repos/%s/%s/immutable-releases", owner, repo))
+	if isNotFound(err) {
+		return false, nil
+	}
+	return on, err
+}
+
+func (g *githubAPI) SetImmutableReleases(ctx context.Context, owner, repo string, enabled bool) error {
+	method := "DELETE"
+	if enabled {
+		method = "PUT"
+	}
+
+	return g.do(ctx, method, fmt.Sprintf("repos/%s/%s/immutable-releases", owner, repo), nil, nil)
+}
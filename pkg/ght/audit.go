@@ -0,0 +1,420 @@
+package ght
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+)
+
+// AuditKeyEnv is the env var holding the key of the audit log HMAC chain
+const AuditKeyEnv = "GHT_AUDIT_KEY"
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	AuditError   = "error"
+)
+
+// ErrAuditTampered is returned when an audit log entry does not match its HMAC chain
+var ErrAuditTampered = errors.New("audit log has been tampered with")
+
+// AuditEntry records a write made to the GitHub API
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Repository string    `json:"repository,omitempty"`
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	// BeforeSHA256 is the digest of the resource read right before the write, if it existed
+	BeforeSHA256  string `json:"before_sha256,omitempty"`
+	PayloadSHA256 string `json:"payload_sha256,omitempty"`
+	// AfterSHA256 is the digest of the resource returned by the write
+	AfterSHA256 string `json:"after_sha256,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	Outcome     string `json:"outcome"`
+	Error       string `json:"error,omitempty"`
+	// Prev and HMAC chain the entries when a key is set, so that tampering can be detected
+	Prev string `json:"prev,omitempty"`
+	HMAC string `json:"hmac,omitempty"`
+}
+
+// AuditLog is an append-only JSON lines log of the writes made to the GitHub API
+type AuditLog struct {
+	w      io.Writer
+	closer io.Closer
+	key    []byte
+
+	mu   sync.Mutex
+	prev string
+	err  error
+}
+
+// NewAuditLog creates an audit log writing to w, the entries are chained using
+// an HMAC when the key is not empty.
+func NewAuditLog(w io.Writer, key []byte) *AuditLog {
+	return &AuditLog{w: w, key: key}
+}
+
+// OpenAuditLog opens the audit log file for appending, "-" writes to stderr so
+// that the entries do not mix with the results written to stdout.
+// The HMAC chain goes on from the last entry of the file.
+func OpenAuditLog(path string, key []byte) (*AuditLog, error) {
+	if path == "-" {
+		return NewAuditLog(os.Stderr, key), nil
+	}
+
+	prev, err := lastHMAC(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s |→ %w", path, err)
+	}
+
+	l := NewAuditLog(f, key)
+	l.closer = f
+	l.prev = prev
+
+	return l, nil
+}
+
+// lastHMAC returns the HMAC of the last entry of the file, if any
+func lastHMAC(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read audit log %s |→ %w", path, err)
+	}
+	defer f.Close()
+
+	var last string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			last = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read audit log %s |→ %w", path, err)
+	}
+
+	if last == "" {
+		return "", nil
+	}
+
+	entry := &AuditEntry{}
+	if err := json.Unmarshal([]byte(last), entry); err != nil {
+		return "", fmt.Errorf("failed to parse the last entry of audit log %s |→ %w", path, err)
+	}
+
+	return entry.HMAC, nil
+}
+
+// Close closes the audit log file, nil-safe
+func (l *AuditLog) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+
+	return l.closer.Close()
+}
+
+// Err returns the first entry that could not be written, nil-safe. The writes
+// made to GitHub don't fail when they can't be recorded, as they are already
+// applied, so it must be checked once the run is over.
+func (l *AuditLog) Err() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
+// Write appends the entry to the log, chaining it to the previous one
+func (l *AuditLog) Write(entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.write(entry)
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+
+	return err
+}
+
+// write appends the entry to the log, the lock must be held
+func (l *AuditLog) write(entry *AuditEntry) error {
+	if len(l.key) > 0 {
+		entry.Prev = l.prev
+		entry.HMAC = ""
+		mac, err := auditMAC(l.key, entry)
+		if err != nil {
+			return err
+		}
+		entry.HMAC = mac
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry |→ %w", err)
+	}
+
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry |→ %w", err)
+	}
+	if len(l.key) > 0 {
+		l.prev = entry.HMAC
+	}
+
+	return nil
+}
+
+// auditMAC returns the HMAC of the entry, computed without its own HMAC
+func auditMAC(key []byte, entry *AuditEntry) (string, error) {
+	e := *entry
+	e.HMAC = ""
+
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry |→ %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyAuditLog checks the HMAC chain of every entry, returning the number of entries verified
+func VerifyAuditLog(r io.Reader, key []byte) (int, error) {
+	if len(key) == 0 {
+		return 0, fmt.Errorf("%s is not set", AuditKeyEnv)
+	}
+
+	var prev string
+	n := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		entry := &AuditEntry{}
+		if err := json.Unmarshal([]byte(text), entry); err != nil {
+			return n, fmt.Errorf("%w: line %d is not a valid entry |→ %v", ErrAuditTampered, line, err)
+		}
+
+		if entry.HMAC == "" {
+			return n, fmt.Errorf("%w: line %d is not signed", ErrAuditTampered, line)
+		}
+		if entry.Prev != prev {
+			return n, fmt.Errorf("%w: line %d does not follow the previous entry", ErrAuditTampered, line)
+		}
+
+		mac, err := auditMAC(key, entry)
+		if err != nil {
+			return n, err
+		}
+		if !hmac.Equal([]byte(mac), []byte(entry.HMAC)) {
+			return n, fmt.Errorf("%w: line %d does not match its hmac", ErrAuditTampered, line)
+		}
+
+		prev = entry.HMAC
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("failed to read audit log |→ %w", err)
+	}
+
+	return n, nil
+}
+
+// Wrap returns a copy of the client whose writes are recorded in the audit log.
+// The actor is the login of the user authenticated by the client.
+func (l *AuditLog) Wrap(client *github.Client) *github.Client {
+	return l.wrap(client, zerolog.Nop())
+}
+
+// wrap is Wrap, logging the entries that can't be written
+func (l *AuditLog) wrap(client *github.Client, logger zerolog.Logger) *github.Client {
+	next := client.Client().Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &auditTransport{log: l, next: next, logger: logger}
+	audited := github.NewClient(&http.Client{Transport: t, Timeout: client.Client().Timeout})
+	audited.BaseURL = client.BaseURL
+	audited.UploadURL = client.UploadURL
+	audited.UserAgent = client.UserAgent
+
+	t.actor = func() string {
+		user, _, err := audited.Users.Get(context.Background(), "")
+		if err != nil {
+			return "unknown"
+		}
+		return user.GetLogin()
+	}
+
+	return audited
+}
+
+// auditTransport records the requests that may change a resource, all but GET and HEAD
+type auditTransport struct {
+	log    *AuditLog
+	next   http.RoundTripper
+	actor  func() string
+	logger zerolog.Logger
+
+	once  sync.Once
+	login string
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	t.once.Do(func() { t.login = t.actor() })
+
+	payload, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &AuditEntry{
+		Time:       time.Now().UTC(),
+		Actor:      t.login,
+		Repository: auditRepository(req.URL.Path, payload, t.login),
+		Method:     req.Method,
+		Endpoint:   req.URL.Path,
+	}
+	if len(payload) > 0 {
+		entry.PayloadSHA256 = Digest(payload)
+	}
+	if req.Method != http.MethodPost {
+		entry.BeforeSHA256 = t.before(req)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		entry.Outcome = AuditError
+		entry.Error = err.Error()
+		t.write(entry)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry.Status = res.StatusCode
+	entry.Outcome = AuditSuccess
+	if res.StatusCode >= http.StatusBadRequest {
+		entry.Outcome = AuditFailure
+	} else if len(body) > 0 {
+		entry.AfterSHA256 = Digest(body)
+	}
+
+	// the write is done, a failure to record it must not hide its response
+	t.write(entry)
+
+	return res, nil
+}
+
+// write appends the entry to the log, a failure is logged and reported later by the Err of the log
+func (t *auditTransport) write(entry *AuditEntry) {
+	if err := t.log.Write(entry); err != nil {
+		t.logger.Error().Err(err).Msgf("failed to record %s %s in the audit log", entry.Method, entry.Endpoint)
+	}
+}
+
+// before returns the digest of the resource the request is about to change, empty when it can't be read
+func (t *auditTransport) before(req *http.Request) string {
+	get, err := http.NewRequestWithContext(req.Context(), http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return ""
+	}
+	for k, v := range req.Header {
+		if k != "Content-Type" && k != "Content-Length" {
+			get.Header[k] = v
+		}
+	}
+
+	res, err := t.next.RoundTrip(get)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	return Digest(body)
+}
+
+// auditRepository returns the owner/name of the repository the request is about
+func auditRepository(path string, payload []byte, actor string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	body := struct {
+		Name  string `json:"name"`
+		Owner string `json:"owner"`
+	}{}
+	_ = json.Unmarshal(payload, &body)
+
+	n := len(segments)
+	for i, s := range segments {
+		if s != "repos" {
+			continue
+		}
+
+		switch {
+		case i+1 == n && i > 0 && segments[i-1] == "user":
+			// POST /user/repos
+			return actor + "/" + body.Name
+		case i+1 == n && i > 1 && segments[i-2] == "orgs":
+			// POST /orgs/{org}/repos
+			return segments[i-1] + "/" + body.Name
+		case i+4 == n && segments[i+3] == "generate":
+			// the new repository, created from the template repository
+			return body.Owner + "/" + body.Name
+		case i+2 < n:
+			return segments[i+1] + "/" + segments[i+2]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,164 @@
+package ght
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var auditKey = []byte("secret")
+
+func auditEntries(t *testing.T, data []byte) []AuditEntry {
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		entry := AuditEntry{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestAuditLogRecordsWrites(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	out := &bytes.Buffer{}
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()), WithAuditLog(NewAuditLog(out, auditKey)))
+	assert.Nil(t, err)
+
+	_, err = Run(rt, e2eOptions())
+	assert.Nil(t, err)
+
+	entries := auditEntries(t, out.Bytes())
+	assert.Len(t, entries, len(srv.Writes()))
+	for _, entry := range entries {
+		assert.Equal(t, "octocat", entry.Actor)
+		assert.Equal(t, "acme/ght", entry.Repository)
+		assert.Equal(t, AuditSuccess, entry.Outcome)
+		assert.NotEmpty(t, entry.HMAC)
+	}
+
+	assert.Equal(t, "POST", entries[0].Method)
+	assert.Equal(t, "/orgs/acme/repos", entries[0].Endpoint)
+	assert.Empty(t, entries[0].BeforeSHA256)
+	assert.NotEmpty(t, entries[0].PayloadSHA256)
+	assert.NotEmpty(t, entries[0].AfterSHA256)
+
+	// the topics existed, empty, before being replaced
+	assert.Equal(t, "/repos/acme/ght/topics", entries[1].Endpoint)
+	assert.NotEmpty(t, entries[1].BeforeSHA256)
+
+	n, err := VerifyAuditLog(bytes.NewReader(out.Bytes()), auditKey)
+	assert.Nil(t, err)
+	assert.Equal(t, len(entries), n)
+}
+
+func TestAuditLogRecordsFailures(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+
+	out := &bytes.Buffer{}
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()), WithAuditLog(NewAuditLog(out, nil)))
+	assert.Nil(t, err)
+
+	err = rt.CreateBranchCommitSignProtection("acme", "ght", "main")
+	assert.NotNil(t, err)
+
+	entries := auditEntries(t, out.Bytes())
+	assert.Len(t, entries, 1)
+	assert.Equal(t, AuditFailure, entries[0].Outcome)
+	assert.Equal(t, 404, entries[0].Status)
+	assert.Empty(t, entries[0].HMAC)
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := NewAuditLog(out, auditKey)
+	assert.Nil(t, l.Write(&AuditEntry{Actor: "octocat", Method: "PUT", Endpoint: "/repos/acme/ght/topics", Outcome: AuditSuccess}))
+	assert.Nil(t, l.Write(&AuditEntry{Actor: "octocat", Method: "PATCH", Endpoint: "/repos/acme/ght", Outcome: AuditSuccess}))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	tampered := strings.Replace(out.String(), "octocat", "mallory", 1)
+	_, err := VerifyAuditLog(strings.NewReader(tampered), auditKey)
+	assert.True(t, errors.Is(err, ErrAuditTampered))
+
+	// removing an entry breaks the chain
+	_, err = VerifyAuditLog(strings.NewReader(lines[1]), auditKey)
+	assert.True(t, errors.Is(err, ErrAuditTampered))
+
+	_, err = VerifyAuditLog(strings.NewReader(out.String()), []byte("other"))
+	assert.True(t, errors.Is(err, ErrAuditTampered))
+
+	_, err = VerifyAuditLog(strings.NewReader(out.String()), nil)
+	assert.NotNil(t, err)
+}
+
+func TestOpenAuditLogContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		l, err := OpenAuditLog(path, auditKey)
+		assert.Nil(t, err)
+		assert.Nil(t, l.Write(&AuditEntry{Actor: "octocat", Method: "PUT", Endpoint: "/repos/acme/ght/topics", Outcome: AuditSuccess}))
+		assert.Nil(t, l.Close())
+	}
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	n, err := VerifyAuditLog(f, auditKey)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestAuditRepository(t *testing.T) {
+	body := []byte(`{"name": "ght", "owner": "acme"}`)
+
+	assert.Equal(t, "octocat/ght", auditRepository("/user/repos", body, "octocat"))
+	assert.Equal(t, "acme/ght", auditRepository("/orgs/acme/repos", body, "octocat"))
+	assert.Equal(t, "acme/ght", auditRepository("/repos/acme/tmpl/generate", body, "octocat"))
+	assert.Equal(t, "acme/ght", auditRepository("/api/v3/repos/acme/ght/branches/main/protection", nil, "octocat"))
+	assert.Equal(t, "acme/ght", auditRepository("/orgs/acme/teams/devs/repos/acme/ght", nil, "octocat"))
+	assert.Equal(t, "", auditRepository("/orgs/acme/teams", nil, "octocat"))
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAuditLogWriteFailureKeepsTheResponse(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	audit := NewAuditLog(failingWriter{}, auditKey)
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()), WithAuditLog(audit))
+	assert.Nil(t, err)
+
+	// the writes are applied and reported as such, only the log fails
+	res, err := Run(rt, e2eOptions())
+	assert.Nil(t, err)
+	assert.True(t, res.Created)
+	assert.NotNil(t, srv.Repository("acme", "ght"))
+	assert.ErrorContains(t, audit.Err(), "disk full")
+
+	var none *AuditLog
+	assert.Nil(t, none.Err())
+}
@@ -0,0 +1,238 @@
+package ght
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// variable matches the ${name} placeholders of the template values
+var variable = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Autolink links the references such as JIRA-123 in the issues, pull requests
+// and commits of the repository to an external system
+type Autolink struct {
+	// KeyPrefix is the reference prefix, such as "${project}-". The variables
+	// are replaced by the values of RepoOptions.Vars, or the owner and the name
+	// of the repository.
+	KeyPrefix string `json:"key_prefix"`
+	// URLTemplate is the url of the reference, <num> is replaced by what follows the prefix
+	URLTemplate string `json:"url_template"`
+	// IsAlphanumeric allows letters after the prefix, true when empty
+	IsAlphanumeric *bool `json:"is_alphanumeric,omitempty"`
+}
+
+// autolinkState is what a step shows of an autolink
+type autolinkState struct {
+	KeyPrefix      string `json:"key_prefix"`
+	URLTemplate    string `json:"url_template"`
+	IsAlphanumeric bool   `json:"is_alphanumeric"`
+}
+
+// expand replaces the ${name} variables of s, failing on the unknown ones
+func expand(s string, opts *RepoOptions) (string, error) {
+	vars := map[string]string{"owner": opts.Owner, "name": opts.Name}
+	for k, v := range opts.Vars {
+		vars[k] = v
+	}
+
+	var unknown []string
+	res := variable.ReplaceAllStringFunc(s, func(m string) string {
+		name := variable.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok {
+			unknown = append(unknown, name)
+		}
+		return v
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("undefined variable %s in %q, set it using --var %s=value", unknown[0], s, unknown[0])
+	}
+
+	return res, nil
+}
+
+// state returns the autolink with its variables replaced
+func (a *Autolink) state(opts *RepoOptions) (*autolinkState, error) {
+	prefix, err := expand(a.KeyPrefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	url, err := expand(a.URLTemplate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == "" {
+		return nil, fmt.Errorf("autolink key_prefix is required")
+	}
+	if !strings.Contains(url, "<num>") {
+		return nil, fmt.Errorf("autolink %s url_template must contain <num>", prefix)
+	}
+
+	return &autolinkState{KeyPrefix: prefix, URLTemplate: url, IsAlphanumeric: a.IsAlphanumeric == nil || *a.IsAlphanumeric}, nil
+}
+
+// ListAutolinks fetches the autolink references of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/autolinks#list-all-autolinks-of-a-repository
+func (r *RepoTemplate) ListAutolinks(owner, repo string) ([]*github.Autolink, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching autolinks of %s/%s", owner, repo)
+
+	api, err := extension[AutolinksAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListAutolinks(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list autolinks of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// AddAutolink creates an autolink reference on a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/autolinks#create-an-autolink-reference-for-a-repository
+func (r *RepoTemplate) AddAutolink(owner, repo string, link *github.AutolinkOptions) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating autolink %s on %s/%s", link.GetKeyPrefix(), owner, repo)
+
+	api, err := extension[AutolinksAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.AddAutolink(ctx, owner, repo, link); err != nil {
+		return fmt.Errorf("failed to create autolink %s on %s/%s |→ %w", link.GetKeyPrefix(), owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeleteAutolink removes an autolink reference from a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/autolinks#delete-an-autolink-reference-from-a-repository
+func (r *RepoTemplate) DeleteAutolink(owner, repo string, link *github.Autolink) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("deleting autolink %s on %s/%s", link.GetKeyPrefix(), owner, repo)
+
+	api, err := extension[AutolinksAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteAutolink(ctx, owner, repo, link.GetID()); err != nil {
+		return fmt.Errorf("failed to delete autolink %s on %s/%s |→ %w", link.GetKeyPrefix(), owner, repo, err)
+	}
+
+	return nil
+}
+
+// Autolinks creates the autolinks of the template, matching the existing ones
+// by prefix, case insensitive as GitHub does. An autolink whose url or
+// alphanumeric flag changed is replaced, as GitHub can't edit them, and put
+// back when the new one can't be created. The autolinks not in the template
+// are left as they are.
+func (r *RepoTemplate) Autolinks(opts *RepoOptions, links []*Autolink, missing bool) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	// the autolinks of a repository that does not exist yet can not be read
+	var current []*github.Autolink
+	if !missing {
+		var err error
+		current, err = r.ListAutolinks(opts.Owner, opts.Name)
+		if err != nil {
+			return []StepResult{{Name: "autolinks", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	byPrefix := map[string]*github.Autolink{}
+	for _, l := range current {
+		byPrefix[strings.ToLower(l.GetKeyPrefix())] = l
+	}
+
+	managed := map[string]bool{}
+	for _, l := range links {
+		step := StepResult{Name: "autolinks:" + l.KeyPrefix}
+
+		err := r.autolink(opts, l, managed, byPrefix, &step)
+		steps = append(steps, step)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				break
+			}
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// autolink creates or replaces a single autolink, filling the step
+func (r *RepoTemplate) autolink(opts *RepoOptions, l *Autolink, managed map[string]bool, byPrefix map[string]*github.Autolink, step *StepResult) error {
+	desired, err := l.state(opts)
+	if err != nil {
+		*step = step.Fail(err)
+		return err
+	}
+	step.Name = "autolinks:" + desired.KeyPrefix
+	step.After = desired
+
+	prefix := strings.ToLower(desired.KeyPrefix)
+	if managed[prefix] {
+		err = fmt.Errorf("autolink %s is defined more than once", desired.KeyPrefix)
+		*step = step.Fail(err)
+		return err
+	}
+	managed[prefix] = true
+
+	existing := byPrefix[prefix]
+	if existing != nil {
+		step.Before = &autolinkState{KeyPrefix: existing.GetKeyPrefix(), URLTemplate: existing.GetURLTemplate(), IsAlphanumeric: existing.GetIsAlphanumeric()}
+	}
+	step.Action = action(existing == nil, existing != nil && changed(desired, step.Before))
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return nil
+	}
+
+	// GitHub refuses a prefix already in the repository, so the old autolink goes first
+	if existing != nil {
+		if err := r.DeleteAutolink(opts.Owner, opts.Name, existing); err != nil {
+			*step = step.Fail(err)
+			return err
+		}
+	}
+
+	err = r.AddAutolink(opts.Owner, opts.Name, &github.AutolinkOptions{
+		KeyPrefix:      github.String(desired.KeyPrefix),
+		URLTemplate:    github.String(desired.URLTemplate),
+		IsAlphanumeric: github.Bool(desired.IsAlphanumeric),
+	})
+	if err != nil && existing != nil {
+		// put the old autolink back, the step reports it lost when that fails too
+		restore := &github.AutolinkOptions{KeyPrefix: existing.KeyPrefix, URLTemplate: existing.URLTemplate, IsAlphanumeric: existing.IsAlphanumeric}
+		if rerr := r.AddAutolink(opts.Owner, opts.Name, restore); rerr != nil {
+			err = fmt.Errorf("autolink %s was deleted to be replaced and could not be restored |→ %w", existing.GetKeyPrefix(), errors.Join(err, rerr))
+			step.After = nil
+		}
+	}
+	if err != nil {
+		*step = step.Fail(err)
+	}
+
+	return err
+}
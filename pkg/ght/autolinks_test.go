@@ -0,0 +1,124 @@
+package ght
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutolinks(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddAutolink("acme", "ght", "TICKET-", "https://tickets.acme.io/<num>", true)
+	srv.AddAutolink("acme", "ght", "OLD-", "https://old.acme.io/<num>", true)
+	var ticket int64
+	for _, l := range srv.Autolinks("acme", "ght") {
+		if l.GetKeyPrefix() == "TICKET-" {
+			ticket = l.GetID()
+		}
+	}
+
+	links := []*Autolink{
+		{KeyPrefix: "${project}-", URLTemplate: "https://jira.acme.io/browse/${project}-<num>"},
+		{KeyPrefix: "TICKET-", URLTemplate: "https://tickets.acme.io/<num>", IsAlphanumeric: github.Bool(false)},
+		{KeyPrefix: "GH-", URLTemplate: "https://github.com/${owner}/${name}/issues/<num>", IsAlphanumeric: github.Bool(false)},
+	}
+	opts := writeTemplate(t, &Config{Autolinks: links})
+	opts.Vars = map[string]string{"project": "GHT"}
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps[1:] {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"autolinks:GHT-":    ActionCreated,
+		"autolinks:TICKET-": ActionUpdated,
+		"autolinks:GH-":     ActionCreated,
+	}, actions)
+
+	current := map[string]string{}
+	for _, l := range srv.Autolinks("acme", "ght") {
+		current[l.GetKeyPrefix()] = l.GetURLTemplate()
+	}
+	assert.Equal(t, map[string]string{
+		"GH-":     "https://github.com/acme/ght/issues/<num>",
+		"GHT-":    "https://jira.acme.io/browse/GHT-<num>",
+		"OLD-":    "https://old.acme.io/<num>",
+		"TICKET-": "https://tickets.acme.io/<num>",
+	}, current)
+
+	// an autolink can't be edited, the changed one is deleted then created again
+	assert.Equal(t, []string{
+		"POST /repos/acme/ght/autolinks",
+		fmt.Sprintf("DELETE /repos/acme/ght/autolinks/%d", ticket),
+		"POST /repos/acme/ght/autolinks",
+		"POST /repos/acme/ght/autolinks",
+	}, srv.Writes())
+	assert.Equal(t, []string{
+		`{"key_prefix":"GHT-","url_template":"https://jira.acme.io/browse/GHT-<num>","is_alphanumeric":true}`,
+		`{"key_prefix":"TICKET-","url_template":"https://tickets.acme.io/<num>","is_alphanumeric":false}`,
+		`{"key_prefix":"GH-","url_template":"https://github.com/acme/ght/issues/<num>","is_alphanumeric":false}`,
+	}, srv.Bodies("POST /repos/acme/ght/autolinks"))
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestAutolinksUndefinedVariable(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Autolinks: []*Autolink{
+		{KeyPrefix: "${project}-", URLTemplate: "https://jira.acme.io/browse/${project}-<num>"},
+	}})
+
+	res, err := Run(rt, opts)
+	assert.ErrorContains(t, err, "undefined variable project")
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Empty(t, srv.Autolinks("acme", "ght"))
+}
+
+func TestAutolinkState(t *testing.T) {
+	opts := &RepoOptions{Owner: "acme", Name: "ght"}
+
+	_, err := (&Autolink{KeyPrefix: "GH-", URLTemplate: "https://github.com/${owner}/${name}/issues"}).state(opts)
+	assert.ErrorContains(t, err, "must contain <num>")
+
+	_, err = (&Autolink{KeyPrefix: "${name}", URLTemplate: "https://acme.io/<num>"}).state(opts)
+	assert.Nil(t, err)
+
+	_, err = (&Autolink{URLTemplate: "https://acme.io/<num>"}).state(opts)
+	assert.NotNil(t, err)
+}
+
+func TestAutolinksReplaceFailure(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddAutolink("acme", "ght", "TICKET-", "https://tickets.acme.io/<num>", true)
+
+	opts := writeTemplate(t, &Config{Autolinks: []*Autolink{
+		{KeyPrefix: "TICKET-", URLTemplate: "https://jira.acme.io/browse/TICKET-<num>"},
+	}})
+
+	// the old autolink is put back
+	srv.Fail("POST /repos/acme/ght/autolinks", http.StatusInternalServerError)
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	links := srv.Autolinks("acme", "ght")
+	assert.Len(t, links, 1)
+	assert.Equal(t, "https://tickets.acme.io/<num>", links[0].GetURLTemplate())
+
+	// the step reports it lost when it can't be put back
+	srv.Fail("POST /repos/acme/ght/autolinks", http.StatusInternalServerError)
+	srv.Fail("POST /repos/acme/ght/autolinks", http.StatusInternalServerError)
+	res, err = Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, res.Steps[1].Error, "autolink TICKET- was deleted to be replaced and could not be restored")
+	assert.NotNil(t, res.Steps[1].Before)
+	assert.Nil(t, res.Steps[1].After)
+	assert.Empty(t, srv.Autolinks("acme", "ght"))
+}
@@ -0,0 +1,190 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Branch is a branch of the repository, created when it does not exist
+type Branch struct {
+	Name string `json:"name"`
+	// From is the branch, the tag or the commit sha the branch is created from,
+	// the default branch when empty
+	From string `json:"from,omitempty"`
+}
+
+// RenameBranch renames a branch, GitHub moves its protection rules and its
+// open pull requests, and redirects the old name to the new one.
+//
+// GitHub API docs: https://docs.github.com/en/rest/branches/branches#rename-a-branch
+func (r *RepoTemplate) RenameBranch(owner, repo, branch, name string) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("renaming branch %s to %s on %s/%s", branch, name, owner, repo)
+
+	api, err := extension[BranchesAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.RenameBranch(ctx, owner, repo, branch, name); err != nil {
+		return fmt.Errorf("failed to rename branch %s to %s on %s/%s |→ %w", branch, name, owner, repo, err)
+	}
+
+	return nil
+}
+
+// CreateBranch creates a branch from the commit of a branch, a tag or a sha.
+//
+// GitHub API docs: https://docs.github.com/en/rest/git/refs#create-a-reference
+func (r *RepoTemplate) CreateBranch(owner, repo, branch, from string) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating branch %s from %s on %s/%s", branch, from, owner, repo)
+
+	api, err := extension[BranchesAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	sha, err := api.GetCommitSHA1(ctx, owner, repo, from)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s on %s/%s |→ %w", from, owner, repo, err)
+	}
+
+	ref := &github.Reference{Ref: github.String("refs/heads/" + branch), Object: &github.GitObject{SHA: github.String(sha)}}
+	if err := api.CreateRef(ctx, owner, repo, ref); err != nil {
+		return fmt.Errorf("failed to create branch %s on %s/%s |→ %w", branch, owner, repo, err)
+	}
+
+	return nil
+}
+
+// ListBranches fetches the names of the branches of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/branches/branches#list-branches
+func (r *RepoTemplate) ListBranches(owner, repo string) ([]string, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching branches of %s/%s", owner, repo)
+
+	api, err := extension[BranchesAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListBranches(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches of %s/%s |→ %w", owner, repo, err)
+	}
+
+	var names []string
+	for _, b := range res {
+		names = append(names, b.GetName())
+	}
+
+	return names, nil
+}
+
+// Branches creates the missing branches of the template and sets the default
+// branch. When the default branch does not exist and is not one of the
+// branches, the current default branch is renamed, such as master to main,
+// keeping its history and protection rules.
+func (r *RepoTemplate) Branches(opts *RepoOptions, defaultBranch string, branches []*Branch, missing bool) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+
+	var (
+		steps []StepResult
+		errs  []error
+	)
+	// add appends the step and reports whether the section must stop
+	add := func(step StepResult, err error) bool {
+		if err != nil {
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		return err != nil && !opts.ContinueOnError
+	}
+
+	declared := map[string]bool{}
+	for _, b := range branches {
+		if b.Name == "" {
+			err := fmt.Errorf("branches name is required")
+			return []StepResult{{Name: "branches", Action: ActionFailed, Error: err.Error()}}, err
+		}
+		declared[b.Name] = true
+	}
+
+	// the branches of a repository that does not exist yet can not be read
+	current := ""
+	exists := map[string]bool{}
+	if !missing || !opts.Plan {
+		res, err := r.GetRepo(owner, repo)
+		if err == nil {
+			current = res.GetDefaultBranch()
+			var names []string
+			names, err = r.ListBranches(owner, repo)
+			for _, name := range names {
+				exists[name] = true
+			}
+		}
+		if err != nil {
+			return []StepResult{{Name: "branches", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	step := StepResult{Name: "default_branch", After: defaultBranch}
+	if current != "" {
+		step.Before = current
+	}
+
+	// the branches are created from the default branch, after it is renamed
+	base, renamed := current, false
+	if defaultBranch != "" && current != "" && current != defaultBranch && !exists[defaultBranch] && !declared[defaultBranch] {
+		renamed = true
+		step.Action = ActionUpdated
+
+		var err error
+		if !opts.Plan {
+			err = r.RenameBranch(owner, repo, current, defaultBranch)
+		}
+		if err == nil {
+			base = defaultBranch
+		}
+		if add(step, err) {
+			return steps, joinErrors(errs)
+		}
+	}
+
+	for _, b := range branches {
+		from := b.From
+		if from == "" {
+			from = base
+		}
+
+		branchStep := StepResult{Name: "branches:" + b.Name, After: &Branch{Name: b.Name, From: from}, Action: action(!exists[b.Name], false)}
+		var err error
+		if !exists[b.Name] && !opts.Plan {
+			err = r.CreateBranch(owner, repo, b.Name, from)
+		}
+		if add(branchStep, err) {
+			return steps, joinErrors(errs)
+		}
+	}
+
+	if defaultBranch == "" || renamed {
+		return steps, joinErrors(errs)
+	}
+
+	step.Action = action(current == "", current != defaultBranch)
+	var err error
+	if step.Action != ActionUnchanged && !opts.Plan {
+		err = r.EditRepo(owner, repo, &github.Repository{DefaultBranch: github.String(defaultBranch)})
+	}
+	add(step, err)
+
+	return steps, joinErrors(errs)
+}
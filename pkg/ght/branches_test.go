@@ -0,0 +1,114 @@
+package ght
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchesRenameDefaultBranch(t *testing.T) {
+	srv, rt := newTestServer(t)
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght"), DefaultBranch: github.String("master")})
+
+	opts := writeTemplate(t, &Config{
+		DefaultBranch:    "main",
+		Branches:         []*Branch{{Name: "develop"}, {Name: "release", From: "develop"}},
+		BranchProtection: &github.ProtectionRequest{EnforceAdmins: true},
+	})
+	opts.Branches = []string{"main", "develop"}
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	// the default branch is renamed, not created, so that its history and pull requests follow
+	assert.Equal(t, []string{`{"new_name":"main"}`}, srv.Bodies("POST /repos/acme/ght/branches/master/rename"))
+	assert.NotContains(t, srv.Writes(), "PATCH /repos/acme/ght")
+
+	// develop starts from the default branch and release from develop, both at the same commit
+	main, err := rt.GetBranch("acme", "ght", "main")
+	assert.Nil(t, err)
+	sha := main.GetCommit().GetSHA()
+	assert.Equal(t, []string{
+		fmt.Sprintf(`{"ref":"refs/heads/develop","sha":"%s"}`, sha),
+		fmt.Sprintf(`{"ref":"refs/heads/release","sha":"%s"}`, sha),
+	}, srv.Bodies("POST /repos/acme/ght/git/refs"))
+
+	var names []string
+	for _, step := range res.Steps[1:4] {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{"default_branch", "branches:develop", "branches:release"}, names)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, ActionCreated, res.Steps[2].Action)
+	assert.Equal(t, ActionCreated, res.Steps[3].Action)
+	assert.Equal(t, &Branch{Name: "develop", From: "main"}, res.Steps[2].After)
+
+	assert.Equal(t, "main", srv.Repository("acme", "ght").GetDefaultBranch())
+	assert.Equal(t, []string{"develop", "main", "release"}, srv.Branches("acme", "ght"))
+	assert.NotNil(t, srv.Protection("acme", "ght", "develop"))
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestBranchesSetDefaultBranch(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{DefaultBranch: "develop", Branches: []*Branch{{Name: "develop"}}})
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "branches:develop", res.Steps[1].Name)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, "default_branch", res.Steps[2].Name)
+	assert.Equal(t, ActionUpdated, res.Steps[2].Action)
+
+	assert.Equal(t, "develop", srv.Repository("acme", "ght").GetDefaultBranch())
+	assert.Equal(t, []string{"develop", "main"}, srv.Branches("acme", "ght"))
+}
+
+func TestBranchesUnknownRef(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	res, err := Run(rt, writeTemplate(t, &Config{Branches: []*Branch{{Name: "hotfix", From: "v9.9.9"}}}))
+	assert.ErrorContains(t, err, "failed to resolve v9.9.9")
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Equal(t, []string{"main"}, srv.Branches("acme", "ght"))
+}
+
+func TestBranchesPlanOnMissingRepo(t *testing.T) {
+	srv, rt := newTestServer(t)
+
+	opts := writeTemplate(t, &Config{
+		Repository:    &github.Repository{Name: github.String("ght"), AutoInit: github.Bool(true)},
+		DefaultBranch: "main",
+		Branches:      []*Branch{{Name: "develop"}},
+	})
+
+	res := plan(t, srv, rt, opts)
+	for _, step := range res.Steps {
+		assert.Equal(t, ActionCreated, step.Action, step.Name)
+	}
+	assert.Len(t, res.Steps, 3)
+}
+
+func TestBranchesEmptyRepoWithDefaultBranch(t *testing.T) {
+	srv, rt := newTestServer(t)
+
+	// without auto_init the repository has no branch yet, main is only its default
+	opts := writeTemplate(t, &Config{
+		Repository:    &github.Repository{Name: github.String("ght")},
+		DefaultBranch: "main",
+	})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "default_branch", res.Steps[1].Name)
+	assert.Equal(t, ActionUnchanged, res.Steps[1].Action)
+
+	assert.Equal(t, []string{"POST /orgs/acme/repos"}, srv.Writes())
+	assert.Empty(t, srv.Branches("acme", "ght"))
+	assert.Equal(t, "main", srv.Repository("acme", "ght").GetDefaultBranch())
+}
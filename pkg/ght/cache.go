@@ -0,0 +1,128 @@
+package ght
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache is an on-disk, content-addressed store of remote files
+type Cache struct {
+	dir string
+}
+
+// CacheEntry links a remote source to the digest of its last known content
+type CacheEntry struct {
+	Source    string    `json:"source"`
+	SHA256    string    `json:"sha256"`
+	ETag      string    `json:"etag,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// NewCache creates a cache in the directory, defaults to ght under the user cache dir.
+func NewCache(dir string) (*Cache, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the user cache dir, use the --cache-dir flag |→ %w", err)
+		}
+		dir = filepath.Join(base, "ght")
+	}
+
+	for _, d := range []string{"blobs", "entries"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create cache dir %s |→ %w", dir, err)
+		}
+	}
+
+	return &Cache{dir: dir}, nil
+}
+
+// Has reports whether the content with the digest is cached
+func (c *Cache) Has(sum string) bool {
+	_, err := os.Stat(c.blobPath(sum))
+	return err == nil
+}
+
+// Blob returns the cached content with the digest
+func (c *Cache) Blob(sum string) ([]byte, error) {
+	data, err := os.ReadFile(c.blobPath(sum))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached blob %s |→ %w", sum, err)
+	}
+
+	if Digest(data) != sum {
+		return nil, fmt.Errorf("failed to read cached blob %s |→ %w", sum, ErrIntegrityMismatch)
+	}
+
+	return data, nil
+}
+
+// Entry returns the cache entry of a source
+func (c *Cache) Entry(source string) (*CacheEntry, error) {
+	data, err := os.ReadFile(c.entryPath(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry of %s |→ %w", source, err)
+	}
+
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cache entry of %s |→ %w", source, err)
+	}
+
+	return entry, nil
+}
+
+// Put stores the content of a source along with its ETag
+func (c *Cache) Put(source, etag string, data []byte) error {
+	sum := Digest(data)
+	if err := writeFileAtomic(c.blobPath(sum), data); err != nil {
+		return err
+	}
+
+	entry, err := json.Marshal(&CacheEntry{
+		Source:    source,
+		SHA256:    sum,
+		ETag:      etag,
+		FetchedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry of %s |→ %w", source, err)
+	}
+
+	return writeFileAtomic(c.entryPath(source), entry)
+}
+
+func (c *Cache) blobPath(sum string) string {
+	return filepath.Join(c.dir, "blobs", filepath.Base(sum))
+}
+
+func (c *Cache) entryPath(source string) string {
+	return filepath.Join(c.dir, "entries", Digest([]byte(source))+".json")
+}
+
+// writeFileAtomic writes the file through a temporary file so readers never see partial content
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s |→ %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s |→ %w", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s |→ %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s |→ %w", path, err)
+	}
+
+	return nil
+}
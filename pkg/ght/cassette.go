@@ -0,0 +1,421 @@
+package ght
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RecordEnv is the env var that makes RunCassette record the cassette instead of replaying it
+const RecordEnv = "GHT_RECORD"
+
+// redacted replaces the secrets found in the recorded interactions
+const redacted = "REDACTED"
+
+var (
+	// ErrInteractionNotFound is returned in replay mode when no recorded interaction matches a request
+	ErrInteractionNotFound = errors.New("no recorded interaction matches the request")
+
+	// recordedHeaders are the headers kept in the cassettes, the others may carry secrets or vary between runs
+	recordedHeaders = []string{"Accept", "Content-Type", "ETag", "If-None-Match", "Link", "Location"}
+
+	// secretParams are the query parameters stripped from the recorded urls
+	secretParams = []string{"access_token", "token", "client_secret"}
+
+	// secretFields are the JSON body fields holding secrets, such as the webhook
+	// config.secret or the encrypted_value of the Actions and environment secrets
+	secretFields = map[string]bool{"secret": true, "encrypted_value": true}
+
+	// interactionFile matches the names of the files written by the cassette
+	interactionFile = regexp.MustCompile(`^[0-9]{4,}\.json$`)
+)
+
+// Interaction is a recorded HTTP request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the sanitized request of an interaction
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the sanitized response of an interaction
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Cassette records the HTTP interactions of a run into a directory, one JSON
+// file per interaction, or replays them without reaching the network.
+//
+// The Authorization header and the other headers that may carry credentials are
+// never recorded, the secret fields of the bodies are redacted, and so is the
+// GITHUB_TOKEN value wherever it appears.
+type Cassette struct {
+	dir    string
+	replay bool
+	secret string
+
+	mu           sync.Mutex
+	recorded     int
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewCassette creates the cassette set by the repo options, nil when neither
+// opts.Record nor opts.Replay is set.
+func NewCassette(opts *RepoOptions) (*Cassette, error) {
+	switch {
+	case opts.Record != "" && opts.Replay != "":
+		return nil, fmt.Errorf("record and replay modes can't be used together")
+	case opts.Record != "":
+		return RecordCassette(opts.Record)
+	case opts.Replay != "":
+		return ReplayCassette(opts.Replay)
+	default:
+		return nil, nil
+	}
+}
+
+// RecordCassette creates a cassette that records into the directory, replacing
+// the interactions recorded before. The directory must not hold any other file,
+// so that recording into a directory such as the one of the template fails
+// instead of deleting its files.
+func RecordCassette(dir string) (*Cassette, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cassette dir %s |→ %w", dir, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cassette dir %s |→ %w", dir, err)
+	}
+
+	var old []string
+	for _, e := range entries {
+		if e.IsDir() || !interactionFile.MatchString(e.Name()) {
+			return nil, fmt.Errorf("cassette dir %s holds %s, which was not recorded by ght, use an empty dir", dir, e.Name())
+		}
+		old = append(old, filepath.Join(dir, e.Name()))
+	}
+	for _, file := range old {
+		if err := os.Remove(file); err != nil {
+			return nil, fmt.Errorf("failed to clear cassette dir %s |→ %w", dir, err)
+		}
+	}
+
+	return &Cassette{dir: dir, secret: os.Getenv("GITHUB_TOKEN")}, nil
+}
+
+// ReplayCassette creates a cassette that replays the interactions recorded in the directory
+func ReplayCassette(dir string) (*Cassette, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cassette dir %s |→ %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no interactions recorded in %s", dir)
+	}
+	sort.Strings(files)
+
+	c := &Cassette{dir: dir, replay: true}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read interaction %s |→ %w", file, err)
+		}
+
+		i := &Interaction{}
+		if err := json.Unmarshal(data, i); err != nil {
+			return nil, fmt.Errorf("failed to parse interaction %s |→ %w", file, err)
+		}
+		c.interactions = append(c.interactions, i)
+	}
+	c.used = make([]bool, len(c.interactions))
+
+	return c, nil
+}
+
+// Replaying reports whether the cassette replays the interactions, nil-safe
+func (c *Cassette) Replaying() bool {
+	return c != nil && c.replay
+}
+
+// Wrap returns a transport that records the requests sent through next, or
+// replays them, in which case next is never used.
+func (c *Cassette) Wrap(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &cassetteTransport{cassette: c, next: next}
+}
+
+type cassetteTransport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cassette.replay {
+		return t.cassette.play(req, body)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	if err := t.cassette.record(req, body, res, resBody); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// readBody reads the request body, leaving it readable for the next transport
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// record stores the sanitized interaction in the next file of the directory
+func (c *Cassette) record(req *http.Request, body []byte, res *http.Response, resBody []byte) error {
+	i := &Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     c.redact(sanitizeURL(req.URL)),
+			Headers: c.headers(req.Header),
+			Body:    c.redact(redactFields(body)),
+		},
+		Response: RecordedResponse{
+			Status:  res.StatusCode,
+			Headers: c.headers(res.Header),
+			Body:    c.redact(redactFields(resBody)),
+		},
+	}
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.recorded++
+	file := filepath.Join(c.dir, fmt.Sprintf("%04d.json", c.recorded))
+	if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write interaction %s |→ %w", file, err)
+	}
+
+	return nil
+}
+
+// play responds with the first interaction not replayed yet that matches the request
+func (c *Cassette) play(req *http.Request, body []byte) (*http.Response, error) {
+	u := sanitizeURL(req.URL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n, i := range c.interactions {
+		if c.used[n] || i.Request.Method != req.Method || !sameRequest(i.Request, u, []byte(redactFields(body))) {
+			continue
+		}
+		c.used[n] = true
+
+		headers := http.Header{}
+		for k, v := range i.Response.Headers {
+			headers[k] = v
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.Status, http.StatusText(i.Response.Status)),
+			StatusCode:    i.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        headers,
+			Body:          io.NopCloser(strings.NewReader(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, u)
+}
+
+// sameRequest reports whether the recorded request has the same path, query and
+// body, the host is ignored so that a cassette can be replayed against any API url
+func sameRequest(r RecordedRequest, u string, body []byte) bool {
+	recorded, err := url.Parse(r.URL)
+	if err != nil {
+		return false
+	}
+	current, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	if recorded.Path != current.Path || recorded.Query().Encode() != current.Query().Encode() {
+		return false
+	}
+
+	if r.Body == string(body) {
+		return true
+	}
+
+	// JSON bodies are compared by value, the recorded ones may have been redacted or reformatted
+	var a, b interface{}
+	return json.Unmarshal([]byte(r.Body), &a) == nil && json.Unmarshal(body, &b) == nil && !changed(a, b) && !changed(b, a)
+}
+
+// headers returns the headers that can be recorded
+func (c *Cassette) headers(h http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range recordedHeaders {
+		for _, v := range h.Values(name) {
+			kept.Add(name, c.redact(v))
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+
+	return kept
+}
+
+// redact replaces the token wherever it appears
+func (c *Cassette) redact(s string) string {
+	if c.secret == "" {
+		return s
+	}
+
+	return strings.ReplaceAll(s, c.secret, redacted)
+}
+
+// redactFields replaces the secret fields of a JSON body, the body is kept as is when it has none
+func redactFields(body []byte) string {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil || !redactValue(v) {
+		return string(body)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+
+	return string(data)
+}
+
+// redactValue replaces the secret fields found in the decoded JSON value, reporting whether there was any
+func redactValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, f := range v {
+			if _, ok := f.(string); ok && secretFields[k] {
+				v[k] = redacted
+				found = true
+			} else if redactValue(f) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, f := range v {
+			if redactValue(f) {
+				found = true
+			}
+		}
+	}
+
+	return found
+}
+
+// sanitizeURL returns the url without credentials
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+
+	query := clean.Query()
+	for _, p := range secretParams {
+		query.Del(p)
+	}
+	clean.RawQuery = query.Encode()
+
+	return clean.String()
+}
+
+// RunCassette applies the template replaying the HTTP interactions recorded in
+// dir, so that a real run can be checked again in CI without network. When the
+// GHT_RECORD env var is set, the run reaches GitHub and the interactions are
+// recorded instead.
+func RunCassette(dir string, opts *RepoOptions, options ...Option) (*RepoResponse, error) {
+	var c *Cassette
+	var err error
+	if os.Getenv(RecordEnv) != "" {
+		c, err = RecordCassette(dir)
+	} else {
+		c, err = ReplayCassette(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options, WithCassette(c))
+
+	fetcher, err := NewFetcher(opts, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	rt, err := NewRepoTemplate(append(options, WithFetcher(fetcher))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Run(rt, opts)
+}
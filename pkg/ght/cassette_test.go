@@ -0,0 +1,181 @@
+package ght
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func cassetteOptions() *RepoOptions {
+	return &RepoOptions{
+		Owner:    "acme",
+		Name:     "ght",
+		Template: "./testdata/full-repo.json",
+		Topics:   []string{"go"},
+		Branches: []string{"main"},
+	}
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	srv := ghtest.NewServer()
+	srv.AddOrg("acme")
+
+	dir := t.TempDir()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	t.Setenv(RecordEnv, "1")
+
+	recorded, err := RunCassette(dir, cassetteOptions())
+	assert.Nil(t, err)
+	assert.True(t, recorded.Created)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		assert.False(t, strings.Contains(string(data), "ghp_secret"), file)
+		assert.False(t, strings.Contains(string(data), "Authorization"), file)
+	}
+
+	// the replay must not reach the network
+	srv.Close()
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv(RecordEnv, "")
+
+	replayed, err := RunCassette(dir, cassetteOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, recorded.Steps, replayed.Steps)
+}
+
+func TestCassetteReplay(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+
+	res, err := RunCassette("./testdata/cassettes/create-repo", cassetteOptions())
+	assert.Nil(t, err)
+	assert.True(t, res.Created)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, ActionCreated, actions["repository"])
+	assert.Equal(t, ActionCreated, actions[PullRequestTemplate])
+	assert.Equal(t, ActionCreated, actions["branch_protection:main"])
+}
+
+func TestCassetteReplayUnknownRequest(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+
+	opts := cassetteOptions()
+	opts.Name = "other"
+
+	res, err := RunCassette("./testdata/cassettes/create-repo", opts)
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+	assert.Equal(t, ActionFailed, res.Steps[0].Action)
+}
+
+func TestCassetteReplayEmptyDir(t *testing.T) {
+	_, err := ReplayCassette(t.TempDir())
+	assert.NotNil(t, err)
+}
+
+func TestNewCassetteRecordAndReplay(t *testing.T) {
+	_, err := NewCassette(&RepoOptions{Record: "a", Replay: "b"})
+	assert.NotNil(t, err)
+
+	c, err := NewCassette(&RepoOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, c)
+}
+
+func TestRecordCassetteKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "template.json")
+	assert.Nil(t, os.WriteFile(template, []byte("{}"), 0o600))
+
+	_, err := RecordCassette(dir)
+	assert.ErrorContains(t, err, "holds template.json, which was not recorded by ght")
+
+	_, err = os.Stat(template)
+	assert.Nil(t, err)
+}
+
+func TestRecordCassetteReplacesInteractions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0001.json", "0002.json"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600))
+	}
+
+	_, err := RecordCassette(dir)
+	assert.Nil(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestCassetteRedactsSecretFields(t *testing.T) {
+	srv := ghtest.NewServer()
+	srv.AddOrg("acme")
+
+	dir := t.TempDir()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+	t.Setenv("DEPLOY_HOOK_SECRET", "hook-s3cr3t")
+	t.Setenv("SONAR_TOKEN_VALUE", "sonar-s3cr3t")
+	t.Setenv(RecordEnv, "1")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+
+	opts := writeTemplate(t, &Config{
+		Webhooks: []*Webhook{{URL: "https://deploy.acme.io/hook", SecretEnv: "DEPLOY_HOOK_SECRET"}},
+		Actions:  &ActionsConfig{Secrets: map[string]*SecretSource{"SONAR_TOKEN": {Env: "SONAR_TOKEN_VALUE"}}},
+		Environments: map[string]*Environment{
+			"production": {Secrets: map[string]*SecretSource{"DEPLOY_KEY": {Env: "SONAR_TOKEN_VALUE"}}},
+		},
+	})
+
+	recorded, err := RunCassette(dir, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "hook-s3cr3t", srv.HookSecret("acme", "ght", "https://deploy.acme.io/hook"))
+
+	bodies := map[string]string{}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		assert.NotContains(t, string(data), "hook-s3cr3t", file)
+
+		i := &Interaction{}
+		assert.Nil(t, json.Unmarshal(data, i))
+		bodies[i.Request.Method+" "+strings.TrimPrefix(i.Request.URL, srv.URL)] = i.Request.Body
+	}
+
+	for _, req := range []string{
+		"POST /repos/acme/ght/hooks",
+		"PUT /repos/acme/ght/actions/secrets/SONAR_TOKEN",
+	} {
+		assert.Contains(t, bodies, req)
+		assert.Contains(t, bodies[req], `"REDACTED"`, req)
+	}
+	var redactedEnvSecret bool
+	for req, body := range bodies {
+		if strings.HasSuffix(req, "/environments/production/secrets/DEPLOY_KEY") {
+			redactedEnvSecret = strings.Contains(body, `"encrypted_value":"REDACTED"`)
+		}
+	}
+	assert.True(t, redactedEnvSecret)
+
+	// the redacted requests still match when replayed
+	srv.Close()
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv(RecordEnv, "")
+
+	replayed, err := RunCassette(dir, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, recorded.Steps, replayed.Steps)
+}
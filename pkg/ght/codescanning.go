@@ -0,0 +1,212 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Code scanning default setup states
+const (
+	CodeScanningConfigured    = "configured"
+	CodeScanningNotConfigured = "not-configured"
+)
+
+// codeQLLanguages maps the languages detected by GitHub to the languages of the CodeQL default setup
+var codeQLLanguages = map[string]string{
+	"C":          "c-cpp",
+	"C++":        "c-cpp",
+	"C#":         "csharp",
+	"Go":         "go",
+	"Java":       "java-kotlin",
+	"Kotlin":     "java-kotlin",
+	"JavaScript": "javascript-typescript",
+	"TypeScript": "javascript-typescript",
+	"Python":     "python",
+	"Ruby":       "ruby",
+	"Swift":      "swift",
+}
+
+// CodeScanningSetup is the CodeQL default setup of the repository
+type CodeScanningSetup struct {
+	// State is configured or not-configured
+	State string `json:"state"`
+	// QuerySuite is default or extended, default when empty
+	QuerySuite string `json:"query_suite,omitempty"`
+	// Languages are CodeQL languages, such as go or javascript-typescript. When
+	// empty, the languages of the repository supported by CodeQL are scanned.
+	Languages []string `json:"languages,omitempty"`
+}
+
+// validate checks the state, the query suite and the languages of the setup
+func (c *CodeScanningSetup) validate() error {
+	if c.State != CodeScanningConfigured && c.State != CodeScanningNotConfigured {
+		return fmt.Errorf("code_scanning state must be %s or %s", CodeScanningConfigured, CodeScanningNotConfigured)
+	}
+	if c.QuerySuite != "" && c.QuerySuite != "default" && c.QuerySuite != "extended" {
+		return fmt.Errorf("code_scanning query_suite must be default or extended")
+	}
+
+	supported := map[string]bool{}
+	for _, l := range codeQLLanguages {
+		supported[l] = true
+	}
+	for _, l := range c.Languages {
+		if !supported[l] {
+			return fmt.Errorf("code_scanning language %s is not supported by CodeQL", l)
+		}
+	}
+
+	return nil
+}
+
+// detectLanguages returns the CodeQL languages of the repository languages
+// and the languages CodeQL does not support, both sorted
+func detectLanguages(languages map[string]int) ([]string, []string) {
+	found := map[string]bool{}
+	var supported, unsupported []string
+	for language := range languages {
+		l, ok := codeQLLanguages[language]
+		switch {
+		case !ok:
+			unsupported = append(unsupported, language)
+		case !found[l]:
+			found[l] = true
+			supported = append(supported, l)
+		}
+	}
+	sort.Strings(supported)
+	sort.Strings(unsupported)
+
+	return supported, unsupported
+}
+
+// GetCodeScanningDefaultSetup fetches the CodeQL default setup of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/code-scanning/code-scanning#get-a-code-scanning-default-setup-configuration
+func (r *RepoTemplate) GetCodeScanningDefaultSetup(owner, repo string) (*CodeScanningSetup, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching code scanning default setup of %s/%s", owner, repo)
+
+	api, err := extension[CodeScanningAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.GetCodeScanningDefaultSetup(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch code scanning default setup of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// UpdateCodeScanningDefaultSetup configures the CodeQL default setup of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/code-scanning/code-scanning#update-a-code-scanning-default-setup-configuration
+func (r *RepoTemplate) UpdateCodeScanningDefaultSetup(owner, repo string, setup *CodeScanningSetup) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting code scanning default setup of %s/%s to %s", owner, repo, setup.State)
+
+	api, err := extension[CodeScanningAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.UpdateCodeScanningDefaultSetup(ctx, owner, repo, setup); err != nil {
+		return fmt.Errorf("failed to set code scanning default setup of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// ListLanguages fetches the languages of a repository, with their number of bytes.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/repos#list-repository-languages
+func (r *RepoTemplate) ListLanguages(owner, repo string) (map[string]int, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching languages of %s/%s", owner, repo)
+
+	api, err := extension[CodeScanningAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListLanguages(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list languages of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CodeScanning configures the CodeQL default setup of the repository. When no
+// language is set, the languages of the repository supported by CodeQL are
+// scanned, and the step is skipped when there is none, as GitHub would refuse it.
+func (r *RepoTemplate) CodeScanning(opts *RepoOptions, setup *CodeScanningSetup, missing bool) (StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	step := StepResult{Name: "code_scanning", After: setup}
+
+	if err := setup.validate(); err != nil {
+		return step, err
+	}
+
+	desired := &CodeScanningSetup{State: setup.State}
+	if setup.State == CodeScanningConfigured {
+		desired.QuerySuite = setup.QuerySuite
+		if desired.QuerySuite == "" {
+			desired.QuerySuite = "default"
+		}
+		desired.Languages = append([]string{}, setup.Languages...)
+		sort.Strings(desired.Languages)
+	}
+
+	// the languages and the setup of a repository that does not exist yet can not be read
+	current := &CodeScanningSetup{State: CodeScanningNotConfigured}
+	var languages map[string]int
+	if !missing || !opts.Plan {
+		var err error
+		if current, err = r.GetCodeScanningDefaultSetup(owner, repo); err != nil {
+			return step, err
+		}
+		sort.Strings(current.Languages)
+		step.Before = current
+
+		if desired.State == CodeScanningConfigured && len(desired.Languages) == 0 {
+			if languages, err = r.ListLanguages(owner, repo); err != nil {
+				return step, err
+			}
+		}
+	}
+
+	if desired.State == CodeScanningConfigured && len(desired.Languages) == 0 {
+		supported, unsupported := detectLanguages(languages)
+		if len(supported) == 0 {
+			step.Action = ActionSkipped
+			step.Error = fmt.Sprintf("no language of %s/%s is supported by CodeQL", owner, repo)
+			if len(unsupported) > 0 {
+				step.Error += ": " + strings.Join(unsupported, ", ")
+			}
+			return step, nil
+		}
+		desired.Languages = supported
+	}
+	step.After = desired
+
+	// only the state is compared when the setup is not configured
+	if desired.State == CodeScanningNotConfigured {
+		step.Action = action(false, current.State != desired.State)
+	} else {
+		step.Action = action(false, changed(desired, current))
+	}
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return step, nil
+	}
+
+	return step, r.UpdateCodeScanningDefaultSetup(owner, repo, desired)
+}
@@ -0,0 +1,68 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeScanning(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.SetLanguages("acme", "ght", map[string]int{"Go": 5000, "TypeScript": 300, "HCL": 20})
+
+	opts := writeTemplate(t, &Config{CodeScanning: &CodeScanningSetup{State: CodeScanningConfigured, QuerySuite: "extended"}})
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+
+	state, suite, languages := srv.CodeScanning("acme", "ght")
+	assert.Equal(t, CodeScanningConfigured, state)
+	assert.Equal(t, "extended", suite)
+	assert.Equal(t, []string{"go", "javascript-typescript"}, languages)
+
+	assertNoOp(t, srv, rt, opts)
+
+	opts = writeTemplate(t, &Config{CodeScanning: &CodeScanningSetup{State: CodeScanningNotConfigured}})
+	res, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	state, _, _ = srv.CodeScanning("acme", "ght")
+	assert.Equal(t, CodeScanningNotConfigured, state)
+}
+
+func TestCodeScanningExplicitLanguages(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.SetLanguages("acme", "ght", map[string]int{"Go": 5000})
+
+	// the languages of the template are scanned as they are, those of the repository are not read
+	opts := writeTemplate(t, &Config{CodeScanning: &CodeScanningSetup{State: CodeScanningConfigured, Languages: []string{"python", "go"}}})
+	srv.Reset()
+	_, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	assert.NotContains(t, srv.Requests(), "GET /repos/acme/ght/languages")
+	assert.Equal(t, []string{`{"state":"configured","query_suite":"default","languages":["go","python"]}`}, srv.Bodies("PATCH /repos/acme/ght/code-scanning/default-setup"))
+	_, _, languages := srv.CodeScanning("acme", "ght")
+	assert.Equal(t, []string{"go", "python"}, languages)
+
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestCodeScanningUnsupportedLanguages(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.SetLanguages("acme", "ght", map[string]int{"Shell": 100, "HCL": 20})
+
+	opts := writeTemplate(t, &Config{CodeScanning: &CodeScanningSetup{State: CodeScanningConfigured}})
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionSkipped, res.Steps[1].Action)
+	assert.Equal(t, "no language of acme/ght is supported by CodeQL: HCL, Shell", res.Steps[1].Error)
+	assert.Empty(t, srv.Writes())
+}
+
+func TestCodeScanningSetupValidate(t *testing.T) {
+	assert.NotNil(t, (&CodeScanningSetup{}).validate())
+	assert.NotNil(t, (&CodeScanningSetup{State: CodeScanningConfigured, QuerySuite: "all"}).validate())
+	assert.NotNil(t, (&CodeScanningSetup{State: CodeScanningConfigured, Languages: []string{"cobol"}}).validate())
+	assert.Nil(t, (&CodeScanningSetup{State: CodeScanningConfigured, Languages: []string{"go"}}).validate())
+}
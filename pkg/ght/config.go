@@ -0,0 +1,85 @@
+package ght
+
+import (
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// RepoOptions is the options for creating a new repository
+type RepoOptions struct {
+	Name            string
+	Owner           string
+	Description     string
+	Topics          []string
+	Branches        []string
+	Template        string
+	Debug           bool
+	Timeout         time.Duration
+	CABundle        string
+	Proxy           string
+	CacheDir        string
+	Offline         bool
+	Lockfile        string
+	Output          string
+	Plan            bool
+	ContinueOnError bool
+	Record          string
+	Replay          string
+	Snapshot        string
+	AuditLog        string
+	// AllowRemoteSecretSources lets a remote template read secrets from local files and commands
+	AllowRemoteSecretSources bool
+	// Vars are the values of the ${name} variables of the template
+	Vars map[string]string
+}
+
+// Config is the configuration for the repository
+type Config struct {
+	Repository            *github.Repository          `json:"repository"`
+	BranchProtection      *github.ProtectionRequest   `json:"branch_protection"`
+	TemplateRepo          *github.TemplateRepoRequest `json:"template_repo"`
+	RequiredSignedCommits bool                        `json:"required_signed_commits"`
+	PullRequestTemplate   string                      `json:"pull_request_template"`
+	IssueTemplate         string                      `json:"issue_template"`
+	// MergeQueue are the merge queue parameters of the protected branches, by branch
+	MergeQueue map[string]*MergeQueue `json:"merge_queue"`
+	// StatusChecks verifies the required status checks against the checks reported on the default branch
+	StatusChecks *StatusChecks `json:"status_checks"`
+	// DefaultBranch is the default branch, the current one is renamed when no branch has its name
+	DefaultBranch string `json:"default_branch"`
+	// Branches are created when missing, before the protection rules are applied
+	Branches []*Branch  `json:"branches"`
+	Webhooks []*Webhook `json:"webhooks"`
+	// RemoveUnmanagedWebhooks deletes the webhooks whose url is not in Webhooks
+	RemoveUnmanagedWebhooks bool         `json:"remove_unmanaged_webhooks"`
+	DeployKeys              []*DeployKey `json:"deploy_keys"`
+	// RemoveUnmanagedDeployKeys deletes the deploy keys whose fingerprint is not in DeployKeys
+	RemoveUnmanagedDeployKeys bool `json:"remove_unmanaged_deploy_keys"`
+	// Autolinks are the autolink references, matched by prefix
+	Autolinks []*Autolink  `json:"autolinks"`
+	Pages     *PagesConfig `json:"pages"`
+	// TagProtection protects the release tags from being deleted or moved
+	TagProtection *TagProtection   `json:"tag_protection"`
+	Releases      *ReleaseSettings `json:"releases"`
+	Actions       *ActionsConfig   `json:"actions"`
+	// Environments are the deployment environments, by name
+	Environments map[string]*Environment `json:"environments"`
+	Security     *SecurityConfig         `json:"security"`
+	CodeScanning *CodeScanningSetup      `json:"code_scanning"`
+}
+
+// Sources returns the files referenced by the configuration
+func (c *Config) Sources() []string {
+	var sources []string
+	for _, s := range []string{c.PullRequestTemplate, c.IssueTemplate} {
+		if s != "" {
+			sources = append(sources, s)
+		}
+	}
+	for _, k := range c.DeployKeys {
+		sources = append(sources, k.Key)
+	}
+
+	return sources
+}
@@ -0,0 +1,422 @@
+package ght
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultFetchTimeout is the maximum time spent fetching a remote file
+	DefaultFetchTimeout = 30 * time.Second
+	// DefaultMaxFetchSize is the maximum size, in bytes, of a remote file
+	DefaultMaxFetchSize = 10 << 20
+	// DefaultGitHubAPIURL is the GitHub API used to resolve github:// sources
+	DefaultGitHubAPIURL = "https://api.github.com/"
+)
+
+var (
+	// ErrFetchTooLarge is returned when a remote file exceeds the size limit
+	ErrFetchTooLarge = errors.New("remote file exceeds the size limit")
+	// ErrInvalidGitHubSource is returned when a github:// source is malformed
+	ErrInvalidGitHubSource = errors.New("invalid github source, expected github://owner/repo/path[@ref]")
+	// ErrIntegrityMismatch is returned when the content does not match the pinned digest
+	ErrIntegrityMismatch = errors.New("integrity check failed")
+	// ErrNotCached is returned in offline mode when a remote file is not in the cache
+	ErrNotCached = errors.New("remote file is not cached and offline mode is enabled")
+
+	// DefaultFetcher is used by Data to retrieve remote files
+	DefaultFetcher = &Fetcher{
+		client:      &http.Client{Timeout: DefaultFetchTimeout},
+		apiURL:      DefaultGitHubAPIURL,
+		maxSize:     DefaultMaxFetchSize,
+		githubHosts: defaultGitHubHosts(),
+		logger:      zerolog.Nop(),
+	}
+)
+
+// Fetcher retrieves the content of local or remote files
+type Fetcher struct {
+	client      *http.Client
+	apiURL      string
+	token       string
+	maxSize     int64
+	githubHosts map[string]bool
+	cache       *Cache
+	offline     bool
+	pins        map[string]string
+	logger      zerolog.Logger
+
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+// NewFetcher creates a new Fetcher according to the repo options, only WithLogger
+// and WithCassette apply. The cache is disabled when a cassette is used, so that
+// every request is recorded or replayed.
+func NewFetcher(opts *RepoOptions, options ...Option) (*Fetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url %s |→ %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.CABundle != "" {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca bundle %s |→ %w", opts.CABundle, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca bundle %s", opts.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPIURL
+	}
+
+	o := newOptions(options)
+
+	var client http.RoundTripper = transport
+	var cache *Cache
+	if o.cassette != nil {
+		client = o.cassette.Wrap(transport)
+	} else {
+		c, err := NewCache(opts.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		cache = c
+	}
+
+	var pins map[string]string
+	if opts.Lockfile != "" {
+		lock, err := ReadLockfile(opts.Lockfile)
+		if err != nil {
+			return nil, err
+		}
+		pins = lock.Pins()
+	}
+
+	return &Fetcher{
+		client:      &http.Client{Timeout: timeout, Transport: client},
+		apiURL:      apiURL,
+		token:       os.Getenv("GITHUB_TOKEN"),
+		maxSize:     DefaultMaxFetchSize,
+		githubHosts: defaultGitHubHosts(),
+		cache:       cache,
+		offline:     opts.Offline,
+		pins:        pins,
+		logger:      o.logger,
+	}, nil
+}
+
+// defaultGitHubHosts returns the hosts that receive the GitHub token
+func defaultGitHubHosts() map[string]bool {
+	return map[string]bool{
+		"github.com":                true,
+		"api.github.com":            true,
+		"raw.githubusercontent.com": true,
+	}
+}
+
+// GitHubSource is a file stored in a GitHub repository at a given ref
+type GitHubSource struct {
+	Owner string
+	Repo  string
+	Path  string
+	Ref   string
+}
+
+// ParseGitHubSource parses sources such as github://owner/repo/path@ref or
+// gh:owner/repo/path@ref. The ref is optional and can be a branch, a tag or
+// a commit SHA; the default branch is used when it is omitted.
+func ParseGitHubSource(source string) (*GitHubSource, bool, error) {
+	var rest string
+	switch {
+	case strings.HasPrefix(source, "github://"):
+		rest = strings.TrimPrefix(source, "github://")
+	case strings.HasPrefix(source, "gh:"):
+		rest = strings.TrimPrefix(source, "gh:")
+	default:
+		return nil, false, nil
+	}
+
+	src := &GitHubSource{}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		src.Ref = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || strings.Trim(parts[2], "/") == "" {
+		return nil, true, fmt.Errorf("failed to parse %s |→ %w", source, ErrInvalidGitHubSource)
+	}
+
+	src.Owner, src.Repo, src.Path = parts[0], parts[1], strings.Trim(parts[2], "/")
+
+	return src, true, nil
+}
+
+// String returns the canonical github:// form of the source
+func (s *GitHubSource) String() string {
+	if s.Ref == "" {
+		return fmt.Sprintf("github://%s/%s/%s", s.Owner, s.Repo, s.Path)
+	}
+
+	return fmt.Sprintf("github://%s/%s/%s@%s", s.Owner, s.Repo, s.Path, s.Ref)
+}
+
+// IsRemote reports whether the source is fetched from GitHub or a url rather than read from disk
+func IsRemote(source string) bool {
+	_, ok, _ := ParseGitHubSource(source)
+	return ok || strings.HasPrefix(source, "https://")
+}
+
+// Data returns the data from a file or url
+func Data(path string) ([]byte, error) {
+	return DefaultFetcher.Data(path)
+}
+
+// Data returns the data from a file or url. A #sha256=<hex> suffix pins the
+// expected content and the fetch fails when the digest does not match.
+func (f *Fetcher) Data(path string) ([]byte, error) {
+	source, pin := SplitPin(path)
+	if pin == "" {
+		pin = f.pins[source]
+	}
+
+	data, err := f.data(source, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := Digest(data)
+	if pin != "" && !strings.EqualFold(pin, sum) {
+		return nil, fmt.Errorf("failed to verify %s, expected sha256 %s but got %s |→ %w", source, pin, sum, ErrIntegrityMismatch)
+	}
+
+	f.record(source, sum)
+
+	return data, nil
+}
+
+// data returns the unverified content of a source
+func (f *Fetcher) data(source, pin string) ([]byte, error) {
+	src, ok, err := ParseGitHubSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		req, err := f.contentsRequest(src)
+		if err != nil {
+			return nil, err
+		}
+		return f.remote(req, src.String(), pin)
+	}
+
+	if strings.HasPrefix(source, "https://") {
+		req, err := f.getRequest(source)
+		if err != nil {
+			return nil, err
+		}
+		return f.remote(req, source, pin)
+	}
+
+	file, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s |→ %w", source, err)
+	}
+
+	return file, nil
+}
+
+// getRequest builds the request of a remote file, sending the token only to GitHub hosts
+func (f *Fetcher) getRequest(path string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get url %s |→ %w", path, err)
+	}
+
+	if f.token != "" && f.githubHosts[req.URL.Hostname()] {
+		req.Header.Set("Authorization", "token "+f.token)
+	}
+
+	return req, nil
+}
+
+// contentsRequest builds the request of a file fetched through the authenticated Contents API.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/contents#get-repository-content
+func (f *Fetcher) contentsRequest(src *GitHubSource) (*http.Request, error) {
+	segments := strings.Split(src.Path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	u := fmt.Sprintf("%s/repos/%s/%s/contents/%s",
+		strings.TrimSuffix(f.apiURL, "/"), url.PathEscape(src.Owner), url.PathEscape(src.Repo), strings.Join(segments, "/"))
+	if src.Ref != "" {
+		u += "?ref=" + url.QueryEscape(src.Ref)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s |→ %w", src, err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if f.token != "" {
+		req.Header.Set("Authorization", "token "+f.token)
+	}
+
+	return req, nil
+}
+
+// remote fetches a remote source, going through the cache when it is enabled
+func (f *Fetcher) remote(req *http.Request, source, pin string) ([]byte, error) {
+	if f.cache == nil {
+		if f.offline {
+			return nil, fmt.Errorf("failed to get url %s |→ %w", source, ErrNotCached)
+		}
+
+		data, _, _, err := f.do(req, source)
+		return data, err
+	}
+
+	// content-addressed entries never change, so a pinned source needs no request
+	if pin != "" {
+		if data, err := f.cache.Blob(pin); err == nil {
+			return data, nil
+		}
+	}
+
+	entry, _ := f.cache.Entry(source)
+	if f.offline {
+		if entry == nil {
+			return nil, fmt.Errorf("failed to get url %s |→ %w", source, ErrNotCached)
+		}
+		return f.cache.Blob(entry.SHA256)
+	}
+
+	if entry != nil && entry.ETag != "" && f.cache.Has(entry.SHA256) {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	data, etag, notModified, err := f.do(req, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if notModified {
+		f.logger.Debug().Msgf("using cached %s", source)
+		return f.cache.Blob(entry.SHA256)
+	}
+
+	if err := f.cache.Put(source, etag, data); err != nil {
+		f.logger.Warn().Err(err).Msgf("failed to cache %s", source)
+	}
+
+	return data, nil
+}
+
+// do sends the request and reads the response body within the size limit
+func (f *Fetcher) do(req *http.Request, path string) ([]byte, string, bool, error) {
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to get url %s |→ %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match") != "" {
+		return nil, resp.Header.Get("ETag"), true, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", false, fmt.Errorf("failed to get url %s |→ unexpected status code: %s", path, resp.Status)
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, "", false, fmt.Errorf("failed to get url %s |→ %w", path, ErrFetchTooLarge)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to read body from url %s |→ %w", path, err)
+	}
+
+	if int64(len(body)) > f.maxSize {
+		return nil, "", false, fmt.Errorf("failed to get url %s |→ %w", path, ErrFetchTooLarge)
+	}
+
+	return body, resp.Header.Get("ETag"), false, nil
+}
+
+// record keeps the digest of a resolved source
+func (f *Fetcher) record(source, sum string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.resolved == nil {
+		f.resolved = map[string]string{}
+	}
+	f.resolved[source] = sum
+}
+
+// Resolved returns the digest of every source fetched so far
+func (f *Fetcher) Resolved() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := make(map[string]string, len(f.resolved))
+	for source, sum := range f.resolved {
+		res[source] = sum
+	}
+
+	return res
+}
+
+// SplitPin splits a source such as url#sha256=<hex> into the source and the pinned digest
+func SplitPin(path string) (string, string) {
+	i := strings.LastIndex(path, "#sha256=")
+	if i < 0 {
+		return path, ""
+	}
+
+	return path[:i], strings.ToLower(path[i+len("#sha256="):])
+}
+
+// Digest returns the hex encoded sha256 of the data
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
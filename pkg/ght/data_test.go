@@ -0,0 +1,430 @@
+package ght
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTLSFileServer serves the repository files over https and makes Data trust it
+func newTLSFileServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewTLSServer(http.FileServer(http.Dir(".")))
+	t.Cleanup(srv.Close)
+
+	useFetcher(t, &Fetcher{
+		client:      srv.Client(),
+		maxSize:     DefaultMaxFetchSize,
+		githubHosts: defaultGitHubHosts(),
+	})
+
+	return srv
+}
+
+// useFetcher replaces the fetcher used by Data during a test
+func useFetcher(t *testing.T, f *Fetcher) {
+	previous := DefaultFetcher
+	DefaultFetcher = f
+	t.Cleanup(func() { DefaultFetcher = previous })
+}
+
+func TestDataRemoteFile(t *testing.T) {
+	srv := newTLSFileServer(t)
+
+	data, err := Data(srv.URL + "/testdata/pull_request_template.md")
+	assert.Nil(t, err)
+
+	expected, _ := os.ReadFile("./testdata/pull_request_template.md")
+	assert.Equal(t, expected, data)
+}
+
+func TestDataRemoteFileNotFound(t *testing.T) {
+	srv := newTLSFileServer(t)
+
+	_, err := Data(srv.URL + "/testdata/nonexistent.json")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasSuffix(err.Error(), "unexpected status code: 404 Not Found"))
+}
+
+func TestDataRemoteServerError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>oops</html>", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: DefaultMaxFetchSize})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.NotNil(t, err)
+	assert.True(t, strings.HasSuffix(err.Error(), "unexpected status code: 500 Internal Server Error"))
+}
+
+func TestDataRemoteTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	client := srv.Client()
+	client.Timeout = 50 * time.Millisecond
+	useFetcher(t, &Fetcher{client: client, maxSize: DefaultMaxFetchSize})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.NotNil(t, err)
+
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+	assert.True(t, urlErr.Timeout())
+}
+
+func TestDataRemoteTooLarge(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 64)))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: 32})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrFetchTooLarge))
+}
+
+func TestDataRemoteTooLargeStreamed(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("a", 16)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: 32})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrFetchTooLarge))
+}
+
+func TestDataRemoteGitHubAuth(t *testing.T) {
+	var auth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{
+		client:      srv.Client(),
+		token:       "1234567890",
+		maxSize:     DefaultMaxFetchSize,
+		githubHosts: map[string]bool{"127.0.0.1": true},
+	})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "token 1234567890", auth)
+}
+
+func TestDataRemoteNoAuthForOtherHosts(t *testing.T) {
+	var auth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{
+		client:      srv.Client(),
+		token:       "1234567890",
+		maxSize:     DefaultMaxFetchSize,
+		githubHosts: defaultGitHubHosts(),
+	})
+
+	_, err := Data(srv.URL + "/template.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "", auth)
+}
+
+func TestDataRemoteUntrustedCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.FileServer(http.Dir(".")))
+	defer srv.Close()
+
+	f, err := NewFetcher(&RepoOptions{CacheDir: t.TempDir()})
+	assert.Nil(t, err)
+	useFetcher(t, f)
+
+	_, err = Data(srv.URL + "/testdata/empty.json")
+	assert.NotNil(t, err)
+}
+
+func TestFetcherWithCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.FileServer(http.Dir(".")))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(bundle, cert, 0o600))
+
+	f, err := NewFetcher(&RepoOptions{CABundle: bundle, CacheDir: t.TempDir()})
+	assert.Nil(t, err)
+	useFetcher(t, f)
+
+	_, err = Data(srv.URL + "/testdata/empty.json")
+	assert.Nil(t, err)
+}
+
+func TestFetcherCABundleNotFound(t *testing.T) {
+	_, err := NewFetcher(&RepoOptions{CABundle: "./testdata/nonexistent.pem"})
+	assert.NotNil(t, err)
+	assert.IsType(t, &os.PathError{}, errors.Unwrap(err))
+}
+
+func TestFetcherInvalidCABundle(t *testing.T) {
+	_, err := NewFetcher(&RepoOptions{CABundle: "./testdata/empty.json"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "no certificates found in ca bundle ./testdata/empty.json", err.Error())
+}
+
+func TestFetcherWithProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.Method + " " + r.Host
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	f, err := NewFetcher(&RepoOptions{Proxy: proxy.URL, CacheDir: t.TempDir()})
+	assert.Nil(t, err)
+	useFetcher(t, f)
+
+	_, err = Data("https://raw.githubusercontent.com/leocomelli/ght/main/testdata/empty.json")
+	assert.NotNil(t, err)
+	assert.Equal(t, "CONNECT raw.githubusercontent.com:443", proxied)
+}
+
+func TestFetcherInvalidProxy(t *testing.T) {
+	_, err := NewFetcher(&RepoOptions{Proxy: "://invalid"})
+	assert.NotNil(t, err)
+	assert.IsType(t, &url.Error{}, errors.Unwrap(err))
+}
+
+func TestParseGitHubSource(t *testing.T) {
+	cases := map[string]*GitHubSource{
+		"github://platform/repo-standards/templates/service.json@v1.2.0": {Owner: "platform", Repo: "repo-standards", Path: "templates/service.json", Ref: "v1.2.0"},
+		"gh:platform/repo-standards/templates/service.json@main":         {Owner: "platform", Repo: "repo-standards", Path: "templates/service.json", Ref: "main"},
+		"gh:platform/repo-standards/pr_template.md":                      {Owner: "platform", Repo: "repo-standards", Path: "pr_template.md"},
+	}
+
+	for source, expected := range cases {
+		src, ok, err := ParseGitHubSource(source)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected, src)
+	}
+}
+
+func TestParseGitHubSourceNotGitHub(t *testing.T) {
+	for _, source := range []string{"./testdata/empty.json", "https://github.com/leocomelli/ght"} {
+		src, ok, err := ParseGitHubSource(source)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		assert.Nil(t, src)
+	}
+}
+
+func TestParseGitHubSourceInvalid(t *testing.T) {
+	for _, source := range []string{"github://platform", "gh:platform/repo-standards", "github://platform/repo-standards/@main", "gh://platform/repo/file"} {
+		_, ok, err := ParseGitHubSource(source)
+		assert.True(t, ok)
+		assert.True(t, errors.Is(err, ErrInvalidGitHubSource), source)
+	}
+}
+
+func TestDataGitHubSource(t *testing.T) {
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		_, _ = w.Write([]byte(`{"required_signed_commits": true}`))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), apiURL: srv.URL, token: "1234567890", maxSize: DefaultMaxFetchSize})
+
+	opts := &RepoOptions{Template: "github://platform/repo-standards/templates/service.json@v1.2.0"}
+	cfg, err := LoadRepoConfig(opts)
+	assert.Nil(t, err)
+	assert.True(t, cfg.RequiredSignedCommits)
+
+	assert.Equal(t, "/repos/platform/repo-standards/contents/templates/service.json", req.URL.Path)
+	assert.Equal(t, "v1.2.0", req.URL.Query().Get("ref"))
+	assert.Equal(t, "application/vnd.github.raw", req.Header.Get("Accept"))
+	assert.Equal(t, "token 1234567890", req.Header.Get("Authorization"))
+}
+
+func TestDataGitHubSourceDefaultBranch(t *testing.T) {
+	var req *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		_, _ = w.Write([]byte("template"))
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), apiURL: srv.URL + "/api/v3/", maxSize: DefaultMaxFetchSize})
+
+	data, err := Data("gh:platform/repo-standards/pr_template.md")
+	assert.Nil(t, err)
+	assert.Equal(t, "template", string(data))
+	assert.Equal(t, "/api/v3/repos/platform/repo-standards/contents/pr_template.md", req.URL.Path)
+	assert.False(t, req.URL.Query().Has("ref"))
+	assert.Equal(t, "", req.Header.Get("Authorization"))
+}
+
+func TestDataGitHubSourceNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	useFetcher(t, &Fetcher{client: srv.Client(), apiURL: srv.URL, maxSize: DefaultMaxFetchSize})
+
+	_, err := Data("gh:platform/repo-standards/nonexistent.json@main")
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to get url github://platform/repo-standards/nonexistent.json@main |→ unexpected status code: 404 Not Found", err.Error())
+}
+
+// newETagServer serves the body with an ETag, counting full and revalidated responses
+func newETagServer(t *testing.T, body *string, full, revalidated *int) *httptest.Server {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + Digest([]byte(*body)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			*revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		*full++
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(*body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestDataPinned(t *testing.T) {
+	srv := newTLSFileServer(t)
+
+	expected, _ := os.ReadFile("./testdata/empty.json")
+	data, err := Data(srv.URL + "/testdata/empty.json#sha256=" + Digest(expected))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, data)
+}
+
+func TestDataPinnedMismatch(t *testing.T) {
+	srv := newTLSFileServer(t)
+
+	_, err := Data(srv.URL + "/testdata/empty.json#sha256=" + Digest([]byte("other")))
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrIntegrityMismatch))
+}
+
+func TestDataPinnedLocalFile(t *testing.T) {
+	_, err := Data("./testdata/empty.json#sha256=" + Digest([]byte("other")))
+	assert.True(t, errors.Is(err, ErrIntegrityMismatch))
+}
+
+func TestDataCacheRevalidation(t *testing.T) {
+	body := "v1"
+	full, revalidated := 0, 0
+	srv := newETagServer(t, &body, &full, &revalidated)
+
+	cache, err := NewCache(t.TempDir())
+	assert.Nil(t, err)
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: DefaultMaxFetchSize, cache: cache})
+
+	for i := 0; i < 2; i++ {
+		data, err := Data(srv.URL + "/template.json")
+		assert.Nil(t, err)
+		assert.Equal(t, "v1", string(data))
+	}
+	assert.Equal(t, 1, full)
+	assert.Equal(t, 1, revalidated)
+
+	body = "v2"
+	data, err := Data(srv.URL + "/template.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(data))
+	assert.Equal(t, 2, full)
+}
+
+func TestDataCachePinnedSkipsNetwork(t *testing.T) {
+	body := "v1"
+	full, revalidated := 0, 0
+	srv := newETagServer(t, &body, &full, &revalidated)
+
+	cache, err := NewCache(t.TempDir())
+	assert.Nil(t, err)
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: DefaultMaxFetchSize, cache: cache})
+
+	pinned := srv.URL + "/template.json#sha256=" + Digest([]byte("v1"))
+	for i := 0; i < 3; i++ {
+		_, err := Data(pinned)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, full)
+	assert.Equal(t, 0, revalidated)
+}
+
+func TestDataOffline(t *testing.T) {
+	body := "v1"
+	full, revalidated := 0, 0
+	srv := newETagServer(t, &body, &full, &revalidated)
+
+	cache, err := NewCache(t.TempDir())
+	assert.Nil(t, err)
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: DefaultMaxFetchSize, cache: cache})
+
+	_, err = Data(srv.URL + "/template.json")
+	assert.Nil(t, err)
+
+	useFetcher(t, &Fetcher{client: srv.Client(), maxSize: DefaultMaxFetchSize, cache: cache, offline: true})
+
+	data, err := Data(srv.URL + "/template.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(data))
+	assert.Equal(t, 1, full)
+	assert.Equal(t, 0, revalidated)
+
+	_, err = Data(srv.URL + "/other.json")
+	assert.True(t, errors.Is(err, ErrNotCached))
+}
+
+func TestDataOfflineWithoutCache(t *testing.T) {
+	useFetcher(t, &Fetcher{client: http.DefaultClient, maxSize: DefaultMaxFetchSize, offline: true})
+
+	_, err := Data("https://raw.githubusercontent.com/leocomelli/ght/main/testdata/empty.json")
+	assert.True(t, errors.Is(err, ErrNotCached))
+}
+
+func TestCacheTamperedBlob(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(dir)
+	assert.Nil(t, err)
+
+	assert.Nil(t, cache.Put("https://example.com/template.json", "", []byte("v1")))
+	sum := Digest([]byte("v1"))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "blobs", sum), []byte("v2"), 0o600))
+
+	_, err = cache.Blob(sum)
+	assert.True(t, errors.Is(err, ErrIntegrityMismatch))
+}
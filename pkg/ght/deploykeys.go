@@ -0,0 +1,263 @@
+package ght
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrDeployKeyInUse is returned when a deploy key is already used by another repository or user
+	ErrDeployKeyInUse = errors.New("deploy key is already in use")
+)
+
+// DeployKey is a deploy key of the repository, identified by the fingerprint of its public key
+type DeployKey struct {
+	Title string `json:"title"`
+	// Key is the local path or the url of the public key, read like the other files of the template
+	Key string `json:"key"`
+	// ReadOnly is true when empty
+	ReadOnly *bool `json:"read_only,omitempty"`
+}
+
+// deployKeyState is what a step shows of a deploy key, never the key itself
+type deployKeyState struct {
+	Title       string `json:"title"`
+	Fingerprint string `json:"fingerprint"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// readOnly returns whether the key can only read the repository
+func (k *DeployKey) readOnly() bool {
+	return k.ReadOnly == nil || *k.ReadOnly
+}
+
+// fingerprint returns the SHA256 fingerprint of a public key in the authorized_keys format
+func fingerprint(key []byte) (string, error) {
+	public, _, _, _, err := ssh.ParseAuthorizedKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return ssh.FingerprintSHA256(public), nil
+}
+
+// ListDeployKeys fetches the deploy keys of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deploy-keys#list-deploy-keys
+func (r *RepoTemplate) ListDeployKeys(owner, repo string) ([]*github.Key, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching deploy keys of %s/%s", owner, repo)
+
+	api, err := extension[DeployKeysAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListKeys(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deploy keys of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateDeployKey adds a deploy key to a repository. A key can only be used
+// once across GitHub, ErrDeployKeyInUse is returned otherwise.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deploy-keys#create-a-deploy-key
+func (r *RepoTemplate) CreateDeployKey(owner, repo string, key *github.Key) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating deploy key %s on %s/%s", key.GetTitle(), owner, repo)
+
+	api, err := extension[DeployKeysAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.CreateKey(ctx, owner, repo, key); err != nil {
+		var res *github.ErrorResponse
+		if errors.As(err, &res) && strings.Contains(res.Error(), "already in use") {
+			err = fmt.Errorf("%w |→ %w", ErrDeployKeyInUse, err)
+		}
+		return fmt.Errorf("failed to create deploy key %s on %s/%s |→ %w", key.GetTitle(), owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeleteDeployKey removes a deploy key from a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deploy-keys#delete-a-deploy-key
+func (r *RepoTemplate) DeleteDeployKey(owner, repo string, key *github.Key) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("deleting deploy key %s on %s/%s", key.GetTitle(), owner, repo)
+
+	api, err := extension[DeployKeysAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteKey(ctx, owner, repo, key.GetID()); err != nil {
+		return fmt.Errorf("failed to delete deploy key %s on %s/%s |→ %w", key.GetTitle(), owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeployKeys adds the deploy keys of the template, matching the existing ones
+// by fingerprint, and deletes the others when remove is set. A key whose title
+// or access changed is replaced, as GitHub can't edit them, and put back when
+// the new one can't be created. No key is deleted when a key of the template
+// can't be read, since it may be one of them.
+func (r *RepoTemplate) DeployKeys(opts *RepoOptions, keys []*DeployKey, remove, missing bool) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	// the deploy keys of a repository that does not exist yet can not be read
+	var current []*github.Key
+	if !missing {
+		var err error
+		current, err = r.ListDeployKeys(opts.Owner, opts.Name)
+		if err != nil {
+			return []StepResult{{Name: "deploy_keys", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	byFingerprint := map[string]*github.Key{}
+	fingerprints := map[int64]string{}
+	for _, k := range current {
+		if f, err := fingerprint([]byte(k.GetKey())); err == nil {
+			byFingerprint[f] = k
+			fingerprints[k.GetID()] = f
+		}
+	}
+
+	managed := map[string]string{}
+	var unresolved []string
+	for _, k := range keys {
+		step := StepResult{Name: "deploy_keys:" + k.Title}
+
+		f, err := r.deployKey(opts, k, managed, byFingerprint, &step)
+		if f == "" {
+			unresolved = append(unresolved, k.Title)
+		} else if managed[f] == "" {
+			managed[f] = k.Title
+		}
+		steps = append(steps, step)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				return steps, joinErrors(errs)
+			}
+		}
+	}
+
+	if !remove {
+		return steps, joinErrors(errs)
+	}
+
+	if len(unresolved) > 0 {
+		steps = append(steps, StepResult{
+			Name:    "deploy_keys",
+			Action:  ActionSkipped,
+			Warning: fmt.Sprintf("the unmanaged deploy keys are kept, the key of %s could not be read", strings.Join(unresolved, ", ")),
+		})
+		return steps, joinErrors(errs)
+	}
+
+	for _, k := range current {
+		f := fingerprints[k.GetID()]
+		if managed[f] != "" {
+			continue
+		}
+
+		step := StepResult{Name: "deploy_keys:" + k.GetTitle(), Action: ActionDeleted, Before: &deployKeyState{Title: k.GetTitle(), Fingerprint: f, ReadOnly: k.GetReadOnly()}}
+
+		var err error
+		if !opts.Plan {
+			err = r.DeleteDeployKey(opts.Owner, opts.Name, k)
+		}
+		if err != nil {
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// deployKey adds or replaces a single deploy key, filling the step, and returns its fingerprint
+func (r *RepoTemplate) deployKey(opts *RepoOptions, k *DeployKey, managed map[string]string, byFingerprint map[string]*github.Key, step *StepResult) (string, error) {
+	data, err := r.Fetcher().Data(k.Key)
+	if err != nil {
+		*step = step.Fail(err)
+		return "", err
+	}
+
+	f, err := fingerprint(data)
+	if err != nil {
+		err = fmt.Errorf("invalid public key of deploy key %s |→ %w", k.Title, err)
+		*step = step.Fail(err)
+		return "", err
+	}
+
+	if title := managed[f]; title != "" {
+		err = fmt.Errorf("deploy key %s has the same key as %s", k.Title, title)
+		*step = step.Fail(err)
+		return f, err
+	}
+
+	desired := &deployKeyState{Title: k.Title, Fingerprint: f, ReadOnly: k.readOnly()}
+	step.After = desired
+
+	existing := byFingerprint[f]
+	if existing != nil {
+		step.Before = &deployKeyState{Title: existing.GetTitle(), Fingerprint: f, ReadOnly: existing.GetReadOnly()}
+	}
+	step.Action = action(existing == nil, existing != nil && changed(desired, step.Before))
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return f, nil
+	}
+
+	// GitHub refuses a key already in the repository, so the old one goes first
+	if existing != nil {
+		if err := r.DeleteDeployKey(opts.Owner, opts.Name, existing); err != nil {
+			*step = step.Fail(err)
+			return f, err
+		}
+	}
+
+	err = r.CreateDeployKey(opts.Owner, opts.Name, &github.Key{
+		Title:    github.String(k.Title),
+		Key:      github.String(strings.TrimSpace(string(data))),
+		ReadOnly: github.Bool(k.readOnly()),
+	})
+	if err != nil && existing != nil {
+		// put the old key back, the step reports it lost when that fails too
+		restore := &github.Key{Title: existing.Title, Key: existing.Key, ReadOnly: existing.ReadOnly}
+		if rerr := r.CreateDeployKey(opts.Owner, opts.Name, restore); rerr != nil {
+			err = fmt.Errorf("deploy key %s was deleted to be replaced and could not be restored |→ %w", existing.GetTitle(), errors.Join(err, rerr))
+			step.After = nil
+		}
+	}
+	if err != nil {
+		*step = step.Fail(err)
+	}
+
+	return f, err
+}
@@ -0,0 +1,191 @@
+package ght
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// publicKey generates an ed25519 public key in the authorized_keys format
+func publicKey(t *testing.T) []byte {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	key, err := ssh.NewPublicKey(public)
+	assert.Nil(t, err)
+
+	return ssh.MarshalAuthorizedKey(key)
+}
+
+// writeKey writes a public key into a temp file, returning its path
+func writeKey(t *testing.T, key []byte) string {
+	path := filepath.Join(t.TempDir(), "id_ed25519.pub")
+	assert.Nil(t, os.WriteFile(path, key, 0o600))
+
+	return path
+}
+
+func TestDeployKeys(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	configKey, ciKey, oldKey := publicKey(t), publicKey(t), publicKey(t)
+	srv.AddDeployKey("acme", "ght", "old", string(oldKey), true)
+	srv.AddDeployKey("acme", "ght", "ci", string(ciKey), true)
+
+	cfg := &Config{
+		DeployKeys: []*DeployKey{
+			{Title: "config-management", Key: writeKey(t, configKey)},
+			{Title: "ci", Key: writeKey(t, ciKey), ReadOnly: github.Bool(false)},
+		},
+		RemoveUnmanagedDeployKeys: true,
+	}
+	opts := writeTemplate(t, cfg)
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps[1:] {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"deploy_keys:config-management": ActionCreated,
+		"deploy_keys:ci":                ActionUpdated,
+		"deploy_keys:old":               ActionDeleted,
+	}, actions)
+
+	keys := srv.DeployKeys("acme", "ght")
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "ci", keys[0].GetTitle())
+	assert.False(t, keys[0].GetReadOnly())
+	assert.Equal(t, "config-management", keys[1].GetTitle())
+	assert.True(t, keys[1].GetReadOnly())
+
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestDeployKeysMatchedByFingerprint(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	key := strings.TrimSpace(string(publicKey(t)))
+	srv.AddDeployKey("acme", "ght", "legacy", key+" old@host", false)
+	id := srv.DeployKeys("acme", "ght")[0].GetID()
+
+	// the comment of the key does not matter, the title and the access do
+	opts := writeTemplate(t, &Config{DeployKeys: []*DeployKey{{Title: "config-management", Key: writeKey(t, []byte(key+" new@host\n"))}}})
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "deploy_keys:config-management", res.Steps[1].Name)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+
+	// GitHub can't edit a key, it is deleted then created again
+	assert.Equal(t, []string{
+		fmt.Sprintf("DELETE /repos/acme/ght/keys/%d", id),
+		"POST /repos/acme/ght/keys",
+	}, srv.Writes())
+	assert.JSONEq(t, fmt.Sprintf(`{"title": "config-management", "key": %q, "read_only": true}`, key+" new@host"), srv.Bodies("POST /repos/acme/ght/keys")[0])
+
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestDeployKeysDuplicates(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("other")})
+
+	used, key := publicKey(t), publicKey(t)
+	srv.AddDeployKey("acme", "other", "used", string(used), true)
+
+	opts := writeTemplate(t, &Config{DeployKeys: []*DeployKey{
+		{Title: "first", Key: writeKey(t, key)},
+		{Title: "second", Key: writeKey(t, key)},
+		{Title: "used", Key: writeKey(t, used)},
+	}})
+	opts.ContinueOnError = true
+
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrDeployKeyInUse))
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, ActionFailed, res.Steps[2].Action)
+	assert.Equal(t, "deploy key second has the same key as first", res.Steps[2].Error)
+	assert.Equal(t, ActionFailed, res.Steps[3].Action)
+}
+
+func TestDeployKeysUnreadableKeyKeepsUnmanaged(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	ciKey := publicKey(t)
+	srv.AddDeployKey("acme", "ght", "ci", string(ciKey), true)
+
+	opts := writeTemplate(t, &Config{
+		DeployKeys:                []*DeployKey{{Title: "ci", Key: filepath.Join(t.TempDir(), "missing.pub")}},
+		RemoveUnmanagedDeployKeys: true,
+	})
+	opts.ContinueOnError = true
+
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Equal(t, ActionSkipped, res.Steps[2].Action)
+	assert.Equal(t, "the unmanaged deploy keys are kept, the key of ci could not be read", res.Steps[2].Warning)
+	assert.Len(t, srv.DeployKeys("acme", "ght"), 1)
+}
+
+func TestDeployKeysReplaceFailureRestoresTheKey(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	ciKey := publicKey(t)
+	srv.AddDeployKey("acme", "ght", "ci", string(ciKey), true)
+	srv.Fail("POST /repos/acme/ght/keys", http.StatusInternalServerError)
+
+	opts := writeTemplate(t, &Config{
+		DeployKeys: []*DeployKey{{Title: "ci", Key: writeKey(t, ciKey), ReadOnly: github.Bool(false)}},
+		Autolinks:  []*Autolink{{KeyPrefix: "TICKET-", URLTemplate: "https://jira.acme.io/browse/TICKET-<num>"}},
+	})
+	opts.ContinueOnError = true
+
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.NotNil(t, res.Steps[1].After)
+
+	// the old key is back, read-only as before, and the run went on
+	keys := srv.DeployKeys("acme", "ght")
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "ci", keys[0].GetTitle())
+	assert.True(t, keys[0].GetReadOnly())
+	assert.Equal(t, strings.TrimSpace(string(ciKey)), keys[0].GetKey())
+	assert.Len(t, srv.Autolinks("acme", "ght"), 1)
+}
+
+func TestDeployKeysReplaceAndRestoreFailure(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	ciKey := publicKey(t)
+	srv.AddDeployKey("acme", "ght", "ci", string(ciKey), true)
+	srv.Fail("POST /repos/acme/ght/keys", http.StatusInternalServerError)
+	srv.Fail("POST /repos/acme/ght/keys", http.StatusInternalServerError)
+
+	opts := writeTemplate(t, &Config{
+		DeployKeys: []*DeployKey{{Title: "ci", Key: writeKey(t, ciKey), ReadOnly: github.Bool(false)}},
+	})
+
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Contains(t, res.Steps[1].Error, "deploy key ci was deleted to be replaced and could not be restored")
+	assert.NotNil(t, res.Steps[1].Before)
+	assert.Nil(t, res.Steps[1].After)
+	assert.Empty(t, srv.DeployKeys("acme", "ght"))
+}
@@ -0,0 +1,77 @@
+package ght
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// changed reports whether applying the desired settings would change the current ones.
+// Both values are compared through their JSON form and only the fields present in
+// the desired settings are taken into account, since the API usually returns more
+// fields than it accepts.
+func changed(desired, current interface{}) bool {
+	d, err := normalize(desired)
+	if err != nil {
+		return true
+	}
+
+	c, err := normalize(current)
+	if err != nil {
+		return true
+	}
+
+	return !subset(d, c)
+}
+
+// normalize converts a value into its generic JSON representation
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// subset reports whether every field of desired has the same value in current
+func subset(desired, current interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for k, v := range d {
+			if !subset(v, c[k]) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok {
+			return len(d) == 0 && current == nil
+		}
+
+		if len(d) != len(c) {
+			return false
+		}
+
+		for i := range d {
+			if !subset(d[i], c[i]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return reflect.DeepEqual(desired, current)
+	}
+}
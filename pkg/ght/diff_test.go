@@ -0,0 +1,35 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangedIgnoresExtraFields(t *testing.T) {
+	desired := &github.RequiredStatusChecks{Strict: true, Checks: []*github.RequiredStatusCheck{}}
+	current := &github.RequiredStatusChecks{Strict: true, Contexts: []string{}, Checks: []*github.RequiredStatusCheck{}}
+
+	assert.False(t, changed(desired, current))
+}
+
+func TestChangedDetectsDifferentValues(t *testing.T) {
+	desired := &github.ProtectionRequest{EnforceAdmins: true}
+	current := &github.ProtectionRequest{EnforceAdmins: false}
+
+	assert.True(t, changed(desired, current))
+}
+
+func TestChangedDetectsRemovedSection(t *testing.T) {
+	desired := &github.ProtectionRequest{}
+	current := &github.ProtectionRequest{RequiredStatusChecks: &github.RequiredStatusChecks{Strict: true}}
+
+	assert.True(t, changed(desired, current))
+}
+
+func TestChangedSlices(t *testing.T) {
+	assert.False(t, changed([]string{"a", "b"}, []string{"a", "b"}))
+	assert.True(t, changed([]string{"a", "b"}, []string{"b", "a"}))
+	assert.False(t, changed([]string{}, nil))
+}
@@ -0,0 +1,91 @@
+package ght
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func e2eOptions() *RepoOptions {
+	return &RepoOptions{
+		Owner:    "acme",
+		Name:     "ght",
+		Template: "./testdata/full-repo.json",
+		Topics:   []string{"go", "cli"},
+		Branches: []string{"main"},
+	}
+}
+
+func TestEndToEndCreateAndRerun(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	res, err := Run(rt, e2eOptions())
+	assert.Nil(t, err)
+	assert.True(t, res.Created)
+	assert.True(t, res.Changed())
+
+	repo := srv.Repository("acme", "ght")
+	assert.True(t, repo.GetPrivate())
+	assert.Equal(t, []string{"go", "cli"}, repo.Topics)
+	assert.True(t, srv.Protection("acme", "ght", "main").EnforceAdmins.Enabled)
+
+	tmpl, _ := os.ReadFile("./testdata/pull_request_template.md")
+	content, ok := srv.Content("acme", "ght", PullRequestTemplate)
+	assert.True(t, ok)
+	assert.Equal(t, tmpl, content)
+
+	srv.Reset()
+	res, err = Run(rt, e2eOptions())
+	assert.Nil(t, err)
+	assert.False(t, res.Created)
+	assert.False(t, res.Changed())
+	for _, step := range res.Steps {
+		assert.Equal(t, ActionUnchanged, step.Action, step.Name)
+	}
+	assert.Empty(t, srv.Writes())
+}
+
+func TestEndToEndRepairsDrift(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	_, err = Run(rt, e2eOptions())
+	assert.Nil(t, err)
+
+	// someone changes the settings by hand
+	client := srv.GitHubClient()
+	_, err = client.Repositories.RemoveBranchProtection(context.Background(), "acme", "ght", "main")
+	assert.Nil(t, err)
+	srv.AddFile("acme", "ght", IssueTemplate, []byte("changed"))
+
+	srv.Reset()
+	res, err := Run(rt, e2eOptions())
+	assert.Nil(t, err)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, ActionUnchanged, actions[PullRequestTemplate])
+	assert.Equal(t, ActionUpdated, actions[IssueTemplate])
+	assert.Equal(t, ActionCreated, actions["branch_protection:main"])
+	assert.Equal(t, ActionUpdated, actions["required_signed_commits:main"])
+	assert.NotNil(t, srv.Protection("acme", "ght", "main"))
+
+	srv.Reset()
+	res, err = Run(rt, e2eOptions())
+	assert.Nil(t, err)
+	assert.False(t, res.Changed())
+}
@@ -0,0 +1,674 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Environment is a deployment environment of the repository
+type Environment struct {
+	// WaitTimer is the number of minutes to wait before a deployment, 0 to 43200
+	WaitTimer int                   `json:"wait_timer,omitempty"`
+	Reviewers *EnvironmentReviewers `json:"reviewers,omitempty"`
+	// ProtectedBranches allows only the protected branches to deploy, it can't be used with the policies
+	ProtectedBranches bool `json:"protected_branches,omitempty"`
+	// BranchPolicies and TagPolicies are the name patterns of the branches and tags allowed to deploy
+	BranchPolicies []string                 `json:"branch_policies,omitempty"`
+	TagPolicies    []string                 `json:"tag_policies,omitempty"`
+	Secrets        map[string]*SecretSource `json:"secrets,omitempty"`
+	Variables      map[string]string        `json:"variables,omitempty"`
+}
+
+// EnvironmentReviewers are the users, by login, and the teams of the owner, by
+// slug, that must approve the deployments
+type EnvironmentReviewers struct {
+	Users []string `json:"users,omitempty"`
+	Teams []string `json:"teams,omitempty"`
+}
+
+// DeploymentPolicy is a name pattern of the branches or tags allowed to deploy to an environment
+type DeploymentPolicy struct {
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Type is branch or tag, branch when empty
+	Type string `json:"type,omitempty"`
+}
+
+// String returns the policy as type:name
+func (p *DeploymentPolicy) String() string {
+	if p.Type == "" {
+		return "branch:" + p.Name
+	}
+
+	return p.Type + ":" + p.Name
+}
+
+// environmentState is the part of an environment compared to detect drift
+type environmentState struct {
+	WaitTimer         int      `json:"wait_timer"`
+	Users             []string `json:"users"`
+	Teams             []string `json:"teams"`
+	ProtectedBranches bool     `json:"protected_branches"`
+	CustomPolicies    bool     `json:"custom_branch_policies"`
+}
+
+// validate checks the environment settings, nil-safe as an environment may be null in the template
+func (e *Environment) validate(name string) error {
+	if e == nil {
+		return fmt.Errorf("environment %s has no settings", name)
+	}
+	if e.ProtectedBranches && e.customPolicies() {
+		return fmt.Errorf("environment %s can't have protected_branches and branch or tag policies", name)
+	}
+	if e.WaitTimer < 0 || e.WaitTimer > 43200 {
+		return fmt.Errorf("wait_timer of environment %s must be between 0 and 43200", name)
+	}
+	for secret, s := range e.Secrets {
+		if err := s.validate(secret); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// customPolicies reports whether the environment restricts the deployments to its policies
+func (e *Environment) customPolicies() bool {
+	return len(e.BranchPolicies) > 0 || len(e.TagPolicies) > 0
+}
+
+// policies returns the deployment policies of the environment, sorted
+func (e *Environment) policies() []*DeploymentPolicy {
+	var policies []*DeploymentPolicy
+	for _, name := range e.BranchPolicies {
+		policies = append(policies, &DeploymentPolicy{Name: name, Type: "branch"})
+	}
+	for _, name := range e.TagPolicies {
+		policies = append(policies, &DeploymentPolicy{Name: name, Type: "tag"})
+	}
+	sortPolicies(policies)
+
+	return policies
+}
+
+// state returns the settings of the environment compared to the current ones
+func (e *Environment) state() *environmentState {
+	state := &environmentState{
+		WaitTimer:         e.WaitTimer,
+		Users:             []string{},
+		Teams:             []string{},
+		ProtectedBranches: e.ProtectedBranches,
+		CustomPolicies:    e.customPolicies(),
+	}
+	if e.Reviewers != nil {
+		for _, u := range e.Reviewers.Users {
+			state.Users = append(state.Users, strings.ToLower(u))
+		}
+		for _, t := range e.Reviewers.Teams {
+			state.Teams = append(state.Teams, strings.ToLower(t))
+		}
+	}
+	sort.Strings(state.Users)
+	sort.Strings(state.Teams)
+
+	return state
+}
+
+// currentState returns the settings of an existing environment, read from its protection rules
+func currentState(env *github.Environment) *environmentState {
+	state := &environmentState{Users: []string{}, Teams: []string{}}
+	for _, rule := range env.ProtectionRules {
+		switch rule.GetType() {
+		case "wait_timer":
+			state.WaitTimer = rule.GetWaitTimer()
+		case "required_reviewers":
+			for _, reviewer := range rule.Reviewers {
+				switch v := reviewer.Reviewer.(type) {
+				case *github.User:
+					state.Users = append(state.Users, strings.ToLower(v.GetLogin()))
+				case *github.Team:
+					state.Teams = append(state.Teams, strings.ToLower(v.GetSlug()))
+				}
+			}
+		}
+	}
+	sort.Strings(state.Users)
+	sort.Strings(state.Teams)
+
+	if policy := env.DeploymentBranchPolicy; policy != nil {
+		state.ProtectedBranches = policy.GetProtectedBranches()
+		state.CustomPolicies = policy.GetCustomBranchPolicies()
+	}
+
+	return state
+}
+
+// sortPolicies sorts the policies by type and name
+func sortPolicies(policies []*DeploymentPolicy) {
+	sort.Slice(policies, func(i, j int) bool { return policies[i].String() < policies[j].String() })
+}
+
+// GetEnvironment fetches a deployment environment of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deployments/environments#get-an-environment
+func (r *RepoTemplate) GetEnvironment(owner, repo, name string) (*github.Environment, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching environment %s of %s/%s", name, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.GetEnvironment(ctx, owner, repo, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch environment %s of %s/%s |→ %w", name, owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateUpdateEnvironment creates or updates a deployment environment, its
+// protection rules are replaced as a whole.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deployments/environments#create-or-update-an-environment
+func (r *RepoTemplate) CreateUpdateEnvironment(owner, repo, name string, env *github.CreateUpdateEnvironment) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting environment %s on %s/%s", name, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.CreateUpdateEnvironment(ctx, owner, repo, name, env); err != nil {
+		return fmt.Errorf("failed to set environment %s on %s/%s |→ %w", name, owner, repo, err)
+	}
+
+	return nil
+}
+
+// ListDeploymentPolicies fetches the branch and tag policies of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deployments/branch-policies#list-deployment-branch-policies
+func (r *RepoTemplate) ListDeploymentPolicies(owner, repo, env string) ([]*DeploymentPolicy, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching deployment policies of environment %s of %s/%s", env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListDeploymentPolicies(ctx, owner, repo, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployment policies of environment %s of %s/%s |→ %w", env, owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateDeploymentPolicy creates a branch or tag policy in a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deployments/branch-policies#create-a-deployment-branch-policy
+func (r *RepoTemplate) CreateDeploymentPolicy(owner, repo, env string, policy *DeploymentPolicy) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating deployment policy %s in environment %s of %s/%s", policy, env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.CreateDeploymentPolicy(ctx, owner, repo, env, policy); err != nil {
+		return fmt.Errorf("failed to create deployment policy %s in environment %s of %s/%s |→ %w", policy, env, owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeleteDeploymentPolicy deletes a branch or tag policy of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/deployments/branch-policies#delete-a-deployment-branch-policy
+func (r *RepoTemplate) DeleteDeploymentPolicy(owner, repo, env string, policy *DeploymentPolicy) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("deleting deployment policy %s in environment %s of %s/%s", policy, env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteDeploymentPolicy(ctx, owner, repo, env, policy.ID); err != nil {
+		return fmt.Errorf("failed to delete deployment policy %s in environment %s of %s/%s |→ %w", policy, env, owner, repo, err)
+	}
+
+	return nil
+}
+
+// GetEnvPublicKey fetches the key used to encrypt the secrets of a deployment
+// environment, that is addressed by the id of its repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#get-an-environment-public-key
+func (r *RepoTemplate) GetEnvPublicKey(owner, repo string, repoID int64, env string) (*github.PublicKey, error) {
+	ctx := context.Background()
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.GetEnvPublicKey(ctx, repoID, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the public key of environment %s of %s/%s |→ %w", env, owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// ListEnvSecrets fetches the names and timestamps of the secrets of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#list-environment-secrets
+func (r *RepoTemplate) ListEnvSecrets(owner, repo string, repoID int64, env string) ([]*github.Secret, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching secrets of environment %s of %s/%s", env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListEnvSecrets(ctx, repoID, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets of environment %s of %s/%s |→ %w", env, owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateOrUpdateEnvSecret creates or updates an encrypted secret of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/secrets#create-or-update-an-environment-secret
+func (r *RepoTemplate) CreateOrUpdateEnvSecret(owner, repo string, repoID int64, env string, secret *github.EncryptedSecret) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting secret %s of environment %s on %s/%s", secret.Name, env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.CreateOrUpdateEnvSecret(ctx, repoID, env, secret); err != nil {
+		return fmt.Errorf("failed to set secret %s of environment %s on %s/%s |→ %w", secret.Name, env, owner, repo, err)
+	}
+
+	return nil
+}
+
+// ListEnvVariables fetches the variables of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#list-environment-variables
+func (r *RepoTemplate) ListEnvVariables(owner, repo string, repoID int64, env string) ([]*github.ActionsVariable, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching variables of environment %s of %s/%s", env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListEnvVariables(ctx, repoID, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variables of environment %s of %s/%s |→ %w", env, owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateEnvVariable creates a variable in a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#create-an-environment-variable
+func (r *RepoTemplate) CreateEnvVariable(owner, repo string, repoID int64, env string, variable *github.ActionsVariable) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating variable %s in environment %s of %s/%s", variable.Name, env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.CreateEnvVariable(ctx, repoID, env, variable); err != nil {
+		return fmt.Errorf("failed to create variable %s in environment %s of %s/%s |→ %w", variable.Name, env, owner, repo, err)
+	}
+
+	return nil
+}
+
+// UpdateEnvVariable updates a variable of a deployment environment.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/variables#update-an-environment-variable
+func (r *RepoTemplate) UpdateEnvVariable(owner, repo string, repoID int64, env string, variable *github.ActionsVariable) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("updating variable %s of environment %s on %s/%s", variable.Name, env, owner, repo)
+
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.UpdateEnvVariable(ctx, repoID, env, variable); err != nil {
+		return fmt.Errorf("failed to update variable %s of environment %s on %s/%s |→ %w", variable.Name, env, owner, repo, err)
+	}
+
+	return nil
+}
+
+// reviewers resolves the logins and slugs of the reviewers into their ids, as GitHub requires
+func (r *RepoTemplate) reviewers(org string, reviewers *EnvironmentReviewers) ([]*github.EnvReviewers, error) {
+	ctx := context.Background()
+
+	if reviewers == nil {
+		return nil, nil
+	}
+	api, err := extension[EnvironmentsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*github.EnvReviewers
+	for _, login := range reviewers.Users {
+		u, err := api.GetUser(ctx, login)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch reviewer %s |→ %w", login, err)
+		}
+		res = append(res, &github.EnvReviewers{Type: github.String("User"), ID: u.ID})
+	}
+	for _, slug := range reviewers.Teams {
+		t, err := api.GetTeamBySlug(ctx, org, slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch reviewer team %s/%s |→ %w", org, slug, err)
+		}
+		res = append(res, &github.EnvReviewers{Type: github.String("Team"), ID: t.ID})
+	}
+
+	return res, nil
+}
+
+// Environments creates or updates the deployment environments of the template,
+// with their protection rules, deployment policies, secrets and variables.
+// The environments of the repository that are not in the template are kept.
+func (r *RepoTemplate) Environments(opts *RepoOptions, envs map[string]*Environment, missing bool) ([]StepResult, error) {
+	var (
+		steps  []StepResult
+		errs   []error
+		repoID int64
+	)
+
+	// the secrets and variables of the environments are addressed by the repository id
+	id := func() (int64, error) {
+		if repoID == 0 {
+			repo, err := r.GetRepo(opts.Owner, opts.Name)
+			if err != nil {
+				return 0, err
+			}
+			repoID = repo.GetID()
+		}
+		return repoID, nil
+	}
+
+	for _, name := range sortedKeys(envs) {
+		envSteps, err := r.environment(opts, name, envs[name], missing, id)
+		steps = append(steps, envSteps...)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				break
+			}
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// environment reconciles a single environment, then its policies, secrets and variables
+func (r *RepoTemplate) environment(opts *RepoOptions, name string, env *Environment, missing bool, repoID func() (int64, error)) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	prefix := "environments:" + name
+	step := StepResult{Name: prefix}
+
+	if err := env.validate(name); err != nil {
+		return []StepResult{step.Fail(err)}, err
+	}
+	desired := env.state()
+	step.After = desired
+
+	// the environments of a repository that does not exist yet can not be read
+	var current *github.Environment
+	if !missing {
+		var err error
+		current, err = r.GetEnvironment(owner, repo, name)
+		if err != nil && !isNotFound(err) {
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+
+	if current != nil {
+		state := currentState(current)
+		step.Before = state
+		step.Action = action(false, changed(desired, state))
+	} else {
+		step.Action = ActionCreated
+	}
+
+	if step.Action != ActionUnchanged && !opts.Plan {
+		if err := r.putEnvironment(opts, name, env); err != nil {
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+	steps := []StepResult{step}
+
+	// a new environment has no policies, secrets nor variables to read
+	created := current == nil
+
+	var errs []error
+	if env.customPolicies() {
+		policyStep, err := r.deploymentPolicies(opts, name, env, current)
+		steps = append(steps, policyStep)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				return steps, joinErrors(errs)
+			}
+		}
+	}
+
+	if len(env.Secrets) > 0 {
+		secretSteps, err := r.environmentSecrets(opts, name, env.Secrets, created, repoID)
+		steps = append(steps, secretSteps...)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				return steps, joinErrors(errs)
+			}
+		}
+	}
+
+	if len(env.Variables) > 0 {
+		variableSteps, err := r.environmentVariables(opts, name, env.Variables, created, repoID)
+		steps = append(steps, variableSteps...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// putEnvironment writes the protection rules of the environment, resolving its reviewers
+func (r *RepoTemplate) putEnvironment(opts *RepoOptions, name string, env *Environment) error {
+	reviewers, err := r.reviewers(opts.Owner, env.Reviewers)
+	if err != nil {
+		return err
+	}
+
+	req := &github.CreateUpdateEnvironment{
+		WaitTimer: github.Int(env.WaitTimer),
+		Reviewers: reviewers,
+	}
+	if env.ProtectedBranches || env.customPolicies() {
+		req.DeploymentBranchPolicy = &github.BranchPolicy{
+			ProtectedBranches:    github.Bool(env.ProtectedBranches),
+			CustomBranchPolicies: github.Bool(env.customPolicies()),
+		}
+	}
+
+	return r.CreateUpdateEnvironment(opts.Owner, opts.Name, name, req)
+}
+
+// deploymentPolicies creates the missing branch and tag policies of the
+// environment and deletes the others, in a single step
+func (r *RepoTemplate) deploymentPolicies(opts *RepoOptions, name string, env *Environment, current *github.Environment) (StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	desired := env.policies()
+
+	var names []string
+	for _, p := range desired {
+		names = append(names, p.String())
+	}
+	step := StepResult{Name: "environments:" + name + ":deployment_policies", After: names}
+
+	// the policies are dropped by GitHub when the environment did not restrict the deployments to them
+	var existing []*DeploymentPolicy
+	if current != nil && current.GetDeploymentBranchPolicy().GetCustomBranchPolicies() {
+		var err error
+		existing, err = r.ListDeploymentPolicies(owner, repo, name)
+		if err != nil {
+			return step.Fail(err), err
+		}
+		sortPolicies(existing)
+	}
+
+	byName := map[string]*DeploymentPolicy{}
+	var before []string
+	for _, p := range existing {
+		byName[p.String()] = p
+		before = append(before, p.String())
+	}
+	if current != nil {
+		step.Before = before
+	}
+
+	var create, remove []*DeploymentPolicy
+	wanted := map[string]bool{}
+	for _, p := range desired {
+		wanted[p.String()] = true
+		if byName[p.String()] == nil {
+			create = append(create, p)
+		}
+	}
+	for _, p := range existing {
+		if !wanted[p.String()] {
+			remove = append(remove, p)
+		}
+	}
+
+	step.Action = action(current == nil, len(create)+len(remove) > 0)
+	if opts.Plan {
+		return step, nil
+	}
+
+	for _, p := range remove {
+		if err := r.DeleteDeploymentPolicy(owner, repo, name, p); err != nil {
+			return step.Fail(err), err
+		}
+	}
+	for _, p := range create {
+		if err := r.CreateDeploymentPolicy(owner, repo, name, p); err != nil {
+			return step.Fail(err), err
+		}
+	}
+
+	return step, nil
+}
+
+// environmentSecrets creates the secrets missing in the environment and updates the outdated ones
+func (r *RepoTemplate) environmentSecrets(opts *RepoOptions, name string, secrets map[string]*SecretSource, created bool, repoID func() (int64, error)) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	prefix := "environments:" + name + ":secrets:"
+
+	var current []*github.Secret
+	if !created {
+		id, err := repoID()
+		if err == nil {
+			current, err = r.ListEnvSecrets(owner, repo, id, name)
+		}
+		if err != nil {
+			return []StepResult{{Name: strings.TrimSuffix(prefix, ":"), Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	return reconcileSecrets(opts, prefix, secrets, current,
+		func() (*github.PublicKey, error) {
+			id, err := repoID()
+			if err != nil {
+				return nil, err
+			}
+			return r.GetEnvPublicKey(owner, repo, id, name)
+		},
+		func(secret *github.EncryptedSecret) error {
+			id, err := repoID()
+			if err != nil {
+				return err
+			}
+			return r.CreateOrUpdateEnvSecret(owner, repo, id, name, secret)
+		},
+	)
+}
+
+// environmentVariables creates or updates the variables of the environment
+func (r *RepoTemplate) environmentVariables(opts *RepoOptions, name string, variables map[string]string, created bool, repoID func() (int64, error)) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	prefix := "environments:" + name + ":variables:"
+
+	var current []*github.ActionsVariable
+	if !created {
+		id, err := repoID()
+		if err == nil {
+			current, err = r.ListEnvVariables(owner, repo, id, name)
+		}
+		if err != nil {
+			return []StepResult{{Name: strings.TrimSuffix(prefix, ":"), Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	return reconcileVariables(opts, prefix, variables, current,
+		func(v *github.ActionsVariable) error {
+			id, err := repoID()
+			if err != nil {
+				return err
+			}
+			return r.CreateEnvVariable(owner, repo, id, name, v)
+		},
+		func(v *github.ActionsVariable) error {
+			id, err := repoID()
+			if err != nil {
+				return err
+			}
+			return r.UpdateEnvVariable(owner, repo, id, name, v)
+		},
+	)
+}
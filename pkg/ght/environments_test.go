@@ -0,0 +1,172 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironments(t *testing.T) {
+	t.Setenv("DEPLOY_TOKEN_VALUE", "d3pl0y")
+
+	srv, rt := newTestServer(t)
+	srv.AddTeam("acme", "sre")
+	srv.AddUser("octocat")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+
+	production := &Environment{
+		WaitTimer:      30,
+		Reviewers:      &EnvironmentReviewers{Users: []string{"octocat"}, Teams: []string{"sre"}},
+		BranchPolicies: []string{"main"},
+		TagPolicies:    []string{"v*"},
+		Secrets:        map[string]*SecretSource{"DEPLOY_TOKEN": {Env: "DEPLOY_TOKEN_VALUE"}},
+		Variables:      map[string]string{"URL": "https://acme.io"},
+	}
+	opts := writeTemplate(t, &Config{Environments: map[string]*Environment{
+		"production": production,
+		"staging":    {ProtectedBranches: true},
+	}})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps[1:] {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"environments:production":                      ActionCreated,
+		"environments:production:deployment_policies":  ActionCreated,
+		"environments:production:secrets:DEPLOY_TOKEN": ActionCreated,
+		"environments:production:variables:URL":        ActionCreated,
+		"environments:staging":                         ActionCreated,
+	}, actions)
+
+	env, policies := srv.Environment("acme", "ght", "production")
+	assert.NotNil(t, env)
+	assert.Equal(t, []string{"branch:main", "tag:v*"}, policies)
+	state := currentState(env)
+	assert.Equal(t, 30, state.WaitTimer)
+	assert.Equal(t, []string{"octocat"}, state.Users)
+	assert.Equal(t, []string{"sre"}, state.Teams)
+	secret, _ := srv.EnvSecret("acme", "ght", "production", "DEPLOY_TOKEN")
+	assert.Equal(t, "d3pl0y", secret)
+	v, _ := srv.EnvVariable("acme", "ght", "production", "URL")
+	assert.Equal(t, "https://acme.io", v)
+
+	staging, _ := srv.Environment("acme", "ght", "staging")
+	assert.True(t, staging.GetDeploymentBranchPolicy().GetProtectedBranches())
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+
+	// the policies not in the template are deleted
+	production.TagPolicies = nil
+	production.WaitTimer = 0
+	opts = writeTemplate(t, &Config{Environments: map[string]*Environment{"production": production}})
+	res, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, ActionUpdated, res.Steps[2].Action)
+	_, policies = srv.Environment("acme", "ght", "production")
+	assert.Equal(t, []string{"branch:main"}, policies)
+}
+
+func TestEnvironmentsPlanOnMissingRepo(t *testing.T) {
+	srv, rt := newTestServer(t)
+
+	opts := writeTemplate(t, &Config{
+		Repository: &github.Repository{Name: github.String("ght")},
+		Environments: map[string]*Environment{"production": {
+			BranchPolicies: []string{"main"},
+			Secrets:        map[string]*SecretSource{"TOKEN": {Command: "exit 1"}},
+			Variables:      map[string]string{"URL": "https://acme.io"},
+		}},
+	})
+
+	res := plan(t, srv, rt, opts)
+	for _, step := range res.Steps {
+		assert.Equal(t, ActionCreated, step.Action, step.Name)
+	}
+	assert.Len(t, res.Steps, 5)
+}
+
+func TestEnvironmentValidate(t *testing.T) {
+	assert.NotNil(t, (&Environment{ProtectedBranches: true, BranchPolicies: []string{"main"}}).validate("production"))
+	assert.NotNil(t, (&Environment{WaitTimer: 50000}).validate("production"))
+	assert.NotNil(t, (&Environment{Secrets: map[string]*SecretSource{"TOKEN": {}}}).validate("production"))
+	assert.Nil(t, (&Environment{ProtectedBranches: true, WaitTimer: 10}).validate("production"))
+	assert.EqualError(t, (*Environment)(nil).validate("staging"), "environment staging has no settings")
+}
+
+func TestEnvironmentsWithoutSettings(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	// "environments": {"staging": null, "production": {"secrets": {"TOKEN": null}}}
+	opts := writeTemplate(t, &Config{Environments: map[string]*Environment{
+		"staging":    nil,
+		"production": {Secrets: map[string]*SecretSource{"TOKEN": nil}},
+	}})
+	opts.ContinueOnError = true
+
+	res, err := Run(rt, opts)
+	assert.ErrorContains(t, err, "environment staging has no settings")
+	assert.ErrorContains(t, err, "secret TOKEN has no source")
+	assert.Equal(t, "environments:production", res.Steps[1].Name)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Equal(t, "environments:staging", res.Steps[2].Name)
+	assert.Equal(t, ActionFailed, res.Steps[2].Action)
+	assert.Empty(t, srv.Writes())
+}
+
+func TestEnvironmentsRequestBodies(t *testing.T) {
+	t.Setenv("DEPLOY_TOKEN_VALUE", "d3pl0y")
+
+	srv, rt := newTestServer(t)
+	srv.AddTeam("acme", "sre")
+	user := srv.AddUser("octocat")
+	repo := srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	team, _, err := srv.GitHubClient().Teams.GetTeamBySlug(context.Background(), "acme", "sre")
+	assert.Nil(t, err)
+
+	opts := writeTemplate(t, &Config{Environments: map[string]*Environment{"production": {
+		WaitTimer:         5,
+		Reviewers:         &EnvironmentReviewers{Users: []string{"OctoCat"}, Teams: []string{"sre"}},
+		ProtectedBranches: true,
+		Secrets: map[string]*SecretSource{
+			"DEPLOY_TOKEN": {Env: "DEPLOY_TOKEN_VALUE"},
+			"DEPLOY_USER":  {Command: "echo robot"},
+		},
+		Variables: map[string]string{"URL": "https://acme.io"},
+	}}})
+
+	srv.Reset()
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+
+	// the reviewers are resolved to their ids
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"wait_timer": 5,
+		"reviewers": [{"type": "User", "id": %d}, {"type": "Team", "id": %d}],
+		"deployment_branch_policy": {"protected_branches": true, "custom_branch_policies": false}
+	}`, user.GetID(), team.GetID()), srv.Bodies("PUT /repos/acme/ght/environments/production")[0])
+
+	// the secrets and variables of the new environment are addressed by the repository id, read once
+	prefix := fmt.Sprintf("/repositories/%d/environments/production", repo.GetID())
+	assert.Equal(t, []string{
+		"GET /repos/acme/ght",
+		"GET /repos/acme/ght/environments/production",
+		"GET /users/OctoCat",
+		"GET /orgs/acme/teams/sre",
+		"PUT /repos/acme/ght/environments/production",
+		"GET /repos/acme/ght",
+		"GET " + prefix + "/secrets/public-key",
+		"PUT " + prefix + "/secrets/DEPLOY_TOKEN",
+		"PUT " + prefix + "/secrets/DEPLOY_USER",
+		"POST " + prefix + "/variables",
+	}, srv.Requests())
+	assert.Equal(t, []string{`{"name":"URL","value":"https://acme.io"}`}, srv.Bodies("POST "+prefix+"/variables"))
+}
@@ -0,0 +1,77 @@
+package ght
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// IsAuthError reports whether GitHub rejected the credentials or their permissions
+func IsAuthError(err error) bool {
+	// errors.As stops at the first match, so each joined error is checked on its own
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if IsAuthError(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var res *github.ErrorResponse
+	if errors.As(err, &res) && res.Response != nil {
+		return res.Response.StatusCode == http.StatusUnauthorized || res.Response.StatusCode == http.StatusForbidden
+	}
+
+	return false
+}
+
+// IsConfigError reports whether the error comes from an invalid template or file reference
+func IsConfigError(err error) bool {
+	var (
+		pathErr   *os.PathError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	return errors.As(err, &pathErr) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) ||
+		errors.Is(err, ErrRepoConfigNotFound) || errors.Is(err, ErrInvalidGitHubSource) || errors.Is(err, ErrRemoteSecretSource) ||
+		errors.Is(err, ErrIntegrityMismatch) || errors.Is(err, ErrNotCached) || errors.Is(err, ErrFetchTooLarge)
+}
+
+// IsTransient reports whether the error may go away by trying again: a network
+// error, a rate limit or a server error of GitHub. Joined errors are transient
+// only when all of them are.
+func IsTransient(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !IsTransient(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if IsAuthError(err) || IsConfigError(err) {
+		return false
+	}
+
+	var (
+		rateErr  *github.RateLimitError
+		abuseErr *github.AbuseRateLimitError
+		res      *github.ErrorResponse
+		netErr   net.Error
+	)
+	switch {
+	case errors.As(err, &rateErr) || errors.As(err, &abuseErr):
+		return true
+	case errors.As(err, &res) && res.Response != nil:
+		return res.Response.StatusCode >= http.StatusInternalServerError || res.Response.StatusCode == http.StatusTooManyRequests
+	default:
+		return errors.As(err, &netErr)
+	}
+}
@@ -0,0 +1,31 @@
+package ght
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func apiError(status int) error {
+	return fmt.Errorf("failed |→ %w", &github.ErrorResponse{Response: &http.Response{StatusCode: status}})
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, IsTransient(apiError(http.StatusBadGateway)))
+	assert.True(t, IsTransient(apiError(http.StatusTooManyRequests)))
+	assert.True(t, IsTransient(&github.RateLimitError{}))
+	assert.True(t, IsTransient(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, IsTransient(errors.Join(apiError(http.StatusInternalServerError), apiError(http.StatusServiceUnavailable))))
+
+	assert.False(t, IsTransient(apiError(http.StatusUnauthorized)))
+	assert.False(t, IsTransient(apiError(http.StatusUnprocessableEntity)))
+	assert.False(t, IsTransient(&os.PathError{Op: "open", Path: "missing.json", Err: os.ErrNotExist}))
+	assert.False(t, IsTransient(ErrRepoConfigNotFound))
+	assert.False(t, IsTransient(errors.Join(apiError(http.StatusBadGateway), ErrRepoConfigNotFound)))
+}
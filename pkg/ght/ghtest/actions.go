@@ -0,0 +1,375 @@
+package ghtest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// secrets holds the Actions secrets of a repository or an environment, sealed
+// with its own key pair like GitHub does
+type secrets struct {
+	keyID      string
+	publicKey  *[32]byte
+	privateKey *[32]byte
+	values     map[string]*secret
+}
+
+// secret is an Actions secret, its value is kept decrypted so that tests can check it
+type secret struct {
+	value     string
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// newSecrets creates an empty set of secrets with a new key pair
+func newSecrets(id int64) *secrets {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return &secrets{
+		keyID:      commitSHA(id)[:20],
+		publicKey:  public,
+		privateKey: private,
+		values:     map[string]*secret{},
+	}
+}
+
+// now returns the current time truncated to the second, as GitHub returns it
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// AddSecret registers an Actions secret in a repository, updated at the given time
+func (s *Server) AddSecret(owner, repo, name, value string, updatedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		r.secrets.values[name] = &secret{value: value, createdAt: updatedAt, updatedAt: updatedAt}
+	}
+}
+
+// Secret returns the decrypted value of an Actions secret and whether it exists
+func (s *Server) Secret(owner, repo, name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		if v := r.secrets.values[name]; v != nil {
+			return v.value, true
+		}
+	}
+
+	return "", false
+}
+
+// AddVariable registers an Actions variable in a repository
+func (s *Server) AddVariable(owner, repo, name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		r.variables[name] = &github.ActionsVariable{Name: name, Value: value, CreatedAt: &github.Timestamp{Time: now()}, UpdatedAt: &github.Timestamp{Time: now()}}
+	}
+}
+
+// Variable returns the value of an Actions variable and whether it exists
+func (s *Server) Variable(owner, repo, name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		if v := r.variables[name]; v != nil {
+			return v.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// routeActions handles the requests to /repos/{owner}/{repo}/actions/...
+func (s *Server) routeActions(w http.ResponseWriter, req *http.Request, r *repository, p []string) bool {
+	switch {
+	case len(p) >= 1 && p[0] == "permissions":
+		return s.routePermissions(w, req, r, p[1:])
+	case match(p, "secrets", "public-key") && req.Method == http.MethodGet:
+		s.publicKey(w, r.secrets)
+	case match(p, "secrets") && req.Method == http.MethodGet:
+		s.listSecrets(w, r.secrets)
+	case match(p, "secrets", "*"):
+		return s.secret(w, req, r.secrets, p[1])
+	case match(p, "variables"):
+		return s.variables(w, req, r.variables)
+	case match(p, "variables", "*"):
+		return s.variable(w, req, r.variables, p[1])
+	default:
+		return false
+	}
+
+	return true
+}
+
+// publicKey handles GET .../secrets/public-key
+func (s *Server) publicKey(w http.ResponseWriter, sec *secrets) {
+	writeJSON(w, http.StatusOK, &github.PublicKey{
+		KeyID: github.String(sec.keyID),
+		Key:   github.String(base64.StdEncoding.EncodeToString(sec.publicKey[:])),
+	})
+}
+
+// listSecrets handles GET .../secrets, the values are never returned
+func (s *Server) listSecrets(w http.ResponseWriter, sec *secrets) {
+	list := &github.Secrets{Secrets: []*github.Secret{}}
+	for name, v := range sec.values {
+		list.Secrets = append(list.Secrets, &github.Secret{
+			Name:      name,
+			CreatedAt: github.Timestamp{Time: v.createdAt},
+			UpdatedAt: github.Timestamp{Time: v.updatedAt},
+		})
+	}
+	sort.Slice(list.Secrets, func(i, j int) bool { return list.Secrets[i].Name < list.Secrets[j].Name })
+	list.TotalCount = len(list.Secrets)
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// secret handles the requests to .../secrets/{name}
+func (s *Server) secret(w http.ResponseWriter, req *http.Request, sec *secrets, name string) bool {
+	switch req.Method {
+	case http.MethodGet:
+		v := sec.values[name]
+		if v == nil {
+			return false
+		}
+		writeJSON(w, http.StatusOK, &github.Secret{
+			Name:      name,
+			CreatedAt: github.Timestamp{Time: v.createdAt},
+			UpdatedAt: github.Timestamp{Time: v.updatedAt},
+		})
+	case http.MethodPut:
+		body := &github.EncryptedSecret{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.KeyID != sec.keyID {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: key_id does not match the public key")
+			return true
+		}
+		sealed, err := base64.StdEncoding.DecodeString(body.EncryptedValue)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: encrypted_value is not base64")
+			return true
+		}
+		value, ok := box.OpenAnonymous(nil, sealed, sec.publicKey, sec.privateKey)
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: encrypted_value can't be decrypted")
+			return true
+		}
+
+		status := http.StatusNoContent
+		v := sec.values[name]
+		if v == nil {
+			status = http.StatusCreated
+			v = &secret{createdAt: now()}
+			sec.values[name] = v
+		}
+		v.value = string(value)
+		v.updatedAt = now()
+		w.WriteHeader(status)
+	case http.MethodDelete:
+		if sec.values[name] == nil {
+			return false
+		}
+		delete(sec.values, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// variables handles the requests to .../variables
+func (s *Server) variables(w http.ResponseWriter, req *http.Request, vars map[string]*github.ActionsVariable) bool {
+	switch req.Method {
+	case http.MethodGet:
+		list := &github.ActionsVariables{Variables: []*github.ActionsVariable{}}
+		for _, v := range vars {
+			list.Variables = append(list.Variables, v)
+		}
+		sort.Slice(list.Variables, func(i, j int) bool { return list.Variables[i].Name < list.Variables[j].Name })
+		list.TotalCount = len(list.Variables)
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		body := &github.ActionsVariable{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.Name == "" {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name is missing")
+			return true
+		}
+		if vars[body.Name] != nil {
+			writeError(w, http.StatusConflict, "Already exists - Variable already exists")
+			return true
+		}
+		vars[body.Name] = &github.ActionsVariable{Name: body.Name, Value: body.Value, CreatedAt: &github.Timestamp{Time: now()}, UpdatedAt: &github.Timestamp{Time: now()}}
+		writeJSON(w, http.StatusCreated, map[string]string{})
+	default:
+		return false
+	}
+
+	return true
+}
+
+// variable handles the requests to .../variables/{name}
+func (s *Server) variable(w http.ResponseWriter, req *http.Request, vars map[string]*github.ActionsVariable, name string) bool {
+	v := vars[name]
+	if v == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, v)
+	case http.MethodPatch:
+		body := &github.ActionsVariable{}
+		if !decode(w, req, body) {
+			return true
+		}
+		v.Value = body.Value
+		v.UpdatedAt = &github.Timestamp{Time: now()}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(vars, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// actionsPermissions holds the Actions permissions of a repository, with GitHub defaults
+type actionsPermissions struct {
+	Enabled                      bool     `json:"enabled"`
+	AllowedActions               string   `json:"allowed_actions"`
+	GithubOwnedAllowed           bool     `json:"github_owned_allowed"`
+	VerifiedAllowed              bool     `json:"verified_allowed"`
+	PatternsAllowed              []string `json:"patterns_allowed"`
+	DefaultWorkflowPermissions   string   `json:"default_workflow_permissions"`
+	CanApprovePullRequestReviews bool     `json:"can_approve_pull_request_reviews"`
+	ApprovalPolicy               string   `json:"approval_policy"`
+	RetentionDays                int      `json:"days"`
+}
+
+// newActionsPermissions returns the permissions of a new repository
+func newActionsPermissions() *actionsPermissions {
+	return &actionsPermissions{
+		Enabled:                    true,
+		AllowedActions:             "all",
+		PatternsAllowed:            []string{},
+		DefaultWorkflowPermissions: "read",
+		ApprovalPolicy:             "first_time_contributors",
+		RetentionDays:              90,
+	}
+}
+
+// ActionsPermissions returns the Actions permissions of a repository, by the
+// name of their fields in the API, nil when the repository does not exist
+func (s *Server) ActionsPermissions(owner, repo string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil {
+		return nil
+	}
+
+	p := r.permissions
+	return map[string]interface{}{
+		"enabled":                          p.Enabled,
+		"allowed_actions":                  p.AllowedActions,
+		"github_owned_allowed":             p.GithubOwnedAllowed,
+		"verified_allowed":                 p.VerifiedAllowed,
+		"patterns_allowed":                 append([]string{}, p.PatternsAllowed...),
+		"default_workflow_permissions":     p.DefaultWorkflowPermissions,
+		"can_approve_pull_request_reviews": p.CanApprovePullRequestReviews,
+		"approval_policy":                  p.ApprovalPolicy,
+		"days":                             p.RetentionDays,
+	}
+}
+
+// routePermissions handles the requests to /repos/{owner}/{repo}/actions/permissions/...
+func (s *Server) routePermissions(w http.ResponseWriter, req *http.Request, r *repository, p []string) bool {
+	perms := r.permissions
+	var setting string
+	if len(p) > 0 {
+		setting = p[0]
+	}
+
+	if req.Method == http.MethodGet {
+		switch setting {
+		case "":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"enabled": perms.Enabled, "allowed_actions": perms.AllowedActions})
+		case "selected-actions":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"github_owned_allowed": perms.GithubOwnedAllowed, "verified_allowed": perms.VerifiedAllowed, "patterns_allowed": perms.PatternsAllowed})
+		case "workflow":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"default_workflow_permissions": perms.DefaultWorkflowPermissions, "can_approve_pull_request_reviews": perms.CanApprovePullRequestReviews})
+		case "fork-pr-contributor-approval":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"approval_policy": perms.ApprovalPolicy})
+		case "artifact-and-log-retention":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"days": perms.RetentionDays, "maximum_allowed_days": 400})
+		default:
+			return false
+		}
+		return true
+	}
+
+	if req.Method != http.MethodPut {
+		return false
+	}
+
+	// the fields missing in the body keep their values
+	edit := *perms
+	if !decode(w, req, &edit) {
+		return true
+	}
+
+	switch {
+	case setting == "" && edit.AllowedActions != "all" && edit.AllowedActions != "local_only" && edit.AllowedActions != "selected":
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: allowed_actions is invalid")
+		return true
+	case setting == "selected-actions" && perms.AllowedActions != "selected":
+		writeError(w, http.StatusConflict, "Conflict: allowed_actions must be selected")
+		return true
+	case setting == "workflow" && edit.DefaultWorkflowPermissions != "read" && edit.DefaultWorkflowPermissions != "write":
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: default_workflow_permissions is invalid")
+		return true
+	case setting == "artifact-and-log-retention" && (edit.RetentionDays < 1 || edit.RetentionDays > 400):
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: days must be between 1 and 400")
+		return true
+	case setting != "" && setting != "selected-actions" && setting != "workflow" && setting != "fork-pr-contributor-approval" && setting != "artifact-and-log-retention":
+		return false
+	case setting != "" && !perms.Enabled:
+		writeError(w, http.StatusConflict, "Conflict: Actions are disabled for this repository")
+		return true
+	}
+
+	*perms = edit
+	if setting == "selected-actions" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"github_owned_allowed": perms.GithubOwnedAllowed, "verified_allowed": perms.VerifiedAllowed, "patterns_allowed": perms.PatternsAllowed})
+		return true
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
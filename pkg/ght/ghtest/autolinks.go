@@ -0,0 +1,101 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// AddAutolink registers an autolink reference in a repository
+func (s *Server) AddAutolink(owner, repo, prefix, urlTemplate string, alphanumeric bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		id := s.id()
+		r.autolinks[id] = &github.Autolink{ID: github.Int64(id), KeyPrefix: github.String(prefix), URLTemplate: github.String(urlTemplate), IsAlphanumeric: github.Bool(alphanumeric)}
+	}
+}
+
+// Autolinks returns the autolink references of a repository, sorted by prefix
+func (s *Server) Autolinks(owner, repo string) []*github.Autolink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil {
+		return nil
+	}
+
+	links := sortedAutolinks(r)
+	sort.Slice(links, func(i, j int) bool { return links[i].GetKeyPrefix() < links[j].GetKeyPrefix() })
+	return links
+}
+
+// autolinks handles the requests to /repos/{owner}/{repo}/autolinks
+func (s *Server) autolinks(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sortedAutolinks(r))
+	case http.MethodPost:
+		body := &github.AutolinkOptions{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.GetKeyPrefix() == "" || !strings.Contains(body.GetURLTemplate(), "<num>") {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: url_template must contain <num>")
+			return true
+		}
+		for _, l := range r.autolinks {
+			// the prefixes are case insensitive
+			if strings.EqualFold(l.GetKeyPrefix(), body.GetKeyPrefix()) {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: key_prefix already exists")
+				return true
+			}
+		}
+
+		id := s.id()
+		l := &github.Autolink{ID: github.Int64(id), KeyPrefix: body.KeyPrefix, URLTemplate: body.URLTemplate, IsAlphanumeric: github.Bool(body.IsAlphanumeric == nil || body.GetIsAlphanumeric())}
+		r.autolinks[id] = l
+		writeJSON(w, http.StatusCreated, l)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// autolink handles the requests to /repos/{owner}/{repo}/autolinks/{id}
+func (s *Server) autolink(w http.ResponseWriter, req *http.Request, r *repository, id string) bool {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	l := r.autolinks[n]
+	if l == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, l)
+	case http.MethodDelete:
+		delete(r.autolinks, n)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// sortedAutolinks returns the autolinks of the repository sorted by id
+func sortedAutolinks(r *repository) []*github.Autolink {
+	links := []*github.Autolink{}
+	for _, l := range r.autolinks {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].GetID() < links[j].GetID() })
+
+	return links
+}
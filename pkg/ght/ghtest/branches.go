@@ -0,0 +1,234 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// listBranches handles GET /repos/{owner}/{repo}/branches
+func (s *Server) listBranches(w http.ResponseWriter, r *repository) {
+	names := []string{}
+	for name := range r.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := []*github.Branch{}
+	for _, name := range names {
+		list = append(list, branchJSON(name, r.branches[name]))
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// routeBranch dispatches the requests under /repos/{owner}/{repo}/branches/{branch}
+func (s *Server) routeBranch(w http.ResponseWriter, req *http.Request, r *repository, name string, b *branch, p []string) bool {
+	method := req.Method
+	switch {
+	case len(p) == 0 && method == http.MethodGet:
+		writeJSON(w, http.StatusOK, branchJSON(name, b))
+	case match(p, "protection") && method == http.MethodGet:
+		if b.protection == nil {
+			writeError(w, http.StatusNotFound, "Branch not protected")
+			return true
+		}
+		writeJSON(w, http.StatusOK, b.protection)
+	case match(p, "protection") && method == http.MethodPut:
+		body := &github.ProtectionRequest{}
+		if !decode(w, req, body) {
+			return true
+		}
+		b.protection = protection(body)
+		writeJSON(w, http.StatusOK, b.protection)
+	case match(p, "protection") && method == http.MethodDelete:
+		if b.protection == nil {
+			writeError(w, http.StatusNotFound, "Branch not protected")
+			return true
+		}
+		b.protection = nil
+		b.signatures = false
+		w.WriteHeader(http.StatusNoContent)
+	case match(p, "protection", "required_signatures"):
+		return s.signatures(w, req, b)
+	case match(p, "rename") && method == http.MethodPost:
+		s.renameBranch(w, req, r, name, b)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// renameBranch handles POST /repos/{owner}/{repo}/branches/{branch}/rename,
+// the branch keeps its commits and protection
+func (s *Server) renameBranch(w http.ResponseWriter, req *http.Request, r *repository, name string, b *branch) {
+	body := &struct {
+		NewName string `json:"new_name"`
+	}{}
+	if !decode(w, req, body) {
+		return
+	}
+	if body.NewName == "" || r.branches[body.NewName] != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: new_name already exists")
+		return
+	}
+
+	delete(r.branches, name)
+	r.branches[body.NewName] = b
+	if r.data.GetDefaultBranch() == name {
+		r.data.DefaultBranch = github.String(body.NewName)
+	}
+
+	writeJSON(w, http.StatusCreated, branchJSON(body.NewName, b))
+}
+
+// commit handles GET /repos/{owner}/{repo}/commits/{ref}, answering the sha
+// of the branch or of the commit when asked for the sha media type
+func (s *Server) commit(w http.ResponseWriter, req *http.Request, r *repository, ref string) {
+	sha := ""
+	if b := r.branches[ref]; b != nil {
+		sha = b.sha
+	}
+	for _, b := range r.branches {
+		if b.sha == ref {
+			sha = ref
+		}
+	}
+	if sha == "" {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+ref)
+		return
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "sha") {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(sha))
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.RepositoryCommit{SHA: github.String(sha)})
+}
+
+// createRef handles POST /repos/{owner}/{repo}/git/refs, only the branches are supported
+func (s *Server) createRef(w http.ResponseWriter, req *http.Request, r *repository) {
+	body := &struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	}{}
+	if !decode(w, req, body) {
+		return
+	}
+
+	name := strings.TrimPrefix(body.Ref, "refs/heads/")
+	if name == body.Ref || name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Reference name is invalid")
+		return
+	}
+	if r.branches[name] != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Reference already exists")
+		return
+	}
+	known := false
+	for _, b := range r.branches {
+		known = known || b.sha == body.SHA
+	}
+	if !known {
+		writeError(w, http.StatusUnprocessableEntity, "Object does not exist")
+		return
+	}
+
+	r.branches[name] = &branch{sha: body.SHA}
+	writeJSON(w, http.StatusCreated, &github.Reference{
+		Ref:    github.String(body.Ref),
+		Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(body.SHA)},
+	})
+}
+
+// signatures handles the requests to /repos/{owner}/{repo}/branches/{branch}/protection/required_signatures
+func (s *Server) signatures(w http.ResponseWriter, req *http.Request, b *branch) bool {
+	if b.protection == nil {
+		writeError(w, http.StatusNotFound, "Branch not protected")
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, &github.SignaturesProtectedBranch{Enabled: github.Bool(b.signatures)})
+	case http.MethodPost:
+		b.signatures = true
+		writeJSON(w, http.StatusOK, &github.SignaturesProtectedBranch{Enabled: github.Bool(true)})
+	case http.MethodDelete:
+		b.signatures = false
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+func branchJSON(name string, b *branch) *github.Branch {
+	return &github.Branch{
+		Name:      github.String(name),
+		Commit:    &github.RepositoryCommit{SHA: github.String(b.sha)},
+		Protected: github.Bool(b.protection != nil),
+	}
+}
+
+// protection returns the protection GitHub responds with after the request is applied
+func protection(req *github.ProtectionRequest) *github.Protection {
+	p := &github.Protection{
+		RequiredStatusChecks:           req.RequiredStatusChecks,
+		EnforceAdmins:                  &github.AdminEnforcement{Enabled: req.EnforceAdmins},
+		RequireLinearHistory:           &github.RequireLinearHistory{Enabled: req.GetRequireLinearHistory()},
+		AllowForcePushes:               &github.AllowForcePushes{Enabled: req.GetAllowForcePushes()},
+		AllowDeletions:                 &github.AllowDeletions{Enabled: req.GetAllowDeletions()},
+		RequiredConversationResolution: &github.RequiredConversationResolution{Enabled: req.GetRequiredConversationResolution()},
+		BlockCreations:                 &github.BlockCreations{Enabled: github.Bool(req.GetBlockCreations())},
+		LockBranch:                     &github.LockBranch{Enabled: github.Bool(req.GetLockBranch())},
+		AllowForkSyncing:               &github.AllowForkSyncing{Enabled: github.Bool(req.GetAllowForkSyncing())},
+	}
+
+	if r := req.RequiredPullRequestReviews; r != nil {
+		p.RequiredPullRequestReviews = &github.PullRequestReviewsEnforcement{
+			DismissStaleReviews:          r.DismissStaleReviews,
+			RequireCodeOwnerReviews:      r.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: r.RequiredApprovingReviewCount,
+			RequireLastPushApproval:      r.GetRequireLastPushApproval(),
+		}
+
+		if d := r.DismissalRestrictionsRequest; d != nil && d.Users != nil {
+			users, teams, apps := actors(d.GetUsers(), d.GetTeams(), d.GetApps())
+			p.RequiredPullRequestReviews.DismissalRestrictions = &github.DismissalRestrictions{Users: users, Teams: teams, Apps: apps}
+		}
+
+		if b := r.BypassPullRequestAllowancesRequest; b != nil {
+			users, teams, apps := actors(b.Users, b.Teams, b.Apps)
+			p.RequiredPullRequestReviews.BypassPullRequestAllowances = &github.BypassPullRequestAllowances{Users: users, Teams: teams, Apps: apps}
+		}
+	}
+
+	if r := req.Restrictions; r != nil {
+		users, teams, apps := actors(r.Users, r.Teams, r.Apps)
+		p.Restrictions = &github.BranchRestrictions{Users: users, Teams: teams, Apps: apps}
+	}
+
+	return p
+}
+
+// actors returns the users, teams and apps named by their logins and slugs
+func actors(logins, teamSlugs, appSlugs []string) ([]*github.User, []*github.Team, []*github.App) {
+	users, teams, apps := []*github.User{}, []*github.Team{}, []*github.App{}
+	for _, login := range logins {
+		users = append(users, &github.User{Login: github.String(login)})
+	}
+	for _, slug := range teamSlugs {
+		teams = append(teams, &github.Team{Slug: github.String(slug)})
+	}
+	for _, slug := range appSlugs {
+		apps = append(apps, &github.App{Slug: github.String(slug)})
+	}
+
+	return users, teams, apps
+}
@@ -0,0 +1,92 @@
+package ghtest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// AddCheckRun reports a completed check run, by the app with the given id, on
+// the last commit of the default branch of a repository
+func (s *Server) AddCheckRun(owner, repo, name string, appID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		if b := r.branches[r.data.GetDefaultBranch()]; b != nil {
+			r.checkRuns[b.sha] = append(r.checkRuns[b.sha], &github.CheckRun{
+				ID:         github.Int64(s.id()),
+				HeadSHA:    github.String(b.sha),
+				Name:       github.String(name),
+				Status:     github.String("completed"),
+				Conclusion: github.String("success"),
+				App:        &github.App{ID: github.Int64(appID)},
+			})
+		}
+	}
+}
+
+// AddStatus reports a successful commit status on the last commit of the
+// default branch of a repository
+func (s *Server) AddStatus(owner, repo, context string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		if b := r.branches[r.data.GetDefaultBranch()]; b != nil {
+			r.statuses[b.sha] = append(r.statuses[b.sha], &github.RepoStatus{
+				ID:      github.Int64(s.id()),
+				Context: github.String(context),
+				State:   github.String("success"),
+			})
+		}
+	}
+}
+
+// Commit adds a commit to the default branch of a repository, the checks
+// reported afterwards are on the new commit
+func (s *Server) Commit(owner, repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		s.addCommit(r)
+	}
+}
+
+// listCommits handles GET /repos/{owner}/{repo}/commits, listing the commits
+// of the branch given by the sha parameter, the default branch when empty
+func (s *Server) listCommits(w http.ResponseWriter, req *http.Request, r *repository) {
+	if len(r.branches) == 0 {
+		writeError(w, http.StatusConflict, "Git Repository is empty.")
+		return
+	}
+
+	name := req.URL.Query().Get("sha")
+	if name == "" {
+		name = r.data.GetDefaultBranch()
+	}
+	b := r.branches[name]
+	if b == nil {
+		writeError(w, http.StatusNotFound, "No commit found for SHA: "+name)
+		return
+	}
+
+	shas := append([]string{b.sha}, b.history...)
+	if n, err := strconv.Atoi(req.URL.Query().Get("per_page")); err == nil && n > 0 && n < len(shas) {
+		shas = shas[:n]
+	}
+
+	list := []*github.RepositoryCommit{}
+	for _, sha := range shas {
+		list = append(list, &github.RepositoryCommit{SHA: github.String(sha)})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// listCheckRuns handles GET /repos/{owner}/{repo}/commits/{ref}/check-runs
+func (s *Server) listCheckRuns(w http.ResponseWriter, r *repository, ref string) {
+	runs := append([]*github.CheckRun{}, r.checkRuns[ref]...)
+	writeJSON(w, http.StatusOK, &github.ListCheckRunsResults{Total: github.Int(len(runs)), CheckRuns: runs})
+}
@@ -0,0 +1,126 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// codeQLLanguages maps the languages of the repositories to the languages of
+// the CodeQL default setup
+var codeQLLanguages = map[string]string{
+	"C":          "c-cpp",
+	"C++":        "c-cpp",
+	"C#":         "csharp",
+	"Go":         "go",
+	"Java":       "java-kotlin",
+	"Kotlin":     "java-kotlin",
+	"JavaScript": "javascript-typescript",
+	"TypeScript": "javascript-typescript",
+	"Python":     "python",
+	"Ruby":       "ruby",
+	"Swift":      "swift",
+}
+
+// codeScanning is the CodeQL default setup of a repository
+type codeScanning struct {
+	State      string            `json:"state"`
+	Languages  []string          `json:"languages"`
+	QuerySuite string            `json:"query_suite"`
+	UpdatedAt  *github.Timestamp `json:"updated_at"`
+	Schedule   *string           `json:"schedule"`
+}
+
+// SetLanguages sets the languages of a repository, with their number of bytes
+func (s *Server) SetLanguages(owner, repo string, languages map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		r.languages = languages
+	}
+}
+
+// CodeScanning returns the state, query suite and languages of the CodeQL
+// default setup of a repository
+func (s *Server) CodeScanning(owner, repo string) (string, string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil || r.codeScanning == nil {
+		return "not-configured", "", nil
+	}
+
+	return r.codeScanning.State, r.codeScanning.QuerySuite, r.codeScanning.Languages
+}
+
+// defaultSetup handles the requests to /repos/{owner}/{repo}/code-scanning/default-setup
+func (s *Server) defaultSetup(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	if r.codeScanning == nil {
+		r.codeScanning = &codeScanning{State: "not-configured", Languages: []string{}, QuerySuite: "default"}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, r.codeScanning)
+	case http.MethodPatch:
+		body := &codeScanning{}
+		if !decode(w, req, body) {
+			return true
+		}
+
+		detected := map[string]bool{}
+		for language := range r.languages {
+			if l, ok := codeQLLanguages[language]; ok {
+				detected[l] = true
+			}
+		}
+
+		supported := map[string]bool{}
+		for _, l := range codeQLLanguages {
+			supported[l] = true
+		}
+		for _, l := range body.Languages {
+			if !supported[l] {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: unsupported language "+l)
+				return true
+			}
+		}
+
+		setup := *r.codeScanning
+		if body.State != "" {
+			setup.State = body.State
+		}
+		if body.QuerySuite != "" {
+			setup.QuerySuite = body.QuerySuite
+		}
+		if body.Languages != nil {
+			setup.Languages = append([]string{}, body.Languages...)
+		}
+		if setup.State == "configured" && len(setup.Languages) == 0 {
+			for l := range detected {
+				setup.Languages = append(setup.Languages, l)
+			}
+		}
+		if setup.State == "configured" && len(setup.Languages) == 0 {
+			writeError(w, http.StatusUnprocessableEntity, "Code scanning default setup is not supported for the languages of this repository")
+			return true
+		}
+		sort.Strings(setup.Languages)
+		setup.UpdatedAt = &github.Timestamp{Time: now()}
+		setup.Schedule = github.String("weekly")
+		if setup.State == "not-configured" {
+			setup.Languages = []string{}
+			setup.Schedule = nil
+		}
+		r.codeScanning = &setup
+
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"run_id": s.id(), "run_url": s.URL + "/repos/" + r.data.GetFullName() + "/actions/runs/1"})
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package ghtest
+
+import (
+	"encoding/base64"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// contents handles the requests to /repos/{owner}/{repo}/contents/{path}, the
+// files are kept on the default branch whatever branch is asked for
+func (s *Server) contents(w http.ResponseWriter, req *http.Request, r *repository, p string) bool {
+	switch req.Method {
+	case http.MethodGet:
+		s.getContents(w, req, r, p)
+	case http.MethodPut:
+		s.putContents(w, req, r, p)
+	case http.MethodDelete:
+		s.deleteContents(w, req, r, p)
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) getContents(w http.ResponseWriter, req *http.Request, r *repository, p string) {
+	if data, ok := r.contents[p]; ok {
+		if strings.Contains(req.Header.Get("Accept"), "raw") {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(data)
+			return
+		}
+
+		file := fileJSON(r, p, data)
+		file.Encoding = github.String("base64")
+		file.Content = github.String(base64.StdEncoding.EncodeToString(data))
+		writeJSON(w, http.StatusOK, file)
+		return
+	}
+
+	// list the files and directories right under the directory
+	prefix := ""
+	if p != "" {
+		prefix = p + "/"
+	}
+	entries := map[string]*github.RepositoryContent{}
+	for name, data := range r.contents {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			dir := prefix + rest[:i]
+			entries[dir] = &github.RepositoryContent{Type: github.String("dir"), Name: github.String(path.Base(dir)), Path: github.String(dir)}
+			continue
+		}
+		entries[name] = fileJSON(r, name, data)
+	}
+
+	if len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	list := []*github.RepositoryContent{}
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GetPath() < list[j].GetPath() })
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) putContents(w http.ResponseWriter, req *http.Request, r *repository, p string) {
+	body := &github.RepositoryContentFileOptions{}
+	if !decode(w, req, body) {
+		return
+	}
+
+	status := http.StatusCreated
+	if current, ok := r.contents[p]; ok {
+		if body.SHA == nil {
+			writeError(w, http.StatusUnprocessableEntity, `Invalid request. "sha" wasn't supplied.`)
+			return
+		}
+		if body.GetSHA() != blobSHA(current) {
+			writeError(w, http.StatusConflict, p+" does not match "+body.GetSHA())
+			return
+		}
+		status = http.StatusOK
+	}
+
+	sha := s.writeFile(r, p, body.Content)
+	writeJSON(w, status, &github.RepositoryContentResponse{
+		Content: fileJSON(r, p, body.Content),
+		Commit:  github.Commit{SHA: github.String(sha), Message: body.Message},
+	})
+}
+
+func (s *Server) deleteContents(w http.ResponseWriter, req *http.Request, r *repository, p string) {
+	body := &github.RepositoryContentFileOptions{}
+	if !decode(w, req, body) {
+		return
+	}
+
+	current, ok := r.contents[p]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if body.GetSHA() != blobSHA(current) {
+		writeError(w, http.StatusConflict, p+" does not match "+body.GetSHA())
+		return
+	}
+
+	delete(r.contents, p)
+	b := r.branches[r.data.GetDefaultBranch()]
+	b.sha = commitSHA(s.id())
+
+	writeJSON(w, http.StatusOK, &github.RepositoryContentResponse{
+		Commit: github.Commit{SHA: github.String(b.sha), Message: body.Message},
+	})
+}
+
+// fileJSON returns the metadata of a file, without its content
+func fileJSON(r *repository, p string, data []byte) *github.RepositoryContent {
+	return &github.RepositoryContent{
+		Type:    github.String("file"),
+		Size:    github.Int(len(data)),
+		Name:    github.String(path.Base(p)),
+		Path:    github.String(p),
+		SHA:     github.String(blobSHA(data)),
+		HTMLURL: github.String(r.data.GetHTMLURL() + "/blob/" + r.data.GetDefaultBranch() + "/" + p),
+	}
+}
@@ -0,0 +1,305 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// environment is a deployment environment of a repository
+type environment struct {
+	data      *github.Environment
+	policies  map[int64]*policy
+	secrets   *secrets
+	variables map[string]*github.ActionsVariable
+}
+
+// policy is a deployment branch or tag policy
+type policy struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// AddUser registers a user, so that it can be referenced by login
+func (s *Server) AddUser(login string) *github.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := &github.User{ID: github.Int64(s.id()), Login: github.String(login), Type: github.String("User")}
+	s.users[key(login)] = u
+
+	return u
+}
+
+// Environment returns a deployment environment and its policies, nil when it does not exist
+func (s *Server) Environment(owner, repo, name string) (*github.Environment, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil || r.environments[name] == nil {
+		return nil, nil
+	}
+
+	env := r.environments[name]
+	var policies []string
+	for _, p := range sortedPolicies(env) {
+		policies = append(policies, p.Type+":"+p.Name)
+	}
+
+	data := *env.data
+	return &data, policies
+}
+
+// EnvSecret returns the decrypted value of an environment secret and whether it exists
+func (s *Server) EnvSecret(owner, repo, env, name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil && r.environments[env] != nil {
+		if v := r.environments[env].secrets.values[name]; v != nil {
+			return v.value, true
+		}
+	}
+
+	return "", false
+}
+
+// EnvVariable returns the value of an environment variable and whether it exists
+func (s *Server) EnvVariable(owner, repo, env, name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil && r.environments[env] != nil {
+		if v := r.environments[env].variables[name]; v != nil {
+			return v.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// getUser handles GET /users/{login}
+func (s *Server) getUser(w http.ResponseWriter, login string) bool {
+	u := s.users[key(login)]
+	if u == nil {
+		return false
+	}
+
+	writeJSON(w, http.StatusOK, u)
+	return true
+}
+
+// routeEnvironments handles the requests to /repos/{owner}/{repo}/environments/...
+func (s *Server) routeEnvironments(w http.ResponseWriter, req *http.Request, r *repository, p []string) bool {
+	if len(p) == 0 {
+		if req.Method != http.MethodGet {
+			return false
+		}
+		list := &github.EnvResponse{Environments: []*github.Environment{}}
+		for _, name := range sortedEnvironments(r) {
+			list.Environments = append(list.Environments, r.environments[name].data)
+		}
+		list.TotalCount = github.Int(len(list.Environments))
+		writeJSON(w, http.StatusOK, list)
+		return true
+	}
+
+	name := p[0]
+	env := r.environments[name]
+
+	switch {
+	case len(p) == 1 && req.Method == http.MethodPut:
+		s.putEnvironment(w, req, r, name)
+	case env == nil:
+		return false
+	case len(p) == 1 && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, env.data)
+	case len(p) == 1 && req.Method == http.MethodDelete:
+		delete(r.environments, name)
+		w.WriteHeader(http.StatusNoContent)
+	case match(p[1:], "deployment-branch-policies"):
+		return s.policies(w, req, env)
+	case match(p[1:], "deployment-branch-policies", "*") && req.Method == http.MethodDelete:
+		id, _ := strconv.ParseInt(p[2], 10, 64)
+		if env.policies[id] == nil {
+			return false
+		}
+		delete(env.policies, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// routeRepositoryID handles the requests to /repositories/{id}/environments/{name}/..., used by
+// the environment secrets and variables
+func (s *Server) routeRepositoryID(w http.ResponseWriter, req *http.Request, p []string) bool {
+	var r *repository
+	for _, repo := range s.repos {
+		if strconv.FormatInt(repo.data.GetID(), 10) == p[1] {
+			r = repo
+		}
+	}
+	if r == nil || len(p) < 5 || p[2] != "environments" {
+		return false
+	}
+
+	env := r.environments[p[3]]
+	if env == nil {
+		return false
+	}
+
+	p = p[4:]
+	switch {
+	case match(p, "secrets", "public-key") && req.Method == http.MethodGet:
+		s.publicKey(w, env.secrets)
+	case match(p, "secrets") && req.Method == http.MethodGet:
+		s.listSecrets(w, env.secrets)
+	case match(p, "secrets", "*"):
+		return s.secret(w, req, env.secrets, p[1])
+	case match(p, "variables"):
+		return s.variables(w, req, env.variables)
+	case match(p, "variables", "*"):
+		return s.variable(w, req, env.variables, p[1])
+	default:
+		return false
+	}
+
+	return true
+}
+
+// putEnvironment handles PUT /repos/{owner}/{repo}/environments/{name}
+func (s *Server) putEnvironment(w http.ResponseWriter, req *http.Request, r *repository, name string) {
+	body := &github.CreateUpdateEnvironment{}
+	if req.ContentLength != 0 && !decode(w, req, body) {
+		return
+	}
+
+	branchPolicy := body.DeploymentBranchPolicy
+	if branchPolicy != nil && branchPolicy.GetProtectedBranches() == branchPolicy.GetCustomBranchPolicies() {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: only one of protected_branches and custom_branch_policies can be true")
+		return
+	}
+
+	var rules []*github.ProtectionRule
+	if body.GetWaitTimer() > 0 {
+		rules = append(rules, &github.ProtectionRule{ID: github.Int64(s.id()), Type: github.String("wait_timer"), WaitTimer: body.WaitTimer})
+	}
+	if len(body.Reviewers) > 0 {
+		rule := &github.ProtectionRule{ID: github.Int64(s.id()), Type: github.String("required_reviewers")}
+		for _, reviewer := range body.Reviewers {
+			found := s.reviewer(reviewer)
+			if found == nil {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: reviewer not found")
+				return
+			}
+			rule.Reviewers = append(rule.Reviewers, found)
+		}
+		rules = append(rules, rule)
+	}
+	if branchPolicy != nil {
+		rules = append(rules, &github.ProtectionRule{ID: github.Int64(s.id()), Type: github.String("branch_policy")})
+	}
+
+	env := r.environments[name]
+	if env == nil {
+		env = &environment{
+			data:      &github.Environment{ID: github.Int64(s.id()), Name: github.String(name)},
+			policies:  map[int64]*policy{},
+			secrets:   newSecrets(s.id()),
+			variables: map[string]*github.ActionsVariable{},
+		}
+		r.environments[name] = env
+	}
+	env.data.ProtectionRules = rules
+	env.data.DeploymentBranchPolicy = branchPolicy
+	if branchPolicy == nil || !branchPolicy.GetCustomBranchPolicies() {
+		env.policies = map[int64]*policy{}
+	}
+
+	writeJSON(w, http.StatusOK, env.data)
+}
+
+// reviewer returns the user or team referenced by its id, nil when it is unknown
+func (s *Server) reviewer(r *github.EnvReviewers) *github.RequiredReviewer {
+	switch r.GetType() {
+	case "User":
+		for _, u := range s.users {
+			if u.GetID() == r.GetID() {
+				return &github.RequiredReviewer{Type: github.String("User"), Reviewer: u}
+			}
+		}
+	case "Team":
+		for _, teams := range s.teams {
+			for _, t := range teams {
+				if t.GetID() == r.GetID() {
+					return &github.RequiredReviewer{Type: github.String("Team"), Reviewer: t}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// policies handles the requests to .../environments/{name}/deployment-branch-policies
+func (s *Server) policies(w http.ResponseWriter, req *http.Request, env *environment) bool {
+	switch req.Method {
+	case http.MethodGet:
+		list := sortedPolicies(env)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"total_count": len(list), "branch_policies": list})
+	case http.MethodPost:
+		if !env.data.GetDeploymentBranchPolicy().GetCustomBranchPolicies() {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return true
+		}
+		body := &policy{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.Type == "" {
+			body.Type = "branch"
+		}
+		for _, p := range env.policies {
+			if p.Name == body.Name && p.Type == body.Type {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: policy already exists")
+				return true
+			}
+		}
+		body.ID = s.id()
+		env.policies[body.ID] = body
+		writeJSON(w, http.StatusOK, body)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// sortedEnvironments returns the names of the environments of the repository sorted
+func sortedEnvironments(r *repository) []string {
+	names := []string{}
+	for name := range r.environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// sortedPolicies returns the policies of the environment sorted by id
+func sortedPolicies(env *environment) []*policy {
+	list := []*policy{}
+	for _, p := range env.policies {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
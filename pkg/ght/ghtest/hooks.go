@@ -0,0 +1,167 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// hook is a repository webhook, its secret is never returned
+type hook struct {
+	data   *github.Hook
+	secret string
+}
+
+// AddHook registers a webhook in a repository, the secret is taken from the config
+func (s *Server) AddHook(owner, repo string, h *github.Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		s.saveHook(r, &hook{data: &github.Hook{ID: github.Int64(s.id())}}, h)
+	}
+}
+
+// Hooks returns the webhooks of a repository sorted by id, their secrets redacted
+func (s *Server) Hooks(owner, repo string) []*github.Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hooks []*github.Hook
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		hooks = sortedHooks(r)
+	}
+
+	return hooks
+}
+
+// HookSecret returns the secret of the webhook with the url, empty when it has none
+func (s *Server) HookSecret(owner, repo, url string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		for _, h := range r.hooks {
+			if h.data.Config["url"] == url {
+				return h.secret
+			}
+		}
+	}
+
+	return ""
+}
+
+// hooks handles the requests to /repos/{owner}/{repo}/hooks
+func (s *Server) hooks(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sortedHooks(r))
+	case http.MethodPost:
+		body := &github.Hook{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if _, ok := body.Config["url"].(string); !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: url is missing")
+			return true
+		}
+		h := &hook{data: &github.Hook{ID: github.Int64(s.id())}}
+		s.saveHook(r, h, body)
+		writeJSON(w, http.StatusCreated, redactHook(h))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// hook handles the requests to /repos/{owner}/{repo}/hooks/{id}
+func (s *Server) hook(w http.ResponseWriter, req *http.Request, r *repository, id string) bool {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return false
+	}
+	h := r.hooks[n]
+	if h == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, redactHook(h))
+	case http.MethodPatch:
+		body := &github.Hook{}
+		if !decode(w, req, body) {
+			return true
+		}
+		s.saveHook(r, h, body)
+		writeJSON(w, http.StatusOK, redactHook(h))
+	case http.MethodDelete:
+		delete(r.hooks, n)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// saveHook applies the fields of the request to the hook, the config is replaced
+// as a whole like GitHub does. The lock must be held.
+func (s *Server) saveHook(r *repository, h *hook, req *github.Hook) {
+	if req.Config != nil {
+		config := map[string]interface{}{"content_type": "form", "insecure_ssl": "0"}
+		h.secret = ""
+		for k, v := range req.Config {
+			if k == "secret" {
+				h.secret, _ = v.(string)
+				continue
+			}
+			config[k] = v
+		}
+		h.data.Config = config
+	}
+	if req.Events != nil {
+		h.data.Events = req.Events
+	}
+	if h.data.Events == nil {
+		h.data.Events = []string{"push"}
+	}
+	if req.Active != nil {
+		h.data.Active = req.Active
+	}
+	if h.data.Active == nil {
+		h.data.Active = github.Bool(true)
+	}
+	h.data.Type = github.String("Repository")
+	h.data.Name = github.String("web")
+
+	r.hooks[h.data.GetID()] = h
+}
+
+// redactHook returns a copy of the hook with its secret masked
+func redactHook(h *hook) *github.Hook {
+	data := *h.data
+	data.Config = map[string]interface{}{}
+	for k, v := range h.data.Config {
+		data.Config[k] = v
+	}
+	if h.secret != "" {
+		data.Config["secret"] = "********"
+	}
+
+	return &data
+}
+
+// sortedHooks returns the hooks of the repository sorted by id, their secrets redacted
+func sortedHooks(r *repository) []*github.Hook {
+	list := []*github.Hook{}
+	for _, h := range r.hooks {
+		list = append(list, redactHook(h))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GetID() < list[j].GetID() })
+
+	return list
+}
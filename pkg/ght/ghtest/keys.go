@@ -0,0 +1,115 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// keyBlob returns the type and data of a public key, without its comment
+func keyBlob(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return key
+	}
+
+	return fields[0] + " " + fields[1]
+}
+
+// AddDeployKey registers a deploy key in a repository
+func (s *Server) AddDeployKey(owner, repo, title, publicKey string, readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		id := s.id()
+		r.keys[id] = &github.Key{ID: github.Int64(id), Title: github.String(title), Key: github.String(keyBlob(publicKey)), ReadOnly: github.Bool(readOnly), Verified: github.Bool(true)}
+	}
+}
+
+// DeployKeys returns the deploy keys of a repository, sorted by title
+func (s *Server) DeployKeys(owner, repo string) []*github.Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil {
+		return nil
+	}
+
+	keys := sortedKeys(r)
+	sort.Slice(keys, func(i, j int) bool { return keys[i].GetTitle() < keys[j].GetTitle() })
+	return keys
+}
+
+// deployKeys handles the requests to /repos/{owner}/{repo}/keys
+func (s *Server) deployKeys(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sortedKeys(r))
+	case http.MethodPost:
+		body := &github.Key{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.GetKey() == "" {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: key is missing")
+			return true
+		}
+
+		// a deploy key can only be used once across GitHub
+		blob := keyBlob(body.GetKey())
+		for _, repo := range s.repos {
+			for _, k := range repo.keys {
+				if k.GetKey() == blob {
+					writeError(w, http.StatusUnprocessableEntity, "Validation Failed: key is already in use")
+					return true
+				}
+			}
+		}
+
+		id := s.id()
+		k := &github.Key{ID: github.Int64(id), Title: body.Title, Key: github.String(blob), ReadOnly: github.Bool(body.GetReadOnly()), Verified: github.Bool(true)}
+		r.keys[id] = k
+		writeJSON(w, http.StatusCreated, k)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// deployKey handles the requests to /repos/{owner}/{repo}/keys/{id}
+func (s *Server) deployKey(w http.ResponseWriter, req *http.Request, r *repository, id string) bool {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	k := r.keys[n]
+	if k == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, k)
+	case http.MethodDelete:
+		delete(r.keys, n)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// sortedKeys returns the deploy keys of the repository sorted by id
+func sortedKeys(r *repository) []*github.Key {
+	keys := []*github.Key{}
+	for _, k := range r.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].GetID() < keys[j].GetID() })
+
+	return keys
+}
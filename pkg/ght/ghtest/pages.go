@@ -0,0 +1,165 @@
+package ghtest
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// pages is the GitHub Pages site of a repository
+type pages struct {
+	URL              string                        `json:"url"`
+	HTMLURL          string                        `json:"html_url"`
+	Status           string                        `json:"status"`
+	BuildType        string                        `json:"build_type"`
+	Source           *github.PagesSource           `json:"source,omitempty"`
+	CNAME            *string                       `json:"cname"`
+	HTTPSEnforced    bool                          `json:"https_enforced"`
+	Public           bool                          `json:"public"`
+	HTTPSCertificate *github.PagesHTTPSCertificate `json:"https_certificate,omitempty"`
+
+	// pinned keeps the certificate in its state, otherwise it is approved once read
+	pinned bool
+}
+
+// pagesRequest is the body of the requests creating and updating a site
+type pagesRequest struct {
+	BuildType     *string             `json:"build_type"`
+	Source        *github.PagesSource `json:"source"`
+	CNAME         *string             `json:"cname"`
+	HTTPSEnforced *bool               `json:"https_enforced"`
+	Public        *bool               `json:"public"`
+}
+
+// Pages returns the GitHub Pages site of a repository, nil when disabled, and its build type
+func (s *Server) Pages(owner, repo string) (*github.Pages, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil || r.pages == nil {
+		return nil, ""
+	}
+
+	p := r.pages
+	site := &github.Pages{
+		Status:           github.String(p.Status),
+		CNAME:            p.CNAME,
+		Source:           p.Source,
+		Public:           github.Bool(p.Public),
+		HTTPSCertificate: p.HTTPSCertificate,
+		HTTPSEnforced:    github.Bool(p.HTTPSEnforced),
+	}
+
+	return site, p.BuildType
+}
+
+// SetPagesCertificate sets the state of the certificate of the custom domain,
+// such as errored, which is then kept as it is
+func (s *Server) SetPagesCertificate(owner, repo, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil && r.pages != nil && r.pages.HTTPSCertificate != nil {
+		r.pages.HTTPSCertificate.State = github.String(state)
+		r.pages.pinned = true
+	}
+}
+
+// pagesSite handles the requests to /repos/{owner}/{repo}/pages
+func (s *Server) pagesSite(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	if req.Method == http.MethodPost {
+		if r.pages != nil {
+			writeError(w, http.StatusConflict, "GitHub Pages is already enabled.")
+			return true
+		}
+
+		body := &pagesRequest{}
+		if !decode(w, req, body) {
+			return true
+		}
+		p := &pages{
+			URL:       s.URL + "/repos/" + r.data.GetFullName() + "/pages",
+			HTMLURL:   "https://" + r.data.GetOwner().GetLogin() + ".github.io/" + r.data.GetName() + "/",
+			Status:    "built",
+			BuildType: "legacy",
+			Public:    true,
+		}
+		if body.BuildType != nil {
+			p.BuildType = *body.BuildType
+		}
+		if p.BuildType == "legacy" {
+			if body.Source == nil || body.Source.GetBranch() == "" {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: source branch is required")
+				return true
+			}
+			p.Source = &github.PagesSource{Branch: body.Source.Branch, Path: github.String("/")}
+			if body.Source.Path != nil {
+				p.Source.Path = body.Source.Path
+			}
+		}
+		r.pages = p
+
+		writeJSON(w, http.StatusCreated, p)
+		return true
+	}
+
+	if r.pages == nil {
+		return false
+	}
+	p := r.pages
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, p)
+		// GitHub issues the certificate of a custom domain in the background
+		if c := p.HTTPSCertificate; c != nil && !p.pinned {
+			c.State = github.String("approved")
+		}
+	case http.MethodPut:
+		body := &pagesRequest{}
+		if !decode(w, req, body) {
+			return true
+		}
+		if body.BuildType != nil {
+			p.BuildType = *body.BuildType
+		}
+		if body.Source != nil {
+			p.Source = body.Source
+		}
+		cname := ""
+		if p.CNAME != nil {
+			cname = *p.CNAME
+		}
+		if body.CNAME != nil && *body.CNAME != cname {
+			// a new domain needs a new certificate
+			p.CNAME = body.CNAME
+			p.HTTPSEnforced = false
+			p.HTTPSCertificate = nil
+			p.pinned = false
+			if *body.CNAME == "" {
+				p.CNAME = nil
+			} else {
+				p.HTTPSCertificate = &github.PagesHTTPSCertificate{State: github.String("new"), Domains: []string{*body.CNAME}}
+			}
+		}
+		if body.HTTPSEnforced != nil {
+			if *body.HTTPSEnforced && p.CNAME != nil && p.HTTPSCertificate.GetState() != "approved" {
+				writeError(w, http.StatusNotFound, "The certificate does not exist yet")
+				return true
+			}
+			p.HTTPSEnforced = *body.HTTPSEnforced
+		}
+		if body.Public != nil {
+			p.Public = *body.Public
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		r.pages = nil
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
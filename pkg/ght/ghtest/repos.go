@@ -0,0 +1,354 @@
+package ghtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/v50/github"
+)
+
+func (s *Server) getOrg(w http.ResponseWriter, login string) {
+	org := s.orgs[key(login)]
+	if org == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, org)
+}
+
+// createRepo handles POST /user/repos and POST /orgs/{org}/repos
+func (s *Server) createRepo(w http.ResponseWriter, req *http.Request, owner string) {
+	repo := &github.Repository{}
+	if !decode(w, req, repo) {
+		return
+	}
+
+	if repo.GetName() == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name is missing")
+		return
+	}
+	if s.repos[key(owner+"/"+repo.GetName())] != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name already exists on this account")
+		return
+	}
+
+	// topics can't be set when a repository is created
+	repo.Topics = nil
+
+	r := s.newRepository(owner, repo)
+	if repo.GetAutoInit() {
+		s.initBranch(r)
+	}
+
+	writeJSON(w, http.StatusCreated, r.data)
+}
+
+// generateRepo handles POST /repos/{template_owner}/{template_repo}/generate
+func (s *Server) generateRepo(w http.ResponseWriter, req *http.Request, tmpl *repository) {
+	body := &github.TemplateRepoRequest{}
+	if !decode(w, req, body) {
+		return
+	}
+
+	if !tmpl.data.GetIsTemplate() {
+		writeError(w, http.StatusUnprocessableEntity, tmpl.data.GetFullName()+" is not a template repository")
+		return
+	}
+
+	owner := body.GetOwner()
+	if owner == "" {
+		owner = s.Login
+	}
+	if key(owner) != key(s.Login) && s.orgs[key(owner)] == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if s.repos[key(owner+"/"+body.GetName())] != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name already exists on this account")
+		return
+	}
+
+	r := s.newRepository(owner, &github.Repository{
+		Name:          body.Name,
+		Description:   body.Description,
+		Private:       body.Private,
+		DefaultBranch: tmpl.data.DefaultBranch,
+	})
+	for path, content := range tmpl.contents {
+		r.contents[path] = content
+	}
+	for name := range tmpl.branches {
+		if name == tmpl.data.GetDefaultBranch() || body.GetIncludeAllBranches() {
+			r.branches[name] = &branch{sha: commitSHA(s.id())}
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, r.data)
+}
+
+// editRepo handles PATCH /repos/{owner}/{repo}
+func (s *Server) editRepo(w http.ResponseWriter, req *http.Request, r *repository) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	edit := &github.Repository{}
+	if err := json.Unmarshal(body, edit); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	if edit.DefaultBranch != nil && r.branches[edit.GetDefaultBranch()] == nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: default_branch does not exist")
+		return
+	}
+
+	owner := r.data.GetOwner().GetLogin()
+	if edit.Name != nil && key(edit.GetName()) != key(r.data.GetName()) {
+		if s.repos[key(owner+"/"+edit.GetName())] != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name already exists on this account")
+			return
+		}
+		delete(s.repos, key(r.data.GetFullName()))
+		s.repos[key(owner+"/"+edit.GetName())] = r
+	}
+
+	if edit.SecurityAndAnalysis != nil && !editSecurityAndAnalysis(r, edit.SecurityAndAnalysis) {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: secret scanning must be enabled to enable push protection")
+		return
+	}
+	analysis := r.data.SecurityAndAnalysis
+
+	// the fields missing in the body keep their values
+	_ = json.Unmarshal(body, r.data)
+	r.data.SecurityAndAnalysis = analysis
+	r.data.FullName = github.String(owner + "/" + r.data.GetName())
+	if edit.Private != nil && edit.Visibility == nil {
+		r.data.Visibility = github.String("public")
+		if r.data.GetPrivate() {
+			r.data.Visibility = github.String("private")
+		}
+	}
+
+	writeJSON(w, http.StatusOK, r.data)
+}
+
+// replaceTopics handles PUT /repos/{owner}/{repo}/topics
+func (s *Server) replaceTopics(w http.ResponseWriter, req *http.Request, r *repository) {
+	body := struct {
+		Names []string `json:"names"`
+	}{}
+	if !decode(w, req, &body) {
+		return
+	}
+
+	if body.Names == nil {
+		body.Names = []string{}
+	}
+	r.data.Topics = body.Names
+
+	writeJSON(w, http.StatusOK, map[string][]string{"names": r.data.Topics})
+}
+
+func (s *Server) listTeams(w http.ResponseWriter, org string) {
+	if s.orgs[key(org)] == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, sortedTeams(s.teams[key(org)], nil))
+}
+
+func (s *Server) getTeam(w http.ResponseWriter, org, slug string) {
+	team := s.teams[key(org)][key(slug)]
+	if team == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, team)
+}
+
+// getMembership handles GET /orgs/{org}/teams/{slug}/memberships/{username}
+func (s *Server) getMembership(w http.ResponseWriter, org, slug, login string) {
+	if s.teams[key(org)][key(slug)] == nil || !s.members[key(org+"/"+slug)][key(login)] {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &github.Membership{
+		State: github.String("active"),
+		Role:  github.String("member"),
+	})
+}
+
+// teamRepo handles the requests to /orgs/{org}/teams/{slug}/repos/{owner}/{repo}
+func (s *Server) teamRepo(w http.ResponseWriter, req *http.Request, org, slug, owner, name string) bool {
+	team := s.teams[key(org)][key(slug)]
+	r := s.repos[key(owner+"/"+name)]
+	if team == nil || r == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		permission, ok := r.teams[key(slug)]
+		if !ok {
+			return false
+		}
+		repo := copyRepo(r.data)
+		repo.Permissions = permissions(permission)
+		writeJSON(w, http.StatusOK, repo)
+	case http.MethodPut:
+		body := struct {
+			Permission string `json:"permission"`
+		}{}
+		if req.ContentLength != 0 && !decode(w, req, &body) {
+			return true
+		}
+		if body.Permission == "" {
+			body.Permission = "push"
+		}
+		r.teams[key(slug)] = body.Permission
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(r.teams, key(slug))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// listRepoTeams handles GET /repos/{owner}/{repo}/teams
+func (s *Server) listRepoTeams(w http.ResponseWriter, r *repository) {
+	writeJSON(w, http.StatusOK, sortedTeams(s.teams[key(r.data.GetOwner().GetLogin())], r.teams))
+}
+
+// labels handles the requests to /repos/{owner}/{repo}/labels
+func (s *Server) labels(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sortedLabels(r))
+	case http.MethodPost:
+		label := &github.Label{}
+		if !decode(w, req, label) {
+			return true
+		}
+		if label.GetName() == "" {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name is missing")
+			return true
+		}
+		if r.labels[key(label.GetName())] != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: label already exists")
+			return true
+		}
+		label.ID = github.Int64(s.id())
+		r.labels[key(label.GetName())] = label
+		writeJSON(w, http.StatusCreated, label)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// label handles the requests to /repos/{owner}/{repo}/labels/{name}
+func (s *Server) label(w http.ResponseWriter, req *http.Request, r *repository, name string) bool {
+	label := r.labels[key(name)]
+	if label == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, label)
+	case http.MethodPatch:
+		body := struct {
+			github.Label
+			NewName *string `json:"new_name,omitempty"`
+		}{}
+		if !decode(w, req, &body) {
+			return true
+		}
+		if body.NewName != nil {
+			body.Name = body.NewName
+		}
+		if body.Name != nil && key(body.GetName()) != key(name) {
+			if r.labels[key(body.GetName())] != nil {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: label already exists")
+				return true
+			}
+			delete(r.labels, key(name))
+			label.Name = body.Name
+			r.labels[key(body.GetName())] = label
+		}
+		if body.Color != nil {
+			label.Color = body.Color
+		}
+		if body.Description != nil {
+			label.Description = body.Description
+		}
+		writeJSON(w, http.StatusOK, label)
+	case http.MethodDelete:
+		delete(r.labels, key(name))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// sortedTeams returns the teams sorted by slug, only the ones with a permission
+// on the repository and with the permission set when repo is given
+func sortedTeams(teams map[string]*github.Team, repo map[string]string) []*github.Team {
+	list := []*github.Team{}
+	for slug, team := range teams {
+		if repo == nil {
+			list = append(list, team)
+			continue
+		}
+		if permission, ok := repo[slug]; ok {
+			t := *team
+			t.Permission = github.String(permission)
+			list = append(list, &t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].GetSlug() < list[j].GetSlug() })
+
+	return list
+}
+
+// sortedLabels returns the labels of the repository sorted by name
+func sortedLabels(r *repository) []*github.Label {
+	list := []*github.Label{}
+	for _, label := range r.labels {
+		l := *label
+		list = append(list, &l)
+	}
+	sort.Slice(list, func(i, j int) bool { return key(list[i].GetName()) < key(list[j].GetName()) })
+
+	return list
+}
+
+// permissions returns the permissions granted by a repository role
+func permissions(role string) map[string]bool {
+	roles := []string{"pull", "triage", "push", "maintain", "admin"}
+	perms := map[string]bool{}
+	granted := true
+	for _, r := range roles {
+		perms[r] = granted
+		if r == role {
+			granted = false
+		}
+	}
+
+	return perms
+}
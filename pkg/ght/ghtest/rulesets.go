@@ -0,0 +1,287 @@
+package ghtest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// ruleset is a repository ruleset, kept as its JSON representation
+type ruleset map[string]interface{}
+
+// mergeQueueParameters are the parameters of the merge_queue rule
+var mergeQueueParameters = []string{
+	"check_response_timeout_minutes",
+	"grouping_strategy",
+	"max_entries_to_build",
+	"max_entries_to_merge",
+	"merge_method",
+	"min_entries_to_merge",
+	"min_entries_to_merge_wait_minutes",
+}
+
+// DisableRulesets makes the rulesets unavailable on a repository, as on the
+// private repositories of the free plans
+func (s *Server) DisableRulesets(owner, repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		r.rulesetsDisabled = true
+	}
+}
+
+// Ruleset returns the JSON representation of the ruleset of a repository with
+// the given name, nil when there is none
+func (s *Server) Ruleset(owner, repo, name string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		for _, rs := range r.rulesets {
+			if rs["name"] == name {
+				return rs
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddTagProtection adds a legacy tag protection pattern to a repository
+func (s *Server) AddTagProtection(owner, repo, pattern string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		id := s.id()
+		r.tagProtections[id] = &github.TagProtection{ID: github.Int64(id), Pattern: github.String(pattern)}
+	}
+}
+
+// TagProtection returns the legacy tag protection patterns of a repository, sorted
+func (s *Server) TagProtection(owner, repo string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var patterns []string
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		for _, p := range r.tagProtections {
+			patterns = append(patterns, p.GetPattern())
+		}
+	}
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+// ImmutableReleases reports whether the releases of a repository are immutable
+func (s *Server) ImmutableReleases(owner, repo string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	return r != nil && r.immutableReleases
+}
+
+// rulesets handles the requests to /repos/{owner}/{repo}/rulesets
+func (s *Server) rulesets(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	if r.rulesetsDisabled {
+		writeError(w, http.StatusForbidden, "Upgrade to GitHub Pro or make this repository public to enable this feature.")
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		ids := []int64{}
+		for id := range r.rulesets {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		// the list only shows the summary of the rulesets
+		list := []ruleset{}
+		for _, id := range ids {
+			rs := r.rulesets[id]
+			list = append(list, ruleset{
+				"id":          rs["id"],
+				"name":        rs["name"],
+				"target":      rs["target"],
+				"enforcement": rs["enforcement"],
+				"source_type": "Repository",
+				"source":      r.data.GetFullName(),
+			})
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		body := ruleset{}
+		if !decode(w, req, &body) {
+			return true
+		}
+		for _, rs := range r.rulesets {
+			if rs["name"] == body["name"] {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: Name must be unique")
+				return true
+			}
+		}
+		if !validRuleset(w, body) {
+			return true
+		}
+
+		id := s.id()
+		body["id"] = float64(id)
+		if body["target"] == nil {
+			body["target"] = "branch"
+		}
+		body["source_type"] = "Repository"
+		body["source"] = r.data.GetFullName()
+		r.rulesets[id] = body
+		writeJSON(w, http.StatusCreated, body)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// ruleset handles the requests to /repos/{owner}/{repo}/rulesets/{id}
+func (s *Server) ruleset(w http.ResponseWriter, req *http.Request, r *repository, id string) bool {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	rs := r.rulesets[n]
+	if rs == nil || r.rulesetsDisabled {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, rs)
+	case http.MethodPut:
+		body := ruleset{}
+		if !decode(w, req, &body) {
+			return true
+		}
+		if !validRuleset(w, body) {
+			return true
+		}
+		for k, v := range body {
+			if k != "id" {
+				rs[k] = v
+			}
+		}
+		writeJSON(w, http.StatusOK, rs)
+	case http.MethodDelete:
+		delete(r.rulesets, n)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// validRuleset checks the enforcement and the rule types of a ruleset, writing the error otherwise
+func validRuleset(w http.ResponseWriter, body ruleset) bool {
+	switch body["enforcement"] {
+	case "active", "evaluate", "disabled", nil:
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: invalid enforcement")
+		return false
+	}
+
+	rules, _ := body["rules"].([]interface{})
+	for _, rule := range rules {
+		m, ok := rule.(map[string]interface{})
+		if !ok || m["type"] == nil {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: rule type is required")
+			return false
+		}
+		if m["type"] != "merge_queue" {
+			continue
+		}
+
+		// every parameter of the merge queue is required
+		params, _ := m["parameters"].(map[string]interface{})
+		for _, p := range mergeQueueParameters {
+			if params[p] == nil {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: merge_queue parameter "+p+" is required")
+				return false
+			}
+		}
+		if body["target"] == "tag" {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: merge_queue only applies to branches")
+			return false
+		}
+	}
+
+	return true
+}
+
+// tagProtections handles the requests to /repos/{owner}/{repo}/tags/protection
+func (s *Server) tagProtections(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		list := []*github.TagProtection{}
+		for _, p := range r.tagProtections {
+			list = append(list, p)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].GetID() < list[j].GetID() })
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		body := &github.TagProtection{}
+		if !decode(w, req, body) {
+			return true
+		}
+		for _, p := range r.tagProtections {
+			if p.GetPattern() == body.GetPattern() {
+				writeError(w, http.StatusUnprocessableEntity, "Validation Failed: pattern already exists")
+				return true
+			}
+		}
+
+		id := s.id()
+		p := &github.TagProtection{ID: github.Int64(id), Pattern: body.Pattern}
+		r.tagProtections[id] = p
+		writeJSON(w, http.StatusCreated, p)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// tagProtection handles the requests to /repos/{owner}/{repo}/tags/protection/{id}
+func (s *Server) tagProtection(w http.ResponseWriter, req *http.Request, r *repository, id string) bool {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	if r.tagProtections[n] == nil || req.Method != http.MethodDelete {
+		return false
+	}
+
+	delete(r.tagProtections, n)
+	w.WriteHeader(http.StatusNoContent)
+
+	return true
+}
+
+// immutableReleases handles the requests to /repos/{owner}/{repo}/immutable-releases
+func (s *Server) immutableReleases(w http.ResponseWriter, req *http.Request, r *repository) bool {
+	switch req.Method {
+	case http.MethodGet:
+		if !r.immutableReleases {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return true
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"enabled": true, "enforced_by_owner": false})
+	case http.MethodPut:
+		r.immutableReleases = true
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		r.immutableReleases = false
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,117 @@
+package ghtest
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// security holds the security features of a repository that are not part of its data
+type security struct {
+	vulnerabilityAlerts           bool
+	automatedSecurityFixes        bool
+	privateVulnerabilityReporting bool
+}
+
+// Security returns the state of the security features of a repository, by
+// the name of their setting, nil when the repository does not exist
+func (s *Server) Security(owner, repo string) map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.repos[key(owner+"/"+repo)]
+	if r == nil {
+		return nil
+	}
+
+	analysis := r.data.GetSecurityAndAnalysis()
+	return map[string]bool{
+		"vulnerability_alerts":            r.security.vulnerabilityAlerts,
+		"automated_security_fixes":        r.security.automatedSecurityFixes,
+		"secret_scanning":                 analysis.GetSecretScanning().GetStatus() == "enabled",
+		"secret_scanning_push_protection": analysis.GetSecretScanningPushProtection().GetStatus() == "enabled",
+		"private_vulnerability_reporting": r.security.privateVulnerabilityReporting,
+	}
+}
+
+// routeSecurity handles the requests to the security features of a repository
+func (s *Server) routeSecurity(w http.ResponseWriter, req *http.Request, r *repository, feature string) bool {
+	sec := &r.security
+	switch {
+	case feature == "vulnerability-alerts" && req.Method == http.MethodGet:
+		// enabled is told by the status only
+		if !sec.vulnerabilityAlerts {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return true
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "vulnerability-alerts" && req.Method == http.MethodPut:
+		sec.vulnerabilityAlerts = true
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "vulnerability-alerts" && req.Method == http.MethodDelete:
+		sec.vulnerabilityAlerts = false
+		sec.automatedSecurityFixes = false
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "automated-security-fixes" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]bool{"enabled": sec.automatedSecurityFixes, "paused": false})
+	case feature == "automated-security-fixes" && req.Method == http.MethodPut:
+		if !sec.vulnerabilityAlerts {
+			writeError(w, http.StatusUnprocessableEntity, "Vulnerability alerts must be enabled")
+			return true
+		}
+		sec.automatedSecurityFixes = true
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "automated-security-fixes" && req.Method == http.MethodDelete:
+		sec.automatedSecurityFixes = false
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "private-vulnerability-reporting" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]bool{"enabled": sec.privateVulnerabilityReporting})
+	case feature == "private-vulnerability-reporting" && req.Method == http.MethodPut:
+		sec.privateVulnerabilityReporting = true
+		w.WriteHeader(http.StatusNoContent)
+	case feature == "private-vulnerability-reporting" && req.Method == http.MethodDelete:
+		sec.privateVulnerabilityReporting = false
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// editSecurityAndAnalysis applies the security_and_analysis of a repository
+// edit, rejecting the push protection without secret scanning like GitHub does
+func editSecurityAndAnalysis(r *repository, edit *github.SecurityAndAnalysis) bool {
+	current := r.data.GetSecurityAndAnalysis()
+	scanning := current.GetSecretScanning().GetStatus()
+	pushProtection := current.GetSecretScanningPushProtection().GetStatus()
+	if edit.SecretScanning != nil {
+		scanning = edit.GetSecretScanning().GetStatus()
+	}
+	if edit.SecretScanningPushProtection != nil {
+		pushProtection = edit.GetSecretScanningPushProtection().GetStatus()
+	}
+
+	if pushProtection == "enabled" && scanning != "enabled" {
+		if edit.SecretScanningPushProtection != nil {
+			return false
+		}
+		// disabling the secret scanning disables the push protection
+		pushProtection = "disabled"
+	}
+
+	analysis := &github.SecurityAndAnalysis{}
+	if current != nil {
+		c := *current
+		analysis = &c
+	}
+	if scanning != "" {
+		analysis.SecretScanning = &github.SecretScanning{Status: github.String(scanning)}
+	}
+	if pushProtection != "" {
+		analysis.SecretScanningPushProtection = &github.SecretScanningPushProtection{Status: github.String(pushProtection)}
+	}
+	r.data.SecurityAndAnalysis = analysis
+
+	return true
+}
@@ -0,0 +1,638 @@
+// Package ghtest provides a stateful in-memory fake of the GitHub REST API, so
+// that ght and the programs using it can be tested end-to-end without network.
+//
+// The fake covers the endpoints used to manage repositories, branches, branch
+// protection, contents, topics, labels, teams, webhooks, deploy keys,
+// autolinks, GitHub Pages, rulesets, tag protection, immutable releases,
+// commits, check runs and statuses, Actions secrets, variables and
+// permissions, deployment environments, security features and code scanning.
+// Writes are kept in memory and are visible to the requests that follow,
+// which allows scenarios such as "create, run again, assert nothing changed":
+//
+//	srv := ghtest.NewServer()
+//	defer srv.Close()
+//	srv.AddOrg("acme")
+//
+//	rt, _ := ght.NewRepoTemplate(ght.WithClient(srv.GitHubClient()))
+package ghtest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// DefaultLogin is the login of the authenticated user
+const DefaultLogin = "octocat"
+
+// Server is a fake GitHub REST API server
+type Server struct {
+	*httptest.Server
+
+	// Login is the authenticated user, the owner of repositories created by
+	// POST /user/repos
+	Login string
+
+	mu       sync.Mutex
+	orgs     map[string]*github.Organization
+	teams    map[string]map[string]*github.Team
+	members  map[string]map[string]bool
+	users    map[string]*github.User
+	repos    map[string]*repository
+	ids      int64
+	requests []string
+	// bodies are the bodies of the requests, at the same index
+	bodies   []string
+	failures map[string][]int
+}
+
+// repository is the state of a repository
+type repository struct {
+	data              *github.Repository
+	branches          map[string]*branch
+	contents          map[string][]byte
+	labels            map[string]*github.Label
+	teams             map[string]string
+	hooks             map[int64]*hook
+	secrets           *secrets
+	variables         map[string]*github.ActionsVariable
+	environments      map[string]*environment
+	security          security
+	languages         map[string]int
+	codeScanning      *codeScanning
+	permissions       *actionsPermissions
+	keys              map[int64]*github.Key
+	autolinks         map[int64]*github.Autolink
+	pages             *pages
+	rulesets          map[int64]ruleset
+	rulesetsDisabled  bool
+	tagProtections    map[int64]*github.TagProtection
+	immutableReleases bool
+	checkRuns         map[string][]*github.CheckRun
+	statuses          map[string][]*github.RepoStatus
+}
+
+// branch is the state of a branch
+type branch struct {
+	sha        string
+	protection *github.Protection
+	signatures bool
+	// history are the previous commits of the branch, the most recent first
+	history []string
+}
+
+// NewServer starts and returns a new fake server, the caller should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		Login:    DefaultLogin,
+		orgs:     map[string]*github.Organization{},
+		teams:    map[string]map[string]*github.Team{},
+		members:  map[string]map[string]bool{},
+		users:    map[string]*github.User{},
+		repos:    map[string]*repository{},
+		failures: map[string][]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// GitHubClient returns a go-github client that talks to the fake server
+func (s *Server) GitHubClient() *github.Client {
+	c := github.NewClient(s.Client())
+	u, _ := url.Parse(s.URL + "/")
+	c.BaseURL = u
+	c.UploadURL = u
+
+	return c
+}
+
+// AddOrg registers an organization
+func (s *Server) AddOrg(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgs[key(login)] = &github.Organization{
+		ID:    github.Int64(s.id()),
+		Login: github.String(login),
+		Type:  github.String("Organization"),
+	}
+	if s.teams[key(login)] == nil {
+		s.teams[key(login)] = map[string]*github.Team{}
+	}
+}
+
+// AddTeam registers a team in an organization, the organization is registered when missing
+func (s *Server) AddTeam(org, slug string) {
+	if s.org(org) == nil {
+		s.AddOrg(org)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.teams[key(org)][key(slug)] = &github.Team{
+		ID:   github.Int64(s.id()),
+		Name: github.String(slug),
+		Slug: github.String(slug),
+	}
+}
+
+// AddTeamMember adds an active member to a team, the team is registered when missing
+func (s *Server) AddTeamMember(org, slug, login string) {
+	s.mu.Lock()
+	missing := s.teams[key(org)][key(slug)] == nil
+	s.mu.Unlock()
+	if missing {
+		s.AddTeam(org, slug)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team := key(org + "/" + slug)
+	if s.members[team] == nil {
+		s.members[team] = map[string]bool{}
+	}
+	s.members[team][key(login)] = true
+}
+
+// AddRepo registers a repository with its default branch, as if it had been
+// created with auto_init
+func (s *Server) AddRepo(owner string, repo *github.Repository) *github.Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.newRepository(owner, repo)
+	s.initBranch(r)
+
+	return copyRepo(r.data)
+}
+
+// AddFile creates or replaces a file in a repository
+func (s *Server) AddFile(owner, repo, path string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		s.writeFile(r, path, content)
+	}
+}
+
+// Repository returns a copy of the repository, or nil when it does not exist
+func (s *Server) Repository(owner, repo string) *github.Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		return copyRepo(r.data)
+	}
+
+	return nil
+}
+
+// Branches returns the branch names of a repository, sorted
+func (s *Server) Branches(owner, repo string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		for name := range r.branches {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Protection returns the protection of a branch, or nil when it is not protected
+func (s *Server) Protection(owner, repo, branch string) *github.Protection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		if b := r.branches[branch]; b != nil {
+			return b.protection
+		}
+	}
+
+	return nil
+}
+
+// Content returns the content of a file and whether it exists
+func (s *Server) Content(owner, repo, path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		data, ok := r.contents[path]
+		return data, ok
+	}
+
+	return nil, false
+}
+
+// Labels returns the labels of a repository, sorted by name
+func (s *Server) Labels(owner, repo string) []*github.Label {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var labels []*github.Label
+	if r := s.repos[key(owner+"/"+repo)]; r != nil {
+		labels = sortedLabels(r)
+	}
+
+	return labels
+}
+
+// Requests returns all the requests served, as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// Bodies returns the bodies of the requests "METHOD /path" served, in order,
+// without the trailing newline added by the JSON encoder of go-github
+func (s *Server) Bodies(request string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bodies []string
+	for i, r := range s.requests {
+		if r == request {
+			bodies = append(bodies, s.bodies[i])
+		}
+	}
+
+	return bodies
+}
+
+// Writes returns the requests that may have changed the state, every method but GET and HEAD
+func (s *Server) Writes() []string {
+	var writes []string
+	for _, req := range s.Requests() {
+		if !strings.HasPrefix(req, http.MethodGet+" ") && !strings.HasPrefix(req, http.MethodHead+" ") {
+			writes = append(writes, req)
+		}
+	}
+
+	return writes
+}
+
+// Fail makes the next request "METHOD /path" fail with the status, without
+// changing the state. Each call fails one more request.
+func (s *Server) Fail(request string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[request] = append(s.failures[request], status)
+}
+
+// Reset forgets the requests served, the state is kept
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+	s.bodies = nil
+}
+
+func (s *Server) org(login string) *github.Organization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.orgs[key(login)]
+}
+
+// id returns a new unique id, the lock must be held
+func (s *Server) id() int64 {
+	s.ids++
+	return s.ids
+}
+
+// newRepository stores a new repository, the lock must be held
+func (s *Server) newRepository(owner string, repo *github.Repository) *repository {
+	data := copyRepo(repo)
+	ownerType := "User"
+	if s.orgs[key(owner)] != nil {
+		ownerType = "Organization"
+	}
+
+	data.ID = github.Int64(s.id())
+	data.Owner = &github.User{Login: github.String(owner), Type: github.String(ownerType)}
+	data.FullName = github.String(owner + "/" + data.GetName())
+	data.HTMLURL = github.String("https://github.com/" + data.GetFullName())
+	data.URL = github.String(s.URL + "/repos/" + data.GetFullName())
+	if data.DefaultBranch == nil {
+		data.DefaultBranch = github.String("main")
+	}
+	if data.Visibility == nil {
+		data.Visibility = github.String("public")
+		if data.GetPrivate() {
+			data.Visibility = github.String("private")
+		}
+	}
+	if data.Topics == nil {
+		data.Topics = []string{}
+	}
+	data.AutoInit = nil
+
+	r := &repository{
+		data:           data,
+		branches:       map[string]*branch{},
+		contents:       map[string][]byte{},
+		labels:         map[string]*github.Label{},
+		teams:          map[string]string{},
+		hooks:          map[int64]*hook{},
+		secrets:        newSecrets(s.id()),
+		variables:      map[string]*github.ActionsVariable{},
+		environments:   map[string]*environment{},
+		permissions:    newActionsPermissions(),
+		keys:           map[int64]*github.Key{},
+		autolinks:      map[int64]*github.Autolink{},
+		rulesets:       map[int64]ruleset{},
+		tagProtections: map[int64]*github.TagProtection{},
+		checkRuns:      map[string][]*github.CheckRun{},
+		statuses:       map[string][]*github.RepoStatus{},
+	}
+	s.repos[key(data.GetFullName())] = r
+
+	return r
+}
+
+// initBranch creates the default branch with a README, the lock must be held
+func (s *Server) initBranch(r *repository) {
+	s.writeFile(r, "README.md", []byte("# "+r.data.GetName()+"\n"))
+}
+
+// addCommit adds a commit to the default branch and returns its sha, the lock must be held
+func (s *Server) addCommit(r *repository) string {
+	b := r.branches[r.data.GetDefaultBranch()]
+	if b == nil {
+		b = &branch{}
+		r.branches[r.data.GetDefaultBranch()] = b
+	}
+	if b.sha != "" {
+		b.history = append([]string{b.sha}, b.history...)
+	}
+	b.sha = commitSHA(s.id())
+
+	return b.sha
+}
+
+// writeFile stores a file and commits it to the default branch, the lock must be held
+func (s *Server) writeFile(r *repository, path string, content []byte) string {
+	r.contents[path] = content
+
+	return s.addCommit(r)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request := req.Method + " " + req.URL.Path
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Problems reading body")
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	s.requests = append(s.requests, request)
+	s.bodies = append(s.bodies, strings.TrimSuffix(string(body), "\n"))
+
+	if statuses := s.failures[request]; len(statuses) > 0 {
+		s.failures[request] = statuses[1:]
+		writeError(w, statuses[0], http.StatusText(statuses[0]))
+		return
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(strings.Trim(req.URL.EscapedPath(), "/"), "/") {
+		seg, err := url.PathUnescape(seg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Problems parsing path")
+			return
+		}
+		segments = append(segments, seg)
+	}
+
+	if !s.route(w, req, segments) {
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// route dispatches the request and reports whether a handler served it
+func (s *Server) route(w http.ResponseWriter, req *http.Request, p []string) bool {
+	method := req.Method
+	switch {
+	case match(p, "user") && method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &github.User{Login: github.String(s.Login), Type: github.String("User")})
+	case match(p, "user", "repos") && method == http.MethodPost:
+		s.createRepo(w, req, s.Login)
+	case match(p, "users", "*") && method == http.MethodGet:
+		return s.getUser(w, p[1])
+	case len(p) >= 2 && p[0] == "repositories":
+		return s.routeRepositoryID(w, req, p)
+	case match(p, "orgs", "*") && method == http.MethodGet:
+		s.getOrg(w, p[1])
+	case match(p, "orgs", "*", "repos") && method == http.MethodPost:
+		if s.orgs[key(p[1])] == nil {
+			return false
+		}
+		s.createRepo(w, req, s.orgs[key(p[1])].GetLogin())
+	case match(p, "orgs", "*", "teams") && method == http.MethodGet:
+		s.listTeams(w, p[1])
+	case match(p, "orgs", "*", "teams", "*") && method == http.MethodGet:
+		s.getTeam(w, p[1], p[3])
+	case match(p, "orgs", "*", "teams", "*", "memberships", "*") && method == http.MethodGet:
+		s.getMembership(w, p[1], p[3], p[5])
+	case match(p, "orgs", "*", "teams", "*", "repos", "*", "*"):
+		return s.teamRepo(w, req, p[1], p[3], p[5], p[6])
+	case len(p) >= 3 && p[0] == "repos":
+		r := s.repos[key(p[1]+"/"+p[2])]
+		if r == nil {
+			return false
+		}
+		return s.routeRepo(w, req, r, p[3:])
+	default:
+		return false
+	}
+
+	return true
+}
+
+// routeRepo dispatches the requests under /repos/{owner}/{repo}
+func (s *Server) routeRepo(w http.ResponseWriter, req *http.Request, r *repository, p []string) bool {
+	method := req.Method
+	switch {
+	case len(p) == 0 && method == http.MethodGet:
+		writeJSON(w, http.StatusOK, r.data)
+	case len(p) == 0 && method == http.MethodPatch:
+		s.editRepo(w, req, r)
+	case len(p) == 0 && method == http.MethodDelete:
+		delete(s.repos, key(r.data.GetFullName()))
+		w.WriteHeader(http.StatusNoContent)
+	case match(p, "generate") && method == http.MethodPost:
+		s.generateRepo(w, req, r)
+	case match(p, "topics") && method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string][]string{"names": r.data.Topics})
+	case match(p, "topics") && method == http.MethodPut:
+		s.replaceTopics(w, req, r)
+	case match(p, "teams") && method == http.MethodGet:
+		s.listRepoTeams(w, r)
+	case match(p, "labels"):
+		return s.labels(w, req, r)
+	case match(p, "labels", "*"):
+		return s.label(w, req, r, p[1])
+	case match(p, "branches") && method == http.MethodGet:
+		s.listBranches(w, r)
+	case len(p) >= 2 && p[0] == "branches":
+		b := r.branches[p[1]]
+		if b == nil {
+			writeError(w, http.StatusNotFound, "Branch not found")
+			return true
+		}
+		return s.routeBranch(w, req, r, p[1], b, p[2:])
+	case match(p, "commits") && method == http.MethodGet:
+		s.listCommits(w, req, r)
+	case match(p, "commits", "*", "check-runs") && method == http.MethodGet:
+		s.listCheckRuns(w, r, p[1])
+	case match(p, "commits", "*", "statuses") && method == http.MethodGet:
+		writeJSON(w, http.StatusOK, append([]*github.RepoStatus{}, r.statuses[p[1]]...))
+	case len(p) >= 2 && p[0] == "commits" && method == http.MethodGet:
+		s.commit(w, req, r, strings.Join(p[1:], "/"))
+	case match(p, "git", "refs") && method == http.MethodPost:
+		s.createRef(w, req, r)
+	case match(p, "pages"):
+		return s.pagesSite(w, req, r)
+	case match(p, "rulesets"):
+		return s.rulesets(w, req, r)
+	case match(p, "rulesets", "*"):
+		return s.ruleset(w, req, r, p[1])
+	case match(p, "tags", "protection"):
+		return s.tagProtections(w, req, r)
+	case match(p, "tags", "protection", "*"):
+		return s.tagProtection(w, req, r, p[2])
+	case match(p, "immutable-releases"):
+		return s.immutableReleases(w, req, r)
+	case match(p, "autolinks"):
+		return s.autolinks(w, req, r)
+	case match(p, "autolinks", "*"):
+		return s.autolink(w, req, r, p[1])
+	case match(p, "keys"):
+		return s.deployKeys(w, req, r)
+	case match(p, "keys", "*"):
+		return s.deployKey(w, req, r, p[1])
+	case match(p, "hooks"):
+		return s.hooks(w, req, r)
+	case match(p, "hooks", "*"):
+		return s.hook(w, req, r, p[1])
+	case match(p, "vulnerability-alerts"), match(p, "automated-security-fixes"), match(p, "private-vulnerability-reporting"):
+		return s.routeSecurity(w, req, r, p[0])
+	case match(p, "languages") && method == http.MethodGet:
+		languages := r.languages
+		if languages == nil {
+			languages = map[string]int{}
+		}
+		writeJSON(w, http.StatusOK, languages)
+	case match(p, "code-scanning", "default-setup"):
+		return s.defaultSetup(w, req, r)
+	case len(p) >= 1 && p[0] == "environments":
+		return s.routeEnvironments(w, req, r, p[1:])
+	case len(p) >= 1 && p[0] == "actions":
+		return s.routeActions(w, req, r, p[1:])
+	case len(p) >= 1 && p[0] == "contents":
+		return s.contents(w, req, r, strings.Join(p[1:], "/"))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// match reports whether the path segments match the pattern, * matches any segment
+func match(segments []string, pattern ...string) bool {
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key returns the map key of a name, GitHub logins and repository names are case insensitive
+func key(name string) string {
+	return strings.ToLower(name)
+}
+
+// commitSHA returns a fake commit sha
+func commitSHA(n int64) string {
+	h := sha1.Sum([]byte(fmt.Sprintf("commit %d", n)))
+	return hex.EncodeToString(h[:])
+}
+
+// blobSHA returns the git blob sha of the content
+func blobSHA(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// copyRepo returns a copy of the repository, so that callers can't change the state
+func copyRepo(repo *github.Repository) *github.Repository {
+	data := &github.Repository{}
+	if repo == nil {
+		return data
+	}
+	b, _ := json.Marshal(repo)
+	_ = json.Unmarshal(b, data)
+
+	return data
+}
+
+// decode reads the JSON body of the request
+func decode(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error the way GitHub does, go-github reads it as a *github.ErrorResponse
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
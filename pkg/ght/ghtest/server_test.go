@@ -0,0 +1,194 @@
+package ghtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+var ctx = context.Background()
+
+func statusCode(err error) int {
+	if res, ok := err.(*github.ErrorResponse); ok {
+		return res.Response.StatusCode
+	}
+
+	return 0
+}
+
+func TestRepository(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	client := srv.GitHubClient()
+
+	_, _, err := client.Repositories.Get(ctx, "acme", "ght")
+	assert.Equal(t, http.StatusNotFound, statusCode(err))
+
+	repo, _, err := client.Repositories.Create(ctx, "acme", &github.Repository{Name: github.String("ght"), AutoInit: github.Bool(true)})
+	assert.Nil(t, err)
+	assert.Equal(t, "acme/ght", repo.GetFullName())
+
+	_, _, err = client.Repositories.Create(ctx, "acme", &github.Repository{Name: github.String("ght")})
+	assert.Equal(t, http.StatusUnprocessableEntity, statusCode(err))
+
+	_, _, err = client.Repositories.Edit(ctx, "acme", "ght", &github.Repository{HasWiki: github.Bool(false)})
+	assert.Nil(t, err)
+
+	repo, _, err = client.Repositories.Get(ctx, "acme", "ght")
+	assert.Nil(t, err)
+	assert.False(t, repo.GetHasWiki())
+	assert.Equal(t, "main", repo.GetDefaultBranch())
+	assert.Equal(t, []string{"main"}, srv.Branches("acme", "ght"))
+}
+
+func TestUserRepository(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	repo, _, err := srv.GitHubClient().Repositories.Create(ctx, "", &github.Repository{Name: github.String("ght")})
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultLogin, repo.GetOwner().GetLogin())
+	assert.Empty(t, srv.Branches(DefaultLogin, "ght"))
+}
+
+func TestGenerateRepository(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("tmpl"), IsTemplate: github.Bool(true)})
+	srv.AddFile("acme", "tmpl", "go.mod", []byte("module x\n"))
+	srv.AddRepo("acme", &github.Repository{Name: github.String("other")})
+	client := srv.GitHubClient()
+
+	_, _, err := client.Repositories.CreateFromTemplate(ctx, "acme", "tmpl", &github.TemplateRepoRequest{Name: github.String("ght"), Owner: github.String("acme")})
+	assert.Nil(t, err)
+
+	data, ok := srv.Content("acme", "ght", "go.mod")
+	assert.True(t, ok)
+	assert.Equal(t, "module x\n", string(data))
+
+	_, _, err = client.Repositories.CreateFromTemplate(ctx, "acme", "other", &github.TemplateRepoRequest{Name: github.String("ght2"), Owner: github.String("acme")})
+	assert.Equal(t, http.StatusUnprocessableEntity, statusCode(err))
+}
+
+func TestBranchProtection(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	client := srv.GitHubClient()
+
+	_, _, err := client.Repositories.GetBranchProtection(ctx, "acme", "ght", "main")
+	assert.Equal(t, github.ErrBranchNotProtected, err)
+
+	_, _, err = client.Repositories.UpdateBranchProtection(ctx, "acme", "ght", "main", &github.ProtectionRequest{
+		EnforceAdmins: true,
+		Restrictions:  &github.BranchRestrictionsRequest{Users: []string{"leocomelli"}, Teams: []string{}},
+	})
+	assert.Nil(t, err)
+
+	p, _, err := client.Repositories.GetBranchProtection(ctx, "acme", "ght", "main")
+	assert.Nil(t, err)
+	assert.True(t, p.EnforceAdmins.Enabled)
+	assert.Equal(t, "leocomelli", p.Restrictions.Users[0].GetLogin())
+
+	_, _, err = client.Repositories.RequireSignaturesOnProtectedBranch(ctx, "acme", "ght", "main")
+	assert.Nil(t, err)
+	sig, _, err := client.Repositories.GetSignaturesProtectedBranch(ctx, "acme", "ght", "main")
+	assert.Nil(t, err)
+	assert.True(t, sig.GetEnabled())
+
+	_, _, err = client.Repositories.GetBranchProtection(ctx, "acme", "ght", "develop")
+	assert.Equal(t, http.StatusNotFound, statusCode(err))
+}
+
+func TestContents(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	client := srv.GitHubClient()
+
+	path := ".github/pull_request_template.md"
+	_, _, err := client.Repositories.CreateFile(ctx, "acme", "ght", path, &github.RepositoryContentFileOptions{
+		Message: github.String("Add template"),
+		Content: []byte("v1"),
+	})
+	assert.Nil(t, err)
+
+	file, _, _, err := client.Repositories.GetContents(ctx, "acme", "ght", path, nil)
+	assert.Nil(t, err)
+	content, _ := file.GetContent()
+	assert.Equal(t, "v1", content)
+
+	_, _, err = client.Repositories.UpdateFile(ctx, "acme", "ght", path, &github.RepositoryContentFileOptions{
+		Message: github.String("Update template"),
+		Content: []byte("v2"),
+		SHA:     github.String("outdated"),
+	})
+	assert.Equal(t, http.StatusConflict, statusCode(err))
+
+	_, _, err = client.Repositories.UpdateFile(ctx, "acme", "ght", path, &github.RepositoryContentFileOptions{
+		Message: github.String("Update template"),
+		Content: []byte("v2"),
+		SHA:     file.SHA,
+	})
+	assert.Nil(t, err)
+
+	_, dir, _, err := client.Repositories.GetContents(ctx, "acme", "ght", ".github", nil)
+	assert.Nil(t, err)
+	assert.Len(t, dir, 1)
+	assert.Equal(t, path, dir[0].GetPath())
+}
+
+func TestTopicsLabelsAndTeams(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddTeam("acme", "devs")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	client := srv.GitHubClient()
+
+	_, _, err := client.Repositories.ReplaceAllTopics(ctx, "acme", "ght", []string{"go"})
+	assert.Nil(t, err)
+	topics, _, err := client.Repositories.ListAllTopics(ctx, "acme", "ght")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go"}, topics)
+
+	_, _, err = client.Issues.CreateLabel(ctx, "acme", "ght", &github.Label{Name: github.String("bug"), Color: github.String("d73a4a")})
+	assert.Nil(t, err)
+	_, _, err = client.Issues.EditLabel(ctx, "acme", "ght", "bug", &github.Label{Color: github.String("000000")})
+	assert.Nil(t, err)
+	labels := srv.Labels("acme", "ght")
+	assert.Len(t, labels, 1)
+	assert.Equal(t, "000000", labels[0].GetColor())
+
+	_, err = client.Teams.AddTeamRepoBySlug(ctx, "acme", "devs", "acme", "ght", &github.TeamAddTeamRepoOptions{Permission: "maintain"})
+	assert.Nil(t, err)
+	teams, _, err := client.Repositories.ListTeams(ctx, "acme", "ght", nil)
+	assert.Nil(t, err)
+	assert.Len(t, teams, 1)
+	assert.Equal(t, "maintain", teams[0].GetPermission())
+
+	assert.Len(t, srv.Writes(), 4)
+	assert.Equal(t, []string{`{"color":"000000"}`}, srv.Bodies("PATCH /repos/acme/ght/labels/bug"))
+
+	srv.Reset()
+	assert.Empty(t, srv.Bodies("PATCH /repos/acme/ght/labels/bug"))
+}
+
+func TestFail(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	client := srv.GitHubClient()
+
+	srv.Fail("GET /repos/acme/ght", http.StatusBadGateway)
+
+	_, _, err := client.Repositories.Get(ctx, "acme", "ght")
+	assert.Equal(t, http.StatusBadGateway, statusCode(err))
+
+	_, _, err = client.Repositories.Get(ctx, "acme", "ght")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,589 @@
+package ght
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+)
+
+// RepoTemplate applies the template settings through the GitHub API
+type RepoTemplate struct {
+	api     GitHubAPI
+	logger  zerolog.Logger
+	fetcher *Fetcher
+}
+
+// NewRepoTemplate creates a new RepoTemplate. Unless a client or an API is given,
+// a client authenticated by the GITHUB_TOKEN env var is used, reaching the
+// GITHUB_API_URL env var when set. The token is not required to replay a cassette.
+func NewRepoTemplate(opts ...Option) (*RepoTemplate, error) {
+	o := newOptions(opts)
+
+	if o.api == nil {
+		client := o.client
+		if client == nil {
+			c, err := newClient(o.cassette)
+			if err != nil {
+				return nil, err
+			}
+			client = c
+		}
+
+		if o.audit != nil {
+			client = o.audit.wrap(client, o.logger)
+		}
+		o.api = NewGitHubAPI(client)
+	}
+
+	return &RepoTemplate{
+		api:     o.api,
+		logger:  o.logger,
+		fetcher: o.fetcher,
+	}, nil
+}
+
+// newClient creates the go-github client authenticated by the GITHUB_TOKEN env var
+func newClient(cassette *Cassette) (*github.Client, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && !cassette.Replaying() {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if cassette != nil {
+		transport = cassette.Wrap(transport)
+	}
+	if token != "" {
+		transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+			Base:   transport,
+		}
+	}
+
+	client := github.NewClient(&http.Client{Transport: transport})
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(apiURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GITHUB_API_URL %s |→ %w", apiURL, err)
+		}
+		client.BaseURL = u
+	}
+
+	return client, nil
+}
+
+// Fetcher returns the fetcher used to read the template files
+func (r *RepoTemplate) Fetcher() *Fetcher {
+	if r == nil || r.fetcher == nil {
+		return DefaultFetcher
+	}
+
+	return r.fetcher
+}
+
+// GetOrg fetches an organization.
+//
+// GitHub API docs: https://docs.github.com/en/rest/reference/orgs#get-an-organization
+func (r *RepoTemplate) GetOrg(org string) (*github.Organization, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching org %s", org)
+
+	res, err := r.api.GetOrganization(ctx, org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch org %s |→ %w", org, err)
+	}
+
+	return res, nil
+}
+
+// IsTeamMember reports whether the user is an active member of the organization team.
+//
+// GitHub API docs: https://docs.github.com/en/rest/teams/members#get-team-membership-for-a-user
+func (r *RepoTemplate) IsTeamMember(org, team, user string) (bool, error) {
+	ctx := context.Background()
+
+	api, err := extension[TeamsAPI](r.api)
+	if err != nil {
+		return false, err
+	}
+
+	m, err := api.GetTeamMembership(ctx, org, team, user)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get membership of %s in team %s/%s |→ %w", user, org, team, err)
+	}
+
+	return m.GetState() == "active", nil
+}
+
+// GetRepo fetches a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/repos#get-a-repository
+func (r *RepoTemplate) GetRepo(owner, repo string) (*github.Repository, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching repo %s/%s", owner, repo)
+
+	res, err := r.api.GetRepository(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repo %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateRepo creates a repository from scratch or using a template.
+//
+// GitHub API docs: https://docs.github.com/en/rest/reference/repos#create-a-repository-for-the-authenticated-user
+// GitHub API docs: https://docs.github.com/en/rest/reference/repos#create-a-repository-using-a-template
+func (r *RepoTemplate) CreateRepo(opts *RepoOptions, cfg *Config) (*github.Repository, error) {
+	ctx := context.Background()
+
+	repo := cfg.Repository
+
+	r.logger.Debug().Msgf("creating repo %s/%s", opts.Owner, opts.Name)
+
+	// Create a repo using a template.
+	if cfg.TemplateRepo != nil {
+		r.logger.Debug().Msg("using template repo")
+
+		tmpl := &github.TemplateRepoRequest{
+			Name:               github.String(opts.Name),
+			Owner:              github.String(opts.Owner),
+			Description:        github.String(opts.Description),
+			IncludeAllBranches: cfg.TemplateRepo.IncludeAllBranches,
+			Private:            cfg.TemplateRepo.Private,
+		}
+
+		res, err := r.api.CreateRepositoryFromTemplate(ctx, opts.Owner, opts.Name, tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create repo using template %s/%s |→ %w", opts.Owner, opts.Name, err)
+		}
+
+		return res, nil
+	}
+
+	// Check if the owner is an organization.
+	owner := opts.Owner
+	_, err := r.GetOrg(opts.Owner)
+	if err != nil && isNotFound(err) {
+		owner = ""
+	}
+
+	// Create a repo from scratch.
+	repo.Name = github.String(opts.Name)
+	repo.Description = github.String(opts.Description)
+	repo.Topics = opts.Topics
+
+	res, err := r.api.CreateRepository(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repo %s/%s |→ %w", opts.Owner, opts.Name, err)
+	}
+
+	return res, nil
+}
+
+// GetBranch fetches a branch.
+//
+// GitHub API docs: https://docs.github.com/en/rest/reference/repos#get-a-branch
+func (r *RepoTemplate) GetBranch(org, name, branch string) (*github.Branch, error) {
+	ctx := context.Background()
+	b, err := r.api.GetBranch(ctx, org, name, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// GetBranchProtection fetches the protection rules of a branch as a request, nil if the branch is not protected.
+//
+// GitHub API docs: https://docs.github.com/en/rest/branches/branch-protection#get-branch-protection
+func (r *RepoTemplate) GetBranchProtection(owner, repo, branch string) (*github.ProtectionRequest, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching branch protection rules on %s", branch)
+
+	p, err := r.api.GetBranchProtection(ctx, owner, repo, branch)
+	if err != nil {
+		if errors.Is(err, github.ErrBranchNotProtected) || isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get branch protection rules on %s |→ %w", branch, err)
+	}
+
+	return protectionRequest(p), nil
+}
+
+// BranchProtectionRules sets branches protection rules, when protection is
+// set, and the merge queue of the branches in queues.
+//
+// Github API docs: https://docs.github.com/en/rest/reference/repos#update-branch-protection
+func (r *RepoTemplate) BranchProtectionRules(opts *RepoOptions, protection *github.ProtectionRequest, signedCommits bool, queues map[string]*MergeQueue) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	if err := validateMergeQueues(opts, queues); err != nil {
+		return []StepResult{{Name: "merge_queue", Action: ActionFailed, Error: err.Error()}}, err
+	}
+	if err := validateStatusChecks(protection); err != nil {
+		return []StepResult{{Name: "branch_protection", Action: ActionFailed, Error: err.Error()}}, err
+	}
+
+	for _, branch := range opts.Branches {
+		var (
+			branchSteps []StepResult
+			err         error
+		)
+		if protection != nil {
+			branchSteps, err = r.branchProtectionRules(opts, branch, protection, signedCommits)
+		}
+		if queue := queues[branch]; queue != nil && err == nil {
+			var step StepResult
+			step, err = r.MergeQueue(opts, branch, queue)
+			branchSteps = append(branchSteps, step)
+		}
+		steps = append(steps, branchSteps...)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				break
+			}
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// branchProtectionRules sets the protection rules of a single branch
+func (r *RepoTemplate) branchProtectionRules(opts *RepoOptions, branch string, protection *github.ProtectionRequest, signedCommits bool) ([]StepResult, error) {
+	ctx := context.Background()
+	owner, repo := opts.Owner, opts.Name
+
+	r.logger.Debug().Msgf("setting branch protection rules on %s", branch)
+
+	step := StepResult{Name: "branch_protection:" + branch, After: protection}
+
+	_, err := r.GetBranch(owner, repo, branch)
+	if err != nil {
+		err = fmt.Errorf("failed to get branch %s. check if the branch exists; if you are creating a new repository use the auto_init option |→ %w", branch, err)
+		return []StepResult{step.Fail(err)}, err
+	}
+
+	current, err := r.GetBranchProtection(owner, repo, branch)
+	if err != nil {
+		return []StepResult{step.Fail(err)}, err
+	}
+
+	step.Before = current
+	step.Action = action(current == nil, changed(protection, current))
+
+	if step.Action != ActionUnchanged && !opts.Plan {
+		_, err = r.api.UpdateBranchProtection(ctx, owner, repo, branch, protection)
+		if err != nil {
+			err = fmt.Errorf("failed to set branch protection rules on %s |→ %w", branch, err)
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+
+	sign := StepResult{Name: "required_signed_commits:" + branch, After: signedCommits}
+
+	enabled, err := r.GetBranchCommitSignProtection(owner, repo, branch)
+	if err != nil {
+		err = fmt.Errorf("failed to get branch protection rules for signed commits on %s |→ %w", branch, err)
+		return []StepResult{step, sign.Fail(err)}, err
+	}
+
+	sign.Before = enabled
+	sign.Action = action(false, enabled != signedCommits)
+
+	switch {
+	case sign.Action == ActionUnchanged || opts.Plan:
+	case signedCommits:
+		if err := r.CreateBranchCommitSignProtection(owner, repo, branch); err != nil {
+			err = fmt.Errorf("failed to set branch protection rules for signed commits on %s |→ %w", branch, err)
+			return []StepResult{step, sign.Fail(err)}, err
+		}
+	default:
+		if err := r.DeleteBranchCommitSignProtection(owner, repo, branch); err != nil {
+			err = fmt.Errorf("failed to delete branch protection rules for signed commits on %s |→ %w", branch, err)
+			return []StepResult{step, sign.Fail(err)}, err
+		}
+	}
+
+	return []StepResult{step, sign}, nil
+}
+
+// GetBranchCommitSignProtection reports whether signed commits are required on a branch.
+//
+// Github API docs: https://docs.github.com/en/rest/branches/branch-protection#get-commit-signature-protection
+func (r *RepoTemplate) GetBranchCommitSignProtection(owner, repo string, branch string) (bool, error) {
+	ctx := context.Background()
+
+	res, err := r.api.GetSignaturesProtectedBranch(ctx, owner, repo, branch)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return res.GetEnabled(), nil
+}
+
+// CreateBranchCommitSignProtection sets branch protection rules for signed commits.
+//
+// Github API docs: https://docs.github.com/en/rest/branches/branch-protection#require-commit-signature-protection
+func (r *RepoTemplate) CreateBranchCommitSignProtection(owner, repo string, branch string) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting branch protection rules for signed commits on %s", branch)
+
+	_, err := r.api.RequireSignaturesOnProtectedBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBranchCommitSignProtection deletes branch protection rules for signed commits.
+//
+// Github API docs: https://docs.github.com/en/rest/branches/branch-protection#delete-commit-signature-protection
+func (r *RepoTemplate) DeleteBranchCommitSignProtection(owner, repo string, branch string) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("making signed commits optional on %s", branch)
+
+	err := r.api.OptionalSignaturesOnProtectedBranch(ctx, owner, repo, branch)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceTopics replaces the topics of a repository.
+//
+// Github API docs: https://docs.github.com/en/rest/reference/repos#replace-all-topics-for-a-repository
+func (r *RepoTemplate) ReplaceTopics(owner, repo string, topics []string) error {
+	ctx := context.Background()
+
+	_, err := r.api.ReplaceAllTopics(ctx, owner, repo, topics)
+	if err != nil {
+		return fmt.Errorf("failed to replace topics on %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// GetContent fetches a file from a repository, nil if the file does not exist.
+//
+// Github API docs: https://docs.github.com/en/rest/repos/contents#get-repository-content
+func (r *RepoTemplate) GetContent(owner, repo, path string) (*github.RepositoryContent, error) {
+	ctx := context.Background()
+
+	res, err := r.api.GetContents(ctx, owner, repo, path)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get file %s/%s/%s |→ %w", owner, repo, path, err)
+	}
+
+	return res, nil
+}
+
+// CreateUpdateContent creates or updates a file in a repository, the sha of the current file is required to update it.
+//
+// Github API docs: https://docs.github.com/en/rest/reference/repos#create-or-update-file-contents
+// Github API docs: https://docs.github.com/en/rest/reference/repos#update-a-file
+func (r *RepoTemplate) CreateUpdateContent(owner, repo, path string, content []byte, sha *string) error {
+	ctx := context.Background()
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: github.String(fmt.Sprintf("Add/Update %s", path)),
+		Content: content,
+	}
+
+	if sha != nil {
+		opts.SHA = sha
+
+		err := r.api.UpdateFile(ctx, owner, repo, path, opts)
+
+		if err != nil {
+			return fmt.Errorf("failed to update file %s/%s/%s |→ %w", owner, repo, path, err)
+		}
+
+		return nil
+	}
+
+	err := r.api.CreateFile(ctx, owner, repo, path, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s/%s/%s |→ %w", owner, repo, path, err)
+	}
+
+	return nil
+}
+
+// EditRepo updates the settings of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/repos#update-a-repository
+func (r *RepoTemplate) EditRepo(owner, repo string, settings *github.Repository) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("updating repo %s/%s", owner, repo)
+
+	api, err := extension[RepositoryEditAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.EditRepository(ctx, owner, repo, settings); err != nil {
+		return fmt.Errorf("failed to update repo %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeleteBranchProtection removes the protection rules of a branch.
+//
+// GitHub API docs: https://docs.github.com/en/rest/branches/branch-protection#delete-branch-protection
+func (r *RepoTemplate) DeleteBranchProtection(owner, repo, branch string) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("removing branch protection rules on %s", branch)
+
+	api, err := extension[RollbackAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.RemoveBranchProtection(ctx, owner, repo, branch); err != nil {
+		return fmt.Errorf("failed to remove branch protection rules on %s |→ %w", branch, err)
+	}
+
+	return nil
+}
+
+// DeleteContent deletes a file.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/contents#delete-a-file
+func (r *RepoTemplate) DeleteContent(owner, repo, path, sha string) error {
+	ctx := context.Background()
+
+	opts := &github.RepositoryContentFileOptions{
+		Message: github.String(fmt.Sprintf("Delete %s", path)),
+		SHA:     github.String(sha),
+	}
+
+	api, err := extension[RollbackAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteFile(ctx, owner, repo, path, opts); err != nil {
+		return fmt.Errorf("failed to delete file %s/%s/%s |→ %w", owner, repo, path, err)
+	}
+
+	return nil
+}
+
+// isNotFound reports whether the GitHub API responded with 404 Not Found
+func isNotFound(err error) bool {
+	var res *github.ErrorResponse
+	return errors.As(err, &res) && res.Response != nil && res.Response.StatusCode == http.StatusNotFound
+}
+
+// protectionRequest converts the protection rules of a branch into the request that sets them
+func protectionRequest(p *github.Protection) *github.ProtectionRequest {
+	req := &github.ProtectionRequest{
+		RequiredStatusChecks: p.RequiredStatusChecks,
+		EnforceAdmins:        p.EnforceAdmins != nil && p.EnforceAdmins.Enabled,
+	}
+
+	if r := p.RequiredPullRequestReviews; r != nil {
+		req.RequiredPullRequestReviews = &github.PullRequestReviewsEnforcementRequest{
+			DismissStaleReviews:          r.DismissStaleReviews,
+			RequireCodeOwnerReviews:      r.RequireCodeOwnerReviews,
+			RequiredApprovingReviewCount: r.RequiredApprovingReviewCount,
+			RequireLastPushApproval:      github.Bool(r.RequireLastPushApproval),
+		}
+
+		if d := r.DismissalRestrictions; d != nil {
+			users, teams, apps := actors(d.Users, d.Teams, d.Apps)
+			req.RequiredPullRequestReviews.DismissalRestrictionsRequest = &github.DismissalRestrictionsRequest{
+				Users: &users,
+				Teams: &teams,
+				Apps:  &apps,
+			}
+		}
+
+		if b := r.BypassPullRequestAllowances; b != nil {
+			users, teams, apps := actors(b.Users, b.Teams, b.Apps)
+			req.RequiredPullRequestReviews.BypassPullRequestAllowancesRequest = &github.BypassPullRequestAllowancesRequest{
+				Users: users,
+				Teams: teams,
+				Apps:  apps,
+			}
+		}
+	}
+
+	if r := p.Restrictions; r != nil {
+		users, teams, apps := actors(r.Users, r.Teams, r.Apps)
+		req.Restrictions = &github.BranchRestrictionsRequest{Users: users, Teams: teams, Apps: apps}
+	}
+
+	if p.RequireLinearHistory != nil {
+		req.RequireLinearHistory = github.Bool(p.RequireLinearHistory.Enabled)
+	}
+	if p.AllowForcePushes != nil {
+		req.AllowForcePushes = github.Bool(p.AllowForcePushes.Enabled)
+	}
+	if p.AllowDeletions != nil {
+		req.AllowDeletions = github.Bool(p.AllowDeletions.Enabled)
+	}
+	if p.RequiredConversationResolution != nil {
+		req.RequiredConversationResolution = github.Bool(p.RequiredConversationResolution.Enabled)
+	}
+	if p.BlockCreations != nil {
+		req.BlockCreations = p.BlockCreations.Enabled
+	}
+	if p.LockBranch != nil {
+		req.LockBranch = p.LockBranch.Enabled
+	}
+	if p.AllowForkSyncing != nil {
+		req.AllowForkSyncing = p.AllowForkSyncing.Enabled
+	}
+
+	return req
+}
+
+// actors returns the user logins, team slugs and app slugs
+func actors(users []*github.User, teams []*github.Team, apps []*github.App) ([]string, []string, []string) {
+	u, t, a := []string{}, []string{}, []string{}
+	for _, user := range users {
+		u = append(u, user.GetLogin())
+	}
+	for _, team := range teams {
+		t = append(t, team.GetSlug())
+	}
+	for _, app := range apps {
+		a = append(a, app.GetSlug())
+	}
+
+	return u, t, a
+}
@@ -0,0 +1,103 @@
+package ght
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHubClientEnvVarNotFound(t *testing.T) {
+	os.Unsetenv("GITHUB_TOKEN")
+
+	_, err := NewRepoTemplate()
+	assert.NotNil(t, err)
+	assert.Equal(t, "GITHUB_TOKEN is not set", err.Error())
+}
+
+func TestGitHubClient(t *testing.T) {
+	os.Setenv("GITHUB_TOKEN", "1234567890")
+
+	res, err := NewRepoTemplate()
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+}
+
+// fakeAPI implements the GitHubAPI calls used by the tests, any other call panics
+type fakeAPI struct {
+	GitHubAPI
+	repo   *github.Repository
+	topics []string
+}
+
+func (f *fakeAPI) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, error) {
+	return f.repo, nil
+}
+
+func (f *fakeAPI) ReplaceAllTopics(ctx context.Context, owner, repo string, topics []string) ([]string, error) {
+	f.topics = topics
+	return topics, nil
+}
+
+func TestGitHubClientWithAPI(t *testing.T) {
+	os.Unsetenv("GITHUB_TOKEN")
+
+	res, err := NewRepoTemplate(WithAPI(&fakeAPI{}), WithLogger(zerolog.Nop()))
+	assert.Nil(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, DefaultFetcher, res.Fetcher())
+}
+
+func TestGitHubClientWithFetcher(t *testing.T) {
+	f := &Fetcher{maxSize: DefaultMaxFetchSize}
+
+	res, err := NewRepoTemplate(WithClient(github.NewClient(nil)), WithFetcher(f))
+	assert.Nil(t, err)
+	assert.Equal(t, f, res.Fetcher())
+}
+
+func TestRunWithFakeAPI(t *testing.T) {
+	api := &fakeAPI{repo: &github.Repository{Name: github.String("ght"), Topics: []string{"old"}}}
+
+	rt, err := NewRepoTemplate(WithAPI(api))
+	assert.Nil(t, err)
+
+	res, err := Run(rt, &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/empty.json",
+		Topics:   []string{"go"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go"}, api.topics)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+}
+
+func TestExtension(t *testing.T) {
+	api := &fakeAPI{}
+
+	_, err := extension[GitHubAPI](api)
+	assert.Nil(t, err)
+
+	// fakeAPI does not implement io.Closer
+	_, err = extension[io.Closer](api)
+	assert.ErrorIs(t, err, ErrUnsupportedAPI)
+	assert.ErrorContains(t, err, "does not implement io.Closer")
+}
+
+func TestRunWithUnsupportedFeature(t *testing.T) {
+	api := &fakeAPI{repo: &github.Repository{Name: github.String("ght")}}
+
+	rt, err := NewRepoTemplate(WithAPI(api))
+	assert.Nil(t, err)
+
+	// fakeAPI only implements GitHubAPI, not HooksAPI
+	_, err = rt.ListHooks("leocomelli", "ght")
+	assert.ErrorIs(t, err, ErrUnsupportedAPI)
+	assert.ErrorContains(t, err, "does not implement ght.HooksAPI")
+}
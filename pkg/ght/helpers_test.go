@@ -0,0 +1,64 @@
+package ght
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServer starts a fake GitHub with the acme organization and its repos,
+// closed when the test ends, and returns a RepoTemplate using it
+func newTestServer(t *testing.T, repos ...string) (*ghtest.Server, *RepoTemplate) {
+	srv := ghtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	srv.AddOrg("acme")
+	for _, name := range repos {
+		srv.AddRepo("acme", &github.Repository{Name: github.String(name)})
+	}
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	return srv, rt
+}
+
+// writeTemplate writes the config to a template file, returning the options to apply it to acme/ght
+func writeTemplate(t *testing.T, cfg *Config) *RepoOptions {
+	data, err := json.Marshal(cfg)
+	assert.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "template.json")
+	assert.Nil(t, os.WriteFile(path, data, 0o600))
+
+	return &RepoOptions{Owner: "acme", Name: "ght", Template: path}
+}
+
+// plan runs the template in plan mode, asserting that it succeeds without any write
+func plan(t *testing.T, srv *ghtest.Server, rt *RepoTemplate, opts *RepoOptions) *RepoResponse {
+	srv.Reset()
+	opts.Plan = true
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Empty(t, srv.Writes())
+
+	return res
+}
+
+// assertNoOp runs the template again, asserting that nothing changes nor is written
+func assertNoOp(t *testing.T, srv *ghtest.Server, rt *RepoTemplate, opts *RepoOptions) *RepoResponse {
+	srv.Reset()
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.False(t, res.Changed())
+	assert.Empty(t, srv.Writes())
+
+	return res
+}
@@ -0,0 +1,280 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// maskedSecret replaces the secrets in the steps, as GitHub masks them
+const maskedSecret = "********"
+
+// Webhook is a repository webhook, identified by its url
+type Webhook struct {
+	URL string `json:"url"`
+	// ContentType is json or form, json when empty
+	ContentType string `json:"content_type,omitempty"`
+	// Events are the events that trigger the webhook, push when empty
+	Events []string `json:"events,omitempty"`
+	// Active is true when empty
+	Active      *bool `json:"active,omitempty"`
+	InsecureSSL bool  `json:"insecure_ssl,omitempty"`
+	// SecretEnv is the env var holding the secret, the secret itself is never in the template
+	SecretEnv string `json:"secret_env,omitempty"`
+}
+
+// hook returns the webhook as a GitHub hook, its secret read from the env
+func (w *Webhook) hook() (*github.Hook, error) {
+	config := map[string]interface{}{
+		"url":          w.URL,
+		"content_type": "json",
+		"insecure_ssl": "0",
+	}
+	if w.ContentType != "" {
+		config["content_type"] = w.ContentType
+	}
+	if w.InsecureSSL {
+		config["insecure_ssl"] = "1"
+	}
+	if w.SecretEnv != "" {
+		secret := os.Getenv(w.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("env var %s with the secret of webhook %s is not set", w.SecretEnv, w.URL)
+		}
+		config["secret"] = secret
+	}
+
+	events := []string{"push"}
+	if len(w.Events) > 0 {
+		events = append([]string{}, w.Events...)
+		sort.Strings(events)
+	}
+
+	active := true
+	if w.Active != nil {
+		active = *w.Active
+	}
+
+	return &github.Hook{Config: config, Events: events, Active: github.Bool(active)}, nil
+}
+
+// hookChanged reports whether the current hook differs from the desired one.
+// The secrets can't be read back, so only their presence is compared.
+func hookChanged(desired, current *github.Hook) bool {
+	if desired.GetActive() != current.GetActive() {
+		return true
+	}
+
+	events := append([]string{}, current.Events...)
+	sort.Strings(events)
+	if changed(desired.Events, events) {
+		return true
+	}
+
+	for _, k := range []string{"content_type", "insecure_ssl"} {
+		if fmt.Sprint(desired.Config[k]) != fmt.Sprint(current.Config[k]) {
+			return true
+		}
+	}
+
+	_, hasSecret := desired.Config["secret"]
+	return hasSecret != (current.Config["secret"] != nil && current.Config["secret"] != "")
+}
+
+// redactHook returns a copy of the hook whose secret is masked, safe to print
+func redactHook(h *github.Hook) *github.Hook {
+	c := *h
+	c.Config = map[string]interface{}{}
+	for k, v := range h.Config {
+		c.Config[k] = v
+	}
+	if _, ok := c.Config["secret"]; ok {
+		c.Config["secret"] = maskedSecret
+	}
+
+	return &c
+}
+
+// hookURL returns the url a hook delivers to
+func hookURL(h *github.Hook) string {
+	url, _ := h.Config["url"].(string)
+	return url
+}
+
+// ListHooks fetches the webhooks of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/webhooks/repos#list-repository-webhooks
+func (r *RepoTemplate) ListHooks(owner, repo string) ([]*github.Hook, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching webhooks of %s/%s", owner, repo)
+
+	api, err := extension[HooksAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListHooks(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreateHook creates a webhook in a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/webhooks/repos#create-a-repository-webhook
+func (r *RepoTemplate) CreateHook(owner, repo string, hook *github.Hook) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating webhook %s on %s/%s", hookURL(hook), owner, repo)
+
+	api, err := extension[HooksAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.CreateHook(ctx, owner, repo, hook); err != nil {
+		return fmt.Errorf("failed to create webhook %s on %s/%s |→ %w", hookURL(hook), owner, repo, err)
+	}
+
+	return nil
+}
+
+// EditHook updates a webhook of a repository, its config is replaced as a whole.
+//
+// GitHub API docs: https://docs.github.com/en/rest/webhooks/repos#update-a-repository-webhook
+func (r *RepoTemplate) EditHook(owner, repo string, id int64, hook *github.Hook) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("updating webhook %s on %s/%s", hookURL(hook), owner, repo)
+
+	api, err := extension[HooksAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.EditHook(ctx, owner, repo, id, hook); err != nil {
+		return fmt.Errorf("failed to update webhook %s on %s/%s |→ %w", hookURL(hook), owner, repo, err)
+	}
+
+	return nil
+}
+
+// DeleteHook deletes a webhook of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/webhooks/repos#delete-a-repository-webhook
+func (r *RepoTemplate) DeleteHook(owner, repo string, id int64) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("deleting webhook %d on %s/%s", id, owner, repo)
+
+	api, err := extension[HooksAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DeleteHook(ctx, owner, repo, id); err != nil {
+		return fmt.Errorf("failed to delete webhook %d on %s/%s |→ %w", id, owner, repo, err)
+	}
+
+	return nil
+}
+
+// Webhooks creates or updates the webhooks of the template, matching the existing
+// ones by url, and deletes the others when remove is set. The secrets are never
+// part of the steps.
+func (r *RepoTemplate) Webhooks(opts *RepoOptions, webhooks []*Webhook, remove, missing bool) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	// the webhooks of a repository that does not exist yet can not be read
+	var current []*github.Hook
+	if !missing {
+		var err error
+		current, err = r.ListHooks(opts.Owner, opts.Name)
+		if err != nil {
+			return []StepResult{{Name: "webhooks", Action: ActionFailed, Error: err.Error()}}, err
+		}
+	}
+
+	byURL := map[string]*github.Hook{}
+	for _, h := range current {
+		byURL[hookURL(h)] = h
+	}
+
+	managed := map[string]bool{}
+	for _, w := range webhooks {
+		managed[w.URL] = true
+		step := StepResult{Name: "webhooks:" + w.URL, After: w}
+
+		err := r.webhook(opts, w, byURL[w.URL], &step)
+		steps = append(steps, step)
+		if err != nil {
+			errs = append(errs, err)
+			if !opts.ContinueOnError {
+				return steps, joinErrors(errs)
+			}
+		}
+	}
+
+	if !remove {
+		return steps, joinErrors(errs)
+	}
+
+	for _, h := range current {
+		if managed[hookURL(h)] {
+			continue
+		}
+
+		step := StepResult{Name: "webhooks:" + hookURL(h), Action: ActionDeleted, Before: redactHook(h)}
+
+		var err error
+		if !opts.Plan {
+			err = r.DeleteHook(opts.Owner, opts.Name, h.GetID())
+		}
+		if err != nil {
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
+
+// webhook creates or updates a single webhook, filling the step
+func (r *RepoTemplate) webhook(opts *RepoOptions, w *Webhook, current *github.Hook, step *StepResult) error {
+	desired, err := w.hook()
+	if err != nil {
+		*step = step.Fail(err)
+		return err
+	}
+
+	if current != nil {
+		step.Before = redactHook(current)
+	}
+	step.Action = action(current == nil, current != nil && hookChanged(desired, current))
+
+	switch {
+	case step.Action == ActionUnchanged || opts.Plan:
+	case current == nil:
+		err = r.CreateHook(opts.Owner, opts.Name, desired)
+	default:
+		err = r.EditHook(opts.Owner, opts.Name, current.GetID(), desired)
+	}
+	if err != nil {
+		*step = step.Fail(err)
+	}
+
+	return err
+}
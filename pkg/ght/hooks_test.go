@@ -0,0 +1,136 @@
+package ght
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooksCreateAndRerun(t *testing.T) {
+	t.Setenv("DEPLOY_HOOK_SECRET", "s3cr3t")
+
+	srv, _ := newTestServer(t, "ght")
+	var logs bytes.Buffer
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()), WithLogger(zerolog.New(&logs).Level(zerolog.DebugLevel)))
+	assert.Nil(t, err)
+
+	opts := writeTemplate(t, &Config{Webhooks: []*Webhook{
+		{URL: "https://deploy.acme.io/hook", Events: []string{"push", "pull_request"}, SecretEnv: "DEPLOY_HOOK_SECRET"},
+		{URL: "https://chat.acme.io/hook", ContentType: "form"},
+	}})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, "webhooks:https://deploy.acme.io/hook", res.Steps[1].Name)
+	assert.Equal(t, ActionCreated, res.Steps[2].Action)
+
+	hooks := srv.Hooks("acme", "ght")
+	assert.Len(t, hooks, 2)
+	assert.Equal(t, []string{"pull_request", "push"}, hooks[0].Events)
+	assert.Equal(t, "json", hooks[0].Config["content_type"])
+	assert.Equal(t, "form", hooks[1].Config["content_type"])
+	assert.Equal(t, "s3cr3t", srv.HookSecret("acme", "ght", "https://deploy.acme.io/hook"))
+
+	// the secret is never part of the results
+	out, _ := json.Marshal(res)
+	assert.False(t, strings.Contains(string(out), "s3cr3t"))
+
+	res = assertNoOp(t, srv, rt, opts)
+	out, _ = json.Marshal(res)
+	assert.False(t, strings.Contains(string(out), "s3cr3t"))
+	assert.True(t, strings.Contains(string(out), maskedSecret))
+
+	// nor of the logs
+	assert.Contains(t, logs.String(), "creating webhook https://deploy.acme.io/hook")
+	assert.NotContains(t, logs.String(), "s3cr3t")
+}
+
+func TestWebhooksRepairDriftAndRemoveUnmanaged(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddHook("acme", "ght", &github.Hook{
+		Config: map[string]interface{}{"url": "https://chat.acme.io/hook", "content_type": "json"},
+		Events: []string{"issues"},
+	})
+	srv.AddHook("acme", "ght", &github.Hook{
+		Config: map[string]interface{}{"url": "https://legacy.acme.io/hook"},
+	})
+
+	cfg := &Config{Webhooks: []*Webhook{{URL: "https://chat.acme.io/hook"}}}
+
+	// unmanaged webhooks are kept unless told otherwise
+	res, err := Run(rt, writeTemplate(t, cfg))
+	assert.Nil(t, err)
+	assert.Len(t, res.Steps, 2)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, []string{"push"}, srv.Hooks("acme", "ght")[0].Events)
+	assert.Len(t, srv.Hooks("acme", "ght"), 2)
+
+	cfg.RemoveUnmanagedWebhooks = true
+	opts := writeTemplate(t, cfg)
+
+	res = plan(t, srv, rt, opts)
+	assert.Equal(t, ActionUnchanged, res.Steps[1].Action)
+	assert.Equal(t, "webhooks:https://legacy.acme.io/hook", res.Steps[2].Name)
+	assert.Equal(t, ActionDeleted, res.Steps[2].Action)
+	assert.True(t, res.Changed())
+	assert.Len(t, srv.Hooks("acme", "ght"), 2)
+
+	opts.Plan = false
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Len(t, srv.Hooks("acme", "ght"), 1)
+}
+
+func TestWebhooksSecretNotSet(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Webhooks: []*Webhook{{URL: "https://deploy.acme.io/hook", SecretEnv: "GHT_MISSING_SECRET"}}})
+
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "GHT_MISSING_SECRET")
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Empty(t, srv.Hooks("acme", "ght"))
+}
+
+func TestWebhooksUpdateReplacesTheConfig(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddHook("acme", "ght", &github.Hook{
+		Config: map[string]interface{}{"url": "https://deploy.acme.io/hook", "content_type": "json", "secret": "old"},
+		Events: []string{"push"},
+		Active: github.Bool(true),
+	})
+	id := srv.Hooks("acme", "ght")[0].GetID()
+
+	// the secret set on GitHub is dropped when the template has none
+	opts := writeTemplate(t, &Config{Webhooks: []*Webhook{{
+		URL:         "https://deploy.acme.io/hook",
+		ContentType: "form",
+		Events:      []string{"release", "deployment"},
+		Active:      github.Bool(false),
+		InsecureSSL: true,
+	}}})
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, []string{fmt.Sprintf("PATCH /repos/acme/ght/hooks/%d", id)}, srv.Writes())
+
+	body := srv.Bodies(fmt.Sprintf("PATCH /repos/acme/ght/hooks/%d", id))[0]
+	assert.JSONEq(t, `{
+		"config": {"url": "https://deploy.acme.io/hook", "content_type": "form", "insecure_ssl": "1"},
+		"events": ["deployment", "release"],
+		"active": false
+	}`, body)
+	assert.Empty(t, srv.HookSecret("acme", "ght", "https://deploy.acme.io/hook"))
+
+	assertNoOp(t, srv, rt, opts)
+}
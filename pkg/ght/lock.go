@@ -0,0 +1,91 @@
+package ght
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// LockfileVersion is the version of the lockfile format
+const LockfileVersion = 1
+
+// Lockfile records the digest of every source resolved from a template
+type Lockfile struct {
+	Version int            `json:"version"`
+	Sources []LockedSource `json:"sources"`
+}
+
+// LockedSource is a source pinned to the digest of its content
+type LockedSource struct {
+	Source string `json:"source"`
+	SHA256 string `json:"sha256"`
+}
+
+// Lock resolves the template and every file it references, returning the lockfile
+func (f *Fetcher) Lock(opts *RepoOptions) (*Lockfile, error) {
+	cfg, err := f.LoadRepoConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, source := range cfg.Sources() {
+		if _, err := f.Data(source); err != nil {
+			return nil, err
+		}
+	}
+
+	lock := &Lockfile{Version: LockfileVersion}
+	for source, sum := range f.Resolved() {
+		lock.Sources = append(lock.Sources, LockedSource{Source: source, SHA256: sum})
+	}
+
+	sort.Slice(lock.Sources, func(i, j int) bool {
+		return lock.Sources[i].Source < lock.Sources[j].Source
+	})
+
+	return lock, nil
+}
+
+// ReadLockfile reads a lockfile from disk
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile %s |→ %w", path, err)
+	}
+
+	lock := &Lockfile{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lockfile %s |→ %w", path, err)
+	}
+
+	if lock.Version != LockfileVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %d in %s", lock.Version, path)
+	}
+
+	return lock, nil
+}
+
+// Write writes the lockfile to disk
+func (l *Lockfile) Write(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile |→ %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write lockfile %s |→ %w", path, err)
+	}
+
+	return nil
+}
+
+// Pins returns the digest of each locked source
+func (l *Lockfile) Pins() map[string]string {
+	pins := make(map[string]string, len(l.Sources))
+	for _, s := range l.Sources {
+		pins[s.Source] = s.SHA256
+	}
+
+	return pins
+}
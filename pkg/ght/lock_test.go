@@ -0,0 +1,62 @@
+package ght
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock(t *testing.T) {
+	f := &Fetcher{maxSize: DefaultMaxFetchSize}
+
+	lock, err := f.Lock(&RepoOptions{Template: "./testdata/pr_template.json"})
+	assert.Nil(t, err)
+
+	tmpl, _ := os.ReadFile("./testdata/pr_template.json")
+	pr, _ := os.ReadFile("./testdata/pull_request_template.md")
+	assert.Equal(t, &Lockfile{
+		Version: LockfileVersion,
+		Sources: []LockedSource{
+			{Source: "./testdata/pr_template.json", SHA256: Digest(tmpl)},
+			{Source: "./testdata/pull_request_template.md", SHA256: Digest(pr)},
+		},
+	}, lock)
+}
+
+func TestLockfileRoundTrip(t *testing.T) {
+	f := &Fetcher{maxSize: DefaultMaxFetchSize}
+
+	lock, err := f.Lock(&RepoOptions{Template: "./testdata/issue_template.json"})
+	assert.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "ght.lock")
+	assert.Nil(t, lock.Write(path))
+
+	read, err := ReadLockfile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, lock, read)
+}
+
+func TestLockfilePinsAreEnforced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ght.lock")
+	lock := &Lockfile{
+		Version: LockfileVersion,
+		Sources: []LockedSource{{Source: "./testdata/empty.json", SHA256: Digest([]byte("other"))}},
+	}
+	assert.Nil(t, lock.Write(path))
+
+	f, err := NewFetcher(&RepoOptions{Lockfile: path, CacheDir: t.TempDir()})
+	assert.Nil(t, err)
+	useFetcher(t, f)
+
+	_, err = LoadRepoConfig(&RepoOptions{Template: "./testdata/empty.json"})
+	assert.True(t, errors.Is(err, ErrIntegrityMismatch))
+}
+
+func TestLockfileNotFound(t *testing.T) {
+	_, err := ReadLockfile("./testdata/nonexistent.lock")
+	assert.IsType(t, &os.PathError{}, errors.Unwrap(err))
+}
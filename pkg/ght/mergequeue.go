@@ -0,0 +1,214 @@
+package ght
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeQueueRulesetName prefixes the names of the branch rulesets managed by ght, one per branch
+const MergeQueueRulesetName = "ght merge queue"
+
+// MergeQueue are the merge queue parameters of a branch, the defaults are the ones of GitHub
+type MergeQueue struct {
+	// MergeMethod is MERGE, SQUASH or REBASE, MERGE when empty
+	MergeMethod string `json:"merge_method,omitempty"`
+	// BuildConcurrency is the number of queued pull requests built at once, 5 when empty
+	BuildConcurrency int `json:"build_concurrency,omitempty"`
+	// MinGroupSize is the number of pull requests merged together at least, 1 when empty
+	MinGroupSize int `json:"min_group_size,omitempty"`
+	// MaxGroupSize is the number of pull requests merged together at most, 5 when empty
+	MaxGroupSize int `json:"max_group_size,omitempty"`
+	// WaitMinutes is the time waited for the minimum group size before merging a smaller group, 5 when empty
+	WaitMinutes *int `json:"wait_minutes,omitempty"`
+	// ChecksTimeoutMinutes is the time the required checks may take before the pull request is removed, 60 when empty
+	ChecksTimeoutMinutes int `json:"checks_timeout_minutes,omitempty"`
+	// GroupingStrategy is ALLGREEN, every commit must pass the checks, or
+	// HEADGREEN, only the head commit of the group must. ALLGREEN when empty
+	GroupingStrategy string `json:"grouping_strategy,omitempty"`
+}
+
+// mergeQueueParameters are the parameters of the merge_queue rule of a ruleset
+type mergeQueueParameters struct {
+	CheckResponseTimeoutMinutes  int    `json:"check_response_timeout_minutes"`
+	GroupingStrategy             string `json:"grouping_strategy"`
+	MaxEntriesToBuild            int    `json:"max_entries_to_build"`
+	MaxEntriesToMerge            int    `json:"max_entries_to_merge"`
+	MergeMethod                  string `json:"merge_method"`
+	MinEntriesToMerge            int    `json:"min_entries_to_merge"`
+	MinEntriesToMergeWaitMinutes int    `json:"min_entries_to_merge_wait_minutes"`
+}
+
+// withDefaults returns the parameters with the defaults of GitHub
+func (m *MergeQueue) withDefaults() *MergeQueue {
+	res := *m
+	if res.MergeMethod == "" {
+		res.MergeMethod = "MERGE"
+	}
+	if res.BuildConcurrency == 0 {
+		res.BuildConcurrency = 5
+	}
+	if res.MinGroupSize == 0 {
+		res.MinGroupSize = 1
+	}
+	if res.MaxGroupSize == 0 {
+		res.MaxGroupSize = 5
+	}
+	if res.WaitMinutes == nil {
+		wait := 5
+		res.WaitMinutes = &wait
+	}
+	if res.ChecksTimeoutMinutes == 0 {
+		res.ChecksTimeoutMinutes = 60
+	}
+	if res.GroupingStrategy == "" {
+		res.GroupingStrategy = "ALLGREEN"
+	}
+
+	return &res
+}
+
+// validate checks the parameters, with their defaults, against the limits of GitHub
+func (m *MergeQueue) validate(branch string) error {
+	switch m.MergeMethod {
+	case "MERGE", "SQUASH", "REBASE":
+	default:
+		return fmt.Errorf("merge_queue of %s merge_method must be MERGE, SQUASH or REBASE", branch)
+	}
+	if m.GroupingStrategy != "ALLGREEN" && m.GroupingStrategy != "HEADGREEN" {
+		return fmt.Errorf("merge_queue of %s grouping_strategy must be ALLGREEN or HEADGREEN", branch)
+	}
+	if m.BuildConcurrency < 1 || m.BuildConcurrency > 100 {
+		return fmt.Errorf("merge_queue of %s build_concurrency must be between 1 and 100", branch)
+	}
+	if m.MinGroupSize < 1 || m.MaxGroupSize > 100 || m.MinGroupSize > m.MaxGroupSize {
+		return fmt.Errorf("merge_queue of %s group sizes must be between 1 and 100, the minimum not above the maximum", branch)
+	}
+	if *m.WaitMinutes < 0 || *m.WaitMinutes > 360 {
+		return fmt.Errorf("merge_queue of %s wait_minutes must be between 0 and 360", branch)
+	}
+	if m.ChecksTimeoutMinutes < 1 || m.ChecksTimeoutMinutes > 360 {
+		return fmt.Errorf("merge_queue of %s checks_timeout_minutes must be between 1 and 360", branch)
+	}
+
+	return nil
+}
+
+// ruleset returns the branch ruleset enabling the merge queue on the branch
+func (m *MergeQueue) ruleset(branch string) (*Ruleset, error) {
+	params := map[string]interface{}{}
+	err := remarshal(&mergeQueueParameters{
+		CheckResponseTimeoutMinutes:  m.ChecksTimeoutMinutes,
+		GroupingStrategy:             m.GroupingStrategy,
+		MaxEntriesToBuild:            m.BuildConcurrency,
+		MaxEntriesToMerge:            m.MaxGroupSize,
+		MergeMethod:                  m.MergeMethod,
+		MinEntriesToMerge:            m.MinGroupSize,
+		MinEntriesToMergeWaitMinutes: *m.WaitMinutes,
+	}, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ruleset{
+		Name:        MergeQueueRulesetName + " " + branch,
+		Target:      "branch",
+		Enforcement: "active",
+		Conditions:  &RulesetConditions{RefName: &RulesetRefName{Include: []string{"refs/heads/" + branch}, Exclude: []string{}}},
+		Rules:       []*RulesetRule{{Type: "merge_queue", Parameters: params}},
+	}, nil
+}
+
+// mergeQueue returns the merge queue parameters of a ruleset, nil when it has no merge_queue rule
+func mergeQueue(rs *Ruleset) (*MergeQueue, error) {
+	for _, rule := range rs.Rules {
+		if rule.Type != "merge_queue" {
+			continue
+		}
+
+		p := &mergeQueueParameters{}
+		if err := remarshal(rule.Parameters, p); err != nil {
+			return nil, err
+		}
+		wait := p.MinEntriesToMergeWaitMinutes
+		return &MergeQueue{
+			MergeMethod:          p.MergeMethod,
+			BuildConcurrency:     p.MaxEntriesToBuild,
+			MinGroupSize:         p.MinEntriesToMerge,
+			MaxGroupSize:         p.MaxEntriesToMerge,
+			WaitMinutes:          &wait,
+			ChecksTimeoutMinutes: p.CheckResponseTimeoutMinutes,
+			GroupingStrategy:     p.GroupingStrategy,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// validateMergeQueues checks that the merge queues are set on the protected branches
+func validateMergeQueues(opts *RepoOptions, queues map[string]*MergeQueue) error {
+	protected := map[string]bool{}
+	for _, b := range opts.Branches {
+		protected[b] = true
+	}
+
+	var unknown []string
+	for branch := range queues {
+		if !protected[branch] {
+			unknown = append(unknown, branch)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("merge_queue branches %s are not in the branches to protect", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// MergeQueue enables the merge queue on the branch through a branch ruleset,
+// reading the parameters of the current ruleset to show the differences.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/rules#create-a-repository-ruleset
+func (r *RepoTemplate) MergeQueue(opts *RepoOptions, branch string, queue *MergeQueue) (StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+
+	desired := queue.withDefaults()
+	step := StepResult{Name: "merge_queue:" + branch, After: desired}
+
+	if err := desired.validate(branch); err != nil {
+		return step.Fail(err), err
+	}
+	rs, err := desired.ruleset(branch)
+	if err != nil {
+		return step.Fail(err), err
+	}
+
+	r.logger.Debug().Msgf("setting merge queue on %s", branch)
+
+	current, err := r.GetRulesetByName(owner, repo, "branch", rs.Name)
+	if isUnavailable(err) {
+		err = fmt.Errorf("merge queue of %s requires the rulesets, not available on %s/%s |→ %w", branch, owner, repo, err)
+	}
+	if err != nil {
+		return step.Fail(err), err
+	}
+
+	if current != nil {
+		before, err := mergeQueue(current)
+		if err != nil {
+			return step.Fail(err), err
+		}
+		step.Before = before
+		rs.ID = current.ID
+	}
+	step.Action = action(current == nil, current != nil && changed(rs, current))
+
+	if step.Action != ActionUnchanged && !opts.Plan {
+		if err := r.CreateUpdateRuleset(owner, repo, rs); err != nil {
+			return step.Fail(err), err
+		}
+	}
+
+	return step, nil
+}
@@ -0,0 +1,113 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeQueue(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	queue := &MergeQueue{MergeMethod: "SQUASH", BuildConcurrency: 10, MaxGroupSize: 8, WaitMinutes: github.Int(0)}
+	opts := writeTemplate(t, &Config{
+		BranchProtection: &github.ProtectionRequest{EnforceAdmins: true},
+		MergeQueue:       map[string]*MergeQueue{"main": queue},
+	})
+	opts.Branches = []string{"main"}
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	// the queue is added once the branch is protected
+	assert.Equal(t, []string{"PUT /repos/acme/ght/branches/main/protection", "POST /repos/acme/ght/rulesets"}, srv.Writes())
+	assert.Equal(t, "merge_queue:main", res.Steps[3].Name)
+	assert.Equal(t, ActionCreated, res.Steps[3].Action)
+
+	rs := srv.Ruleset("acme", "ght", "ght merge queue main")
+	assert.Equal(t, "branch", rs["target"])
+	assert.Equal(t, map[string]interface{}{"ref_name": map[string]interface{}{
+		"include": []interface{}{"refs/heads/main"},
+		"exclude": []interface{}{},
+	}}, rs["conditions"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"type": "merge_queue",
+		"parameters": map[string]interface{}{
+			"check_response_timeout_minutes":    float64(60),
+			"grouping_strategy":                 "ALLGREEN",
+			"max_entries_to_build":              float64(10),
+			"max_entries_to_merge":              float64(8),
+			"merge_method":                      "SQUASH",
+			"min_entries_to_merge":              float64(1),
+			"min_entries_to_merge_wait_minutes": float64(0),
+		},
+	}}, rs["rules"])
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+
+	// the current parameters are read back for the diff
+	queue.ChecksTimeoutMinutes = 30
+	opts = writeTemplate(t, &Config{MergeQueue: map[string]*MergeQueue{"main": queue}})
+	opts.Branches = []string{"main"}
+	res = plan(t, srv, rt, opts)
+	assert.Len(t, res.Steps, 2)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, 60, res.Steps[1].Before.(*MergeQueue).ChecksTimeoutMinutes)
+	assert.Equal(t, 30, res.Steps[1].After.(*MergeQueue).ChecksTimeoutMinutes)
+}
+
+func TestMergeQueueDefaults(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	// the parameters left out get the defaults of GitHub, so that the next run compares equal
+	opts := writeTemplate(t, &Config{MergeQueue: map[string]*MergeQueue{"main": {}}})
+	opts.Branches = []string{"main"}
+	_, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	rs := srv.Ruleset("acme", "ght", "ght merge queue main")
+	assert.Equal(t, map[string]interface{}{
+		"check_response_timeout_minutes":    float64(60),
+		"grouping_strategy":                 "ALLGREEN",
+		"max_entries_to_build":              float64(5),
+		"max_entries_to_merge":              float64(5),
+		"merge_method":                      "MERGE",
+		"min_entries_to_merge":              float64(1),
+		"min_entries_to_merge_wait_minutes": float64(5),
+	}, rs["rules"].([]interface{})[0].(map[string]interface{})["parameters"])
+
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestMergeQueueUnprotectedBranch(t *testing.T) {
+	_, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{MergeQueue: map[string]*MergeQueue{"develop": {}}})
+	opts.Branches = []string{"main"}
+
+	_, err := Run(rt, opts)
+	assert.ErrorContains(t, err, "merge_queue branches develop are not in the branches to protect")
+}
+
+func TestMergeQueueRulesetsUnavailable(t *testing.T) {
+	srv, rt := newTestServer(t)
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght"), Private: github.Bool(true)})
+	srv.DisableRulesets("acme", "ght")
+
+	opts := writeTemplate(t, &Config{MergeQueue: map[string]*MergeQueue{"main": {}}})
+	opts.Branches = []string{"main"}
+
+	res, err := Run(rt, opts)
+	assert.ErrorContains(t, err, "merge queue of main requires the rulesets")
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+}
+
+func TestMergeQueueValidate(t *testing.T) {
+	assert.NotNil(t, (&MergeQueue{MergeMethod: "FAST_FORWARD"}).withDefaults().validate("main"))
+	assert.NotNil(t, (&MergeQueue{MinGroupSize: 6}).withDefaults().validate("main"))
+	assert.NotNil(t, (&MergeQueue{WaitMinutes: github.Int(400)}).withDefaults().validate("main"))
+	assert.NotNil(t, (&MergeQueue{ChecksTimeoutMinutes: 361}).withDefaults().validate("main"))
+	assert.Nil(t, (&MergeQueue{GroupingStrategy: "HEADGREEN", WaitMinutes: github.Int(0)}).withDefaults().validate("main"))
+}
@@ -0,0 +1,72 @@
+package ght
+
+import (
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+)
+
+// Option configures a RepoTemplate or a Fetcher
+type Option func(*options)
+
+type options struct {
+	api      GitHubAPI
+	client   *github.Client
+	audit    *AuditLog
+	logger   zerolog.Logger
+	fetcher  *Fetcher
+	cassette *Cassette
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{logger: zerolog.Nop()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithClient uses the go-github client to reach the GitHub API
+func WithClient(client *github.Client) Option {
+	return func(o *options) {
+		o.api = nil
+		o.client = client
+	}
+}
+
+// WithAPI uses the GitHubAPI implementation, such as a fake in tests
+func WithAPI(api GitHubAPI) Option {
+	return func(o *options) {
+		o.api = api
+		o.client = nil
+	}
+}
+
+// WithLogger sets the logger, nothing is logged by default
+func WithLogger(logger zerolog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithFetcher sets the fetcher used to read the template files, defaults to DefaultFetcher
+func WithFetcher(f *Fetcher) Option {
+	return func(o *options) {
+		o.fetcher = f
+	}
+}
+
+// WithCassette records or replays the HTTP interactions with the cassette
+func WithCassette(c *Cassette) Option {
+	return func(o *options) {
+		o.cassette = c
+	}
+}
+
+// WithAuditLog records the writes made to the GitHub API in the audit log. It
+// applies to the default client and to the one given by WithClient, not to WithAPI.
+func WithAuditLog(l *AuditLog) Option {
+	return func(o *options) {
+		o.audit = l
+	}
+}
@@ -0,0 +1,65 @@
+package ght
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the run results
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// ValidateOutput checks whether the output format is supported
+func ValidateOutput(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %s, use one of %s, %s or %s", format, OutputTable, OutputJSON, OutputYAML)
+	}
+}
+
+// WriteResult writes the run results in the given format
+func WriteResult(w io.Writer, res *RepoResponse, format string) error {
+	if err := ValidateOutput(format); err != nil {
+		return err
+	}
+
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			return fmt.Errorf("failed to write json output |→ %w", err)
+		}
+	case OutputYAML:
+		// go-github types only carry json tags, so the yaml output reuses the json field names
+		v, err := normalize(res)
+		if err != nil {
+			return fmt.Errorf("failed to write yaml output |→ %w", err)
+		}
+
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to write yaml output |→ %w", err)
+		}
+		return enc.Close()
+	case OutputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "REPOSITORY\tSTEP\tACTION\tERROR\n")
+		for _, step := range res.Steps {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Fullname, step.Name, step.Action, step.Error)
+		}
+		return tw.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package ght
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+var result = &RepoResponse{
+	Fullname: "leocomelli/ght",
+	Created:  true,
+	Steps: []StepResult{
+		{Name: "repository", Action: ActionCreated, After: &github.Repository{Name: github.String("ght")}},
+		{Name: "topics", Action: ActionFailed, After: []string{"go"}, Error: "failed to replace topics"},
+	},
+}
+
+func TestWriteResultJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteResult(&buf, result, OutputJSON))
+	assert.JSONEq(t, `{
+		"fullname": "leocomelli/ght",
+		"created": true,
+		"steps": [
+			{"name": "repository", "action": "created", "after": {"name": "ght"}},
+			{"name": "topics", "action": "failed", "after": ["go"], "error": "failed to replace topics"}
+		]
+	}`, buf.String())
+}
+
+func TestWriteResultYAML(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteResult(&buf, result, OutputYAML))
+	assert.YAMLEq(t, `
+fullname: leocomelli/ght
+created: true
+steps:
+  - name: repository
+    action: created
+    after:
+      name: ght
+  - name: topics
+    action: failed
+    after: [go]
+    error: failed to replace topics
+`, buf.String())
+}
+
+func TestWriteResultTable(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, WriteResult(&buf, result, OutputTable))
+	assert.Equal(t, `REPOSITORY      STEP        ACTION   ERROR
+leocomelli/ght  repository  created  
+leocomelli/ght  topics      failed   failed to replace topics
+`, buf.String())
+}
+
+func TestWriteResultUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteResult(&buf, result, "xml")
+	assert.NotNil(t, err)
+	assert.Equal(t, "unsupported output format xml, use one of table, json or yaml", err.Error())
+}
@@ -0,0 +1,289 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// certificateFailures are the states of a certificate that will not be approved
+var certificateFailures = map[string]bool{
+	"errored":               true,
+	"bad_authz":             true,
+	"authorization_revoked": true,
+	"dns_changed":           true,
+}
+
+// PagesConfig is the GitHub Pages site of the repository, the settings left empty are not managed
+type PagesConfig struct {
+	// BuildType is legacy, built from a branch, or workflow, built by GitHub Actions. legacy when empty
+	BuildType string `json:"build_type,omitempty"`
+	// Source is the branch and the path, / or /docs, of the legacy builds
+	Source *github.PagesSource `json:"source,omitempty"`
+	// CNAME is the custom domain, an empty string removes it
+	CNAME         *string `json:"cname,omitempty"`
+	HTTPSEnforced *bool   `json:"https_enforced,omitempty"`
+	// Visibility is public or private, private requires GitHub Enterprise Cloud
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// PagesSite is the GitHub Pages site as read and written by the API, with
+// the build type go-github v50 lacks
+type PagesSite struct {
+	Status           *string                       `json:"status,omitempty"`
+	BuildType        *string                       `json:"build_type,omitempty"`
+	Source           *github.PagesSource           `json:"source,omitempty"`
+	CNAME            *string                       `json:"cname,omitempty"`
+	HTTPSEnforced    *bool                         `json:"https_enforced,omitempty"`
+	Public           *bool                         `json:"public,omitempty"`
+	HTTPSCertificate *github.PagesHTTPSCertificate `json:"https_certificate,omitempty"`
+}
+
+// GetCNAME returns the custom domain, empty when there is none
+func (p *PagesSite) GetCNAME() string {
+	if p == nil || p.CNAME == nil {
+		return ""
+	}
+	return *p.CNAME
+}
+
+// GetHTTPSEnforced reports whether the https is enforced
+func (p *PagesSite) GetHTTPSEnforced() bool {
+	return p != nil && p.HTTPSEnforced != nil && *p.HTTPSEnforced
+}
+
+// validate checks the build type, the source and the visibility of the site
+func (p *PagesConfig) validate() error {
+	switch p.BuildType {
+	case "", "legacy":
+		if p.Source == nil || p.Source.GetBranch() == "" {
+			return fmt.Errorf("pages source branch is required by the legacy build type")
+		}
+		if path := p.Source.GetPath(); path != "" && path != "/" && path != "/docs" {
+			return fmt.Errorf("pages source path must be / or /docs")
+		}
+	case "workflow":
+		if p.Source != nil {
+			return fmt.Errorf("pages source requires the legacy build type")
+		}
+	default:
+		return fmt.Errorf("pages build_type must be legacy or workflow")
+	}
+	if p.Visibility != "" && p.Visibility != "public" && p.Visibility != "private" {
+		return fmt.Errorf("pages visibility must be public or private")
+	}
+
+	return nil
+}
+
+// desired returns the settings with their defaults
+func (p *PagesConfig) desired() *PagesConfig {
+	desired := *p
+	if desired.BuildType == "" {
+		desired.BuildType = "legacy"
+	}
+	if desired.Source != nil {
+		desired.Source = &github.PagesSource{Branch: p.Source.Branch, Path: github.String(p.Source.GetPath())}
+		if desired.Source.GetPath() == "" {
+			desired.Source.Path = github.String("/")
+		}
+	}
+
+	return &desired
+}
+
+// site returns the request setting the site as configured
+func (p *PagesConfig) site() *PagesSite {
+	site := &PagesSite{
+		BuildType:     github.String(p.BuildType),
+		Source:        p.Source,
+		CNAME:         p.CNAME,
+		HTTPSEnforced: p.HTTPSEnforced,
+	}
+	if p.Visibility != "" {
+		site.Public = github.Bool(p.Visibility == "public")
+	}
+
+	return site
+}
+
+// pagesConfig returns the settings of a site, comparable with the configured ones
+func pagesConfig(site *PagesSite) *PagesConfig {
+	cfg := &PagesConfig{
+		BuildType:     "legacy",
+		Source:        site.Source,
+		CNAME:         github.String(""),
+		HTTPSEnforced: github.Bool(site.HTTPSEnforced != nil && *site.HTTPSEnforced),
+		Visibility:    "public",
+	}
+	if site.BuildType != nil {
+		cfg.BuildType = *site.BuildType
+	}
+	if site.CNAME != nil {
+		cfg.CNAME = site.CNAME
+	}
+	if site.Public != nil && !*site.Public {
+		cfg.Visibility = "private"
+	}
+
+	return cfg
+}
+
+// GetPages fetches the GitHub Pages site of a repository, nil when Pages is not enabled.
+//
+// GitHub API docs: https://docs.github.com/en/rest/pages/pages#get-a-apiname-pages-site
+func (r *RepoTemplate) GetPages(owner, repo string) (*PagesSite, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching GitHub Pages site of %s/%s", owner, repo)
+
+	api, err := extension[PagesAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.GetPages(ctx, owner, repo)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch GitHub Pages site of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// CreatePages enables GitHub Pages on a repository, using the build type and the source of the site.
+//
+// GitHub API docs: https://docs.github.com/en/rest/pages/pages#create-a-apiname-pages-site
+func (r *RepoTemplate) CreatePages(owner, repo string, site *PagesSite) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("creating GitHub Pages site of %s/%s", owner, repo)
+
+	api, err := extension[PagesAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.CreatePages(ctx, owner, repo, &PagesSite{BuildType: site.BuildType, Source: site.Source}); err != nil {
+		return fmt.Errorf("failed to create GitHub Pages site of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// UpdatePages updates the GitHub Pages site of a repository, the empty fields are left as they are.
+//
+// GitHub API docs: https://docs.github.com/en/rest/pages/pages#update-information-about-a-apiname-pages-site
+func (r *RepoTemplate) UpdatePages(owner, repo string, site *PagesSite) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("updating GitHub Pages site of %s/%s", owner, repo)
+
+	api, err := extension[PagesAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.UpdatePages(ctx, owner, repo, site); err != nil {
+		return fmt.Errorf("failed to update GitHub Pages site of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// certificateState returns the state of the certificate of the custom domain
+// of the site, failing when it will not be approved
+func (r *RepoTemplate) certificateState(owner, repo string) (string, error) {
+	site, err := r.GetPages(owner, repo)
+	if err != nil {
+		return "", err
+	}
+	if site == nil {
+		return "", fmt.Errorf("GitHub Pages is not enabled on %s/%s", owner, repo)
+	}
+
+	state := site.HTTPSCertificate.GetState()
+	if certificateFailures[state] {
+		err := fmt.Errorf("certificate of %s is %s", site.GetCNAME(), state)
+		if d := site.HTTPSCertificate.GetDescription(); d != "" {
+			err = fmt.Errorf("%w: %s", err, d)
+		}
+		return state, err
+	}
+
+	return state, nil
+}
+
+// Pages enables or updates the GitHub Pages site of the repository. When a
+// custom domain is set, the https is enforced once GitHub approved its
+// certificate, which may take a few minutes: ght does not wait for it, the
+// step warns that the https is left to a later run.
+func (r *RepoTemplate) Pages(opts *RepoOptions, cfg *PagesConfig, missing bool) (StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	step := StepResult{Name: "pages", After: cfg}
+
+	if err := cfg.validate(); err != nil {
+		return step, err
+	}
+	desired := cfg.desired()
+	step.After = desired
+
+	// the site of a repository that does not exist yet can not be read
+	var current *PagesSite
+	if !missing || !opts.Plan {
+		var err error
+		if current, err = r.GetPages(owner, repo); err != nil {
+			return step, err
+		}
+	}
+	if current != nil {
+		step.Before = pagesConfig(current)
+	}
+	step.Action = action(current == nil, current != nil && changed(desired, step.Before))
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return step, nil
+	}
+
+	site := desired.site()
+	if current == nil {
+		if err := r.CreatePages(owner, repo, site); err != nil {
+			return step, err
+		}
+		current = &PagesSite{}
+	}
+
+	// the https of a custom domain can't be enforced before its certificate is approved
+	wait := site.GetHTTPSEnforced() && site.GetCNAME() != "" && (!current.GetHTTPSEnforced() || current.GetCNAME() != site.GetCNAME())
+	if wait {
+		site.HTTPSEnforced = nil
+	}
+
+	// the build type and the source were set on creation
+	if step.Action == ActionUpdated || site.CNAME != nil || site.HTTPSEnforced != nil || site.Public != nil {
+		if err := r.UpdatePages(owner, repo, site); err != nil {
+			return step, err
+		}
+	}
+
+	if wait {
+		state, err := r.certificateState(owner, repo)
+		if err != nil {
+			return step, fmt.Errorf("failed to enforce https on GitHub Pages site of %s/%s |→ %w", owner, repo, err)
+		}
+		if state != "approved" {
+			if state == "" {
+				state = "not issued yet"
+			}
+			step.Warning = fmt.Sprintf("the certificate of %s is %s, https is enforced by a later run once it is approved", site.GetCNAME(), state)
+			r.logger.Warn().Msgf("GitHub Pages site of %s/%s: %s", owner, repo, step.Warning)
+			return step, nil
+		}
+		return step, r.UpdatePages(owner, repo, &PagesSite{HTTPSEnforced: github.Bool(true)})
+	}
+
+	return step, nil
+}
@@ -0,0 +1,102 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	pages := &PagesConfig{
+		Source:        &github.PagesSource{Branch: github.String("gh-pages"), Path: github.String("/docs")},
+		CNAME:         github.String("docs.acme.io"),
+		HTTPSEnforced: github.Bool(true),
+	}
+	opts := writeTemplate(t, &Config{Pages: pages})
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "pages", res.Steps[1].Name)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+
+	// the certificate of the new domain is not approved yet, the https is left to the next run
+	assert.Equal(t, "the certificate of docs.acme.io is new, https is enforced by a later run once it is approved", res.Steps[1].Warning)
+	site, buildType := srv.Pages("acme", "ght")
+	assert.Equal(t, "legacy", buildType)
+	assert.Equal(t, "gh-pages", site.GetSource().GetBranch())
+	assert.Equal(t, "/docs", site.GetSource().GetPath())
+	assert.Equal(t, "docs.acme.io", site.GetCNAME())
+	assert.False(t, site.GetHTTPSEnforced())
+	assert.Equal(t, []string{`{"build_type":"legacy","source":{"branch":"gh-pages","path":"/docs"}}`}, srv.Bodies("POST /repos/acme/ght/pages"))
+	assert.Equal(t, []string{`{"build_type":"legacy","source":{"branch":"gh-pages","path":"/docs"},"cname":"docs.acme.io"}`}, srv.Bodies("PUT /repos/acme/ght/pages"))
+
+	srv.Reset()
+	res, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Empty(t, res.Steps[1].Warning)
+	// the https is enforced on its own, once the rest of the site is set
+	assert.Equal(t, []string{
+		`{"build_type":"legacy","source":{"branch":"gh-pages","path":"/docs"},"cname":"docs.acme.io"}`,
+		`{"https_enforced":true}`,
+	}, srv.Bodies("PUT /repos/acme/ght/pages"))
+	site, _ = srv.Pages("acme", "ght")
+	assert.Equal(t, "approved", site.GetHTTPSCertificate().GetState())
+	assert.True(t, site.GetHTTPSEnforced())
+
+	// once the https is enforced, a run is a no-op
+	assertNoOp(t, srv, rt, opts)
+
+	// the drift is reported in plan mode, then fixed
+	opts = writeTemplate(t, &Config{Pages: &PagesConfig{BuildType: "workflow", CNAME: github.String("docs.acme.io")}})
+	res = plan(t, srv, rt, opts)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+
+	opts.Plan = false
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+	site, buildType = srv.Pages("acme", "ght")
+	assert.Equal(t, "workflow", buildType)
+	assert.True(t, site.GetHTTPSEnforced())
+}
+
+func TestPagesCertificateErrored(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	pages := &PagesConfig{BuildType: "workflow", CNAME: github.String("docs.acme.io")}
+	_, err := Run(rt, writeTemplate(t, &Config{Pages: pages}))
+	assert.Nil(t, err)
+	srv.SetPagesCertificate("acme", "ght", "bad_authz")
+
+	pages.HTTPSEnforced = github.Bool(true)
+	res, err := Run(rt, writeTemplate(t, &Config{Pages: pages}))
+	assert.ErrorContains(t, err, "certificate of docs.acme.io is bad_authz")
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+
+	site, _ := srv.Pages("acme", "ght")
+	assert.False(t, site.GetHTTPSEnforced())
+}
+
+func TestPagesPlanOnMissingRepo(t *testing.T) {
+	srv, rt := newTestServer(t)
+
+	opts := writeTemplate(t, &Config{
+		Repository: &github.Repository{Name: github.String("ght")},
+		Pages:      &PagesConfig{BuildType: "workflow"},
+	})
+
+	res := plan(t, srv, rt, opts)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+}
+
+func TestPagesConfigValidate(t *testing.T) {
+	assert.NotNil(t, (&PagesConfig{}).validate())
+	assert.NotNil(t, (&PagesConfig{Source: &github.PagesSource{Branch: github.String("main"), Path: github.String("/site")}}).validate())
+	assert.NotNil(t, (&PagesConfig{BuildType: "workflow", Source: &github.PagesSource{Branch: github.String("main")}}).validate())
+	assert.NotNil(t, (&PagesConfig{BuildType: "workflow", Visibility: "internal"}).validate())
+	assert.Nil(t, (&PagesConfig{Source: &github.PagesSource{Branch: github.String("main")}}).validate())
+}
@@ -0,0 +1,190 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// ActionsPermissions restricts what the GitHub Actions of the repository can
+// run and do, the settings left empty are not managed
+type ActionsPermissions struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	// AllowedActions is all, local_only or selected
+	AllowedActions string `json:"allowed_actions,omitempty"`
+	// SelectedActions are the actions allowed when AllowedActions is selected,
+	// the patterns_allowed such as "acme/*" or "docker/login-action@v3"
+	SelectedActions *github.ActionsAllowed `json:"selected_actions,omitempty"`
+	Workflow        *WorkflowPermissions   `json:"workflow,omitempty"`
+	// ForkPRApproval is the contributors whose pull requests from forks need an
+	// approval to run the workflows: first_time_contributors_new_to_github,
+	// first_time_contributors or all_external_contributors
+	ForkPRApproval string `json:"fork_pr_approval,omitempty"`
+	// RetentionDays is the number of days the artifacts and logs are kept, 1 to 400
+	RetentionDays int `json:"retention_days,omitempty"`
+}
+
+// WorkflowPermissions are the default permissions of the GITHUB_TOKEN
+type WorkflowPermissions struct {
+	// DefaultWorkflowPermissions is read or write
+	DefaultWorkflowPermissions   string `json:"default_workflow_permissions,omitempty"`
+	CanApprovePullRequestReviews *bool  `json:"can_approve_pull_request_reviews,omitempty"`
+}
+
+// validate checks the values of the permissions
+func (p *ActionsPermissions) validate() error {
+	switch p.AllowedActions {
+	case "", "all", "local_only", "selected":
+	default:
+		return fmt.Errorf("actions permissions allowed_actions must be all, local_only or selected")
+	}
+	if p.SelectedActions != nil && p.AllowedActions != "selected" {
+		return fmt.Errorf("actions permissions selected_actions requires allowed_actions selected")
+	}
+	if p.Workflow != nil {
+		switch p.Workflow.DefaultWorkflowPermissions {
+		case "", "read", "write":
+		default:
+			return fmt.Errorf("actions permissions default_workflow_permissions must be read or write")
+		}
+	}
+	switch p.ForkPRApproval {
+	case "", "first_time_contributors_new_to_github", "first_time_contributors", "all_external_contributors":
+	default:
+		return fmt.Errorf("actions permissions fork_pr_approval must be first_time_contributors_new_to_github, first_time_contributors or all_external_contributors")
+	}
+	if p.RetentionDays < 0 || p.RetentionDays > 400 {
+		return fmt.Errorf("actions permissions retention_days must be between 1 and 400")
+	}
+
+	return nil
+}
+
+// actionsSetting is an Actions setting read and written through its own endpoint
+type actionsSetting struct {
+	name    string
+	desired interface{}
+	get     func() (interface{}, error)
+	set     func() error
+}
+
+// settings returns the managed settings, the permissions first as the others depend on them
+func (r *RepoTemplate) settings(api ActionsPermissionsAPI, owner, repo string, p *ActionsPermissions) []*actionsSetting {
+	ctx := context.Background()
+
+	var settings []*actionsSetting
+	if p.Enabled != nil || p.AllowedActions != "" {
+		desired := &github.ActionsPermissionsRepository{Enabled: p.Enabled}
+		if p.AllowedActions != "" {
+			desired.AllowedActions = github.String(p.AllowedActions)
+		}
+		settings = append(settings, &actionsSetting{
+			name:    "actions",
+			desired: desired,
+			get:     func() (interface{}, error) { return api.GetActionsPermissions(ctx, owner, repo) },
+			set: func() error {
+				// enabled is required by GitHub
+				req := *desired
+				if req.Enabled == nil {
+					req.Enabled = github.Bool(true)
+				}
+				return api.EditActionsPermissions(ctx, owner, repo, &req)
+			},
+		})
+	}
+
+	// the other settings can't be written while the Actions are disabled
+	if p.Enabled != nil && !*p.Enabled {
+		return settings
+	}
+
+	if p.SelectedActions != nil {
+		settings = append(settings, &actionsSetting{
+			name:    "selected_actions",
+			desired: p.SelectedActions,
+			get:     func() (interface{}, error) { return api.GetActionsAllowed(ctx, owner, repo) },
+			set:     func() error { return api.EditActionsAllowed(ctx, owner, repo, p.SelectedActions) },
+		})
+	}
+	if p.Workflow != nil {
+		settings = append(settings, &actionsSetting{
+			name:    "workflow",
+			desired: p.Workflow,
+			get:     func() (interface{}, error) { return api.GetWorkflowPermissions(ctx, owner, repo) },
+			set:     func() error { return api.EditWorkflowPermissions(ctx, owner, repo, p.Workflow) },
+		})
+	}
+	if p.ForkPRApproval != "" {
+		settings = append(settings, &actionsSetting{
+			name:    "fork_pr_approval",
+			desired: p.ForkPRApproval,
+			get:     func() (interface{}, error) { return api.GetForkPRApproval(ctx, owner, repo) },
+			set:     func() error { return api.EditForkPRApproval(ctx, owner, repo, p.ForkPRApproval) },
+		})
+	}
+	if p.RetentionDays != 0 {
+		settings = append(settings, &actionsSetting{
+			name:    "retention_days",
+			desired: p.RetentionDays,
+			get:     func() (interface{}, error) { return api.GetRetentionDays(ctx, owner, repo) },
+			set:     func() error { return api.EditRetentionDays(ctx, owner, repo, p.RetentionDays) },
+		})
+	}
+
+	return settings
+}
+
+// ActionsPermissions applies the Actions permissions of the template, one step
+// per setting, named permissions:<setting>. When the Actions are disabled, the
+// other settings are left as they are.
+//
+// GitHub API docs: https://docs.github.com/en/rest/actions/permissions
+func (r *RepoTemplate) ActionsPermissions(opts *RepoOptions, permissions *ActionsPermissions, missing bool) ([]StepResult, error) {
+	var (
+		steps []StepResult
+		errs  []error
+	)
+
+	if err := permissions.validate(); err != nil {
+		return []StepResult{{Name: "permissions", Action: ActionFailed, Error: err.Error()}}, err
+	}
+	api, err := extension[ActionsPermissionsAPI](r.api)
+	if err != nil {
+		return []StepResult{{Name: "permissions", Action: ActionFailed, Error: err.Error()}}, err
+	}
+
+	for _, s := range r.settings(api, opts.Owner, opts.Name, permissions) {
+		step := StepResult{Name: "permissions:" + s.name, After: s.desired, Action: ActionUpdated}
+
+		// the settings of a repository that does not exist yet can not be read
+		var err error
+		if !missing || !opts.Plan {
+			r.logger.Debug().Msgf("fetching Actions %s permissions of %s/%s", s.name, opts.Owner, opts.Name)
+
+			var current interface{}
+			current, err = s.get()
+			if err == nil {
+				step.Before = current
+				step.Action = action(false, changed(s.desired, current))
+			}
+		}
+
+		if err == nil && step.Action != ActionUnchanged && !opts.Plan {
+			r.logger.Debug().Msgf("setting Actions %s permissions of %s/%s", s.name, opts.Owner, opts.Name)
+			err = s.set()
+		}
+
+		if err != nil {
+			err = fmt.Errorf("failed to apply Actions %s permissions of %s/%s |→ %w", s.name, opts.Owner, opts.Name, err)
+			step = step.Fail(err)
+			errs = append(errs, err)
+		}
+		steps = append(steps, step)
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
@@ -0,0 +1,101 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionsPermissions(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{Permissions: &ActionsPermissions{
+		AllowedActions: "selected",
+		SelectedActions: &github.ActionsAllowed{
+			GithubOwnedAllowed: github.Bool(true),
+			PatternsAllowed:    []string{"acme/*", "docker/login-action@v3"},
+		},
+		Workflow:       &WorkflowPermissions{DefaultWorkflowPermissions: "read", CanApprovePullRequestReviews: github.Bool(false)},
+		ForkPRApproval: "all_external_contributors",
+		RetentionDays:  30,
+	}}})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	actions := map[string]Action{}
+	for _, step := range res.Steps[1:] {
+		actions[step.Name] = step.Action
+	}
+	assert.Equal(t, map[string]Action{
+		"permissions:actions":          ActionUpdated,
+		"permissions:selected_actions": ActionUpdated,
+		"permissions:workflow":         ActionUnchanged,
+		"permissions:fork_pr_approval": ActionUpdated,
+		"permissions:retention_days":   ActionUpdated,
+	}, actions)
+
+	assert.Equal(t, map[string]interface{}{
+		"enabled":                          true,
+		"allowed_actions":                  "selected",
+		"github_owned_allowed":             true,
+		"verified_allowed":                 false,
+		"patterns_allowed":                 []string{"acme/*", "docker/login-action@v3"},
+		"default_workflow_permissions":     "read",
+		"can_approve_pull_request_reviews": false,
+		"approval_policy":                  "all_external_contributors",
+		"days":                             30,
+	}, srv.ActionsPermissions("acme", "ght"))
+
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestActionsPermissionsRequests(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{Permissions: &ActionsPermissions{
+		AllowedActions:  "selected",
+		SelectedActions: &github.ActionsAllowed{PatternsAllowed: []string{"acme/*"}},
+		Workflow:        &WorkflowPermissions{DefaultWorkflowPermissions: "write", CanApprovePullRequestReviews: github.Bool(true)},
+	}}})
+
+	srv.Reset()
+	_, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	// the selected actions can only be set once the repository allows the selected ones
+	assert.Equal(t, []string{
+		"PUT /repos/acme/ght/actions/permissions",
+		"PUT /repos/acme/ght/actions/permissions/selected-actions",
+		"PUT /repos/acme/ght/actions/permissions/workflow",
+	}, srv.Writes())
+	assert.Equal(t, []string{`{"enabled":true,"allowed_actions":"selected"}`}, srv.Bodies("PUT /repos/acme/ght/actions/permissions"))
+	assert.Equal(t, []string{`{"patterns_allowed":["acme/*"]}`}, srv.Bodies("PUT /repos/acme/ght/actions/permissions/selected-actions"))
+	assert.Equal(t, []string{`{"default_workflow_permissions":"write","can_approve_pull_request_reviews":true}`}, srv.Bodies("PUT /repos/acme/ght/actions/permissions/workflow"))
+}
+
+func TestActionsPermissionsDisabled(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	// the other settings are left as they are
+	opts := writeTemplate(t, &Config{Actions: &ActionsConfig{Permissions: &ActionsPermissions{
+		Enabled:       github.Bool(false),
+		RetentionDays: 30,
+	}}})
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Len(t, res.Steps, 2)
+	assert.Equal(t, "permissions:actions", res.Steps[1].Name)
+	assert.Equal(t, false, srv.ActionsPermissions("acme", "ght")["enabled"])
+	assert.Equal(t, 90, srv.ActionsPermissions("acme", "ght")["days"])
+}
+
+func TestActionsPermissionsValidate(t *testing.T) {
+	assert.NotNil(t, (&ActionsPermissions{AllowedActions: "some"}).validate())
+	assert.NotNil(t, (&ActionsPermissions{AllowedActions: "all", SelectedActions: &github.ActionsAllowed{}}).validate())
+	assert.NotNil(t, (&ActionsPermissions{Workflow: &WorkflowPermissions{DefaultWorkflowPermissions: "admin"}}).validate())
+	assert.NotNil(t, (&ActionsPermissions{ForkPRApproval: "nobody"}).validate())
+	assert.NotNil(t, (&ActionsPermissions{RetentionDays: 500}).validate())
+	assert.Nil(t, (&ActionsPermissions{AllowedActions: "local_only", RetentionDays: 7}).validate())
+}
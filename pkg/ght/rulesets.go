@@ -0,0 +1,132 @@
+package ght
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Ruleset is a ruleset of the repository, applying rules to the branches or the tags whose names match
+type Ruleset struct {
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Target is branch or tag
+	Target string `json:"target,omitempty"`
+	// Enforcement is active, evaluate or disabled
+	Enforcement  string                `json:"enforcement"`
+	BypassActors []*RulesetBypassActor `json:"bypass_actors,omitempty"`
+	Conditions   *RulesetConditions    `json:"conditions,omitempty"`
+	Rules        []*RulesetRule        `json:"rules,omitempty"`
+}
+
+// RulesetBypassActor is allowed to bypass the rules of a ruleset
+type RulesetBypassActor struct {
+	ActorID int64 `json:"actor_id"`
+	// ActorType is RepositoryRole, Team, Integration or OrganizationAdmin
+	ActorType string `json:"actor_type"`
+	// BypassMode is always or pull_request
+	BypassMode string `json:"bypass_mode,omitempty"`
+}
+
+// RulesetConditions select the refs a ruleset applies to
+type RulesetConditions struct {
+	RefName *RulesetRefName `json:"ref_name"`
+}
+
+// RulesetRefName matches the full names of the refs, such as refs/tags/v*,
+// ~DEFAULT_BRANCH or ~ALL
+type RulesetRefName struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// RulesetRule is a rule of a ruleset, such as deletion or merge_queue, with its parameters
+type RulesetRule struct {
+	Type       string                 `json:"type"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// sortRules sorts the rules and the ref names of a ruleset, as GitHub does not keep their order
+func (rs *Ruleset) sortRules() {
+	sort.Slice(rs.Rules, func(i, j int) bool { return rs.Rules[i].Type < rs.Rules[j].Type })
+	if c := rs.Conditions; c != nil && c.RefName != nil {
+		sort.Strings(c.RefName.Include)
+		sort.Strings(c.RefName.Exclude)
+	}
+}
+
+// isUnavailable reports whether the rulesets are not available on the
+// repository, such as the private repositories of the free plans
+func isUnavailable(err error) bool {
+	var res *github.ErrorResponse
+	return errors.As(err, &res) && res.Response != nil &&
+		(res.Response.StatusCode == http.StatusForbidden || res.Response.StatusCode == http.StatusNotFound)
+}
+
+// GetRulesetByName fetches the ruleset of a repository with the given target
+// and name, nil when there is none. The rulesets of the organization are ignored.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/rules#get-all-repository-rulesets
+func (r *RepoTemplate) GetRulesetByName(owner, repo, target, name string) (*Ruleset, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching %s ruleset %s of %s/%s", target, name, owner, repo)
+
+	api, err := extension[RulesetsAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesets, err := api.ListRulesets(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rulesets of %s/%s |→ %w", owner, repo, err)
+	}
+
+	for _, rs := range rulesets {
+		if rs.Target != target || rs.Name != name {
+			continue
+		}
+
+		// the list does not include the conditions and the rules
+		res, err := api.GetRuleset(ctx, owner, repo, rs.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch ruleset %s of %s/%s |→ %w", name, owner, repo, err)
+		}
+		res.sortRules()
+		return res, nil
+	}
+
+	return nil, nil
+}
+
+// CreateUpdateRuleset creates the ruleset, or updates it when it has an id.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/rules#update-a-repository-ruleset
+func (r *RepoTemplate) CreateUpdateRuleset(owner, repo string, rs *Ruleset) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting ruleset %s of %s/%s", rs.Name, owner, repo)
+
+	api, err := extension[RulesetsAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	req := *rs
+	req.ID = 0
+
+	if rs.ID == 0 {
+		err = api.CreateRuleset(ctx, owner, repo, &req)
+	} else {
+		err = api.UpdateRuleset(ctx, owner, repo, rs.ID, &req)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set ruleset %s of %s/%s |→ %w", rs.Name, owner, repo, err)
+	}
+
+	return nil
+}
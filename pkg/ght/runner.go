@@ -0,0 +1,440 @@
+package ght
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	PullRequestTemplate = ".github/pull_request_template.md"
+	IssueTemplate       = ".github/issue_template.md"
+)
+
+var (
+	// ErrRepoConfigNotFound is returned when no repository section is found in the template file
+	ErrRepoConfigNotFound = errors.New("no repository section in template file")
+	// ErrRemoteSecretSource is returned when a remote template reads a secret from a local file or command
+	ErrRemoteSecretSource = errors.New("remote templates can't read secrets from local files or commands")
+)
+
+// Action is what ght did to a setting
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionDeleted   Action = "deleted"
+	ActionUnchanged Action = "unchanged"
+	ActionSkipped   Action = "skipped"
+	ActionFailed    Action = "failed"
+)
+
+// StepResult represents the outcome of a single step of the run
+type StepResult struct {
+	Name   string      `json:"name"`
+	Action Action      `json:"action"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// Warning is about a setting that is applied but is likely wrong
+	Warning string `json:"warning,omitempty"`
+}
+
+// Fail marks the step as failed
+func (s StepResult) Fail(err error) StepResult {
+	s.Action = ActionFailed
+	s.Error = err.Error()
+	return s
+}
+
+// RepoResponse represents the action output
+type RepoResponse struct {
+	Fullname string       `json:"fullname"`
+	Created  bool         `json:"created"`
+	Steps    []StepResult `json:"steps"`
+}
+
+// add appends the step to the response, marking it as failed when err is not nil
+func (r *RepoResponse) add(step StepResult, err error) error {
+	if err != nil {
+		step = step.Fail(err)
+	}
+	r.Steps = append(r.Steps, step)
+
+	return err
+}
+
+// changes reports whether the action creates, updates or deletes a setting
+func (a Action) changes() bool {
+	return a == ActionCreated || a == ActionUpdated || a == ActionDeleted
+}
+
+// action returns the action taken on a setting that is missing or changed
+func action(missing, changed bool) Action {
+	switch {
+	case missing:
+		return ActionCreated
+	case changed:
+		return ActionUpdated
+	default:
+		return ActionUnchanged
+	}
+}
+
+// Run performs the actions according to the repo config.
+//
+// The run stops at the first failure unless opts.ContinueOnError is set, in
+// which case the remaining sections are still applied and all the failures are
+// returned together. In plan mode (opts.Plan) nothing is written and the steps
+// report the actions that would be taken. When opts.Snapshot is set, the
+// settings the run may change are written to that file before any write, so
+// that they can be restored by Rollback. The steps changing a setting the
+// snapshot does not record, such as the webhooks, carry a warning.
+func Run(rt *RepoTemplate, opts *RepoOptions) (*RepoResponse, error) {
+	res := &RepoResponse{
+		Fullname: fmt.Sprintf("%s/%s", opts.Owner, opts.Name),
+	}
+
+	cfg, err := rt.Fetcher().LoadRepoConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.logger.Debug().Msgf("Loading repo config from %s", opts.Template)
+
+	// Check if repo exists
+	repoStep := StepResult{Name: "repository", Action: ActionUnchanged}
+	current, err := rt.GetRepo(opts.Owner, opts.Name)
+	if err != nil && !isNotFound(err) {
+		return res, res.add(repoStep, errors.Unwrap(err))
+	}
+
+	// Capture the settings about to change, before any write
+	if opts.Snapshot != "" && !opts.Plan {
+		snap, err := rt.Snapshot(opts, cfg, current)
+		if err != nil {
+			return res, fmt.Errorf("failed to take snapshot |→ %w", err)
+		}
+		if err := snap.Write(opts.Snapshot); err != nil {
+			return res, err
+		}
+		if len(snap.Uncovered) > 0 {
+			rt.logger.Warn().Msgf("the snapshot does not record %s, ght rollback can not restore them", strings.Join(snap.Uncovered, ", "))
+			defer warnUncovered(res)
+		}
+	}
+
+	// Create repo if it doesn't exist
+	missing := err != nil
+	if missing {
+		if cfg.Repository == nil && cfg.TemplateRepo == nil {
+			return res, res.add(repoStep, ErrRepoConfigNotFound)
+		}
+
+		repoStep.Action = ActionCreated
+		repoStep.After = cfg.Repository
+		if cfg.TemplateRepo != nil {
+			repoStep.After = cfg.TemplateRepo
+		}
+
+		if !opts.Plan {
+			created, err := rt.CreateRepo(opts, cfg)
+			if err != nil {
+				return res, res.add(repoStep, err)
+			}
+			res.Created = true
+			repoStep.After = created
+		}
+	}
+	_ = res.add(repoStep, nil)
+
+	var errs []error
+	// stop collects the error and reports whether the run must stop
+	stop := func(err error) bool {
+		if err == nil {
+			return false
+		}
+		errs = append(errs, err)
+		return !opts.ContinueOnError
+	}
+
+	// Create the branches and set the default branch
+	if cfg.DefaultBranch != "" || len(cfg.Branches) > 0 {
+		steps, err := rt.Branches(opts, cfg.DefaultBranch, cfg.Branches, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Replace topics
+	if opts.Topics != nil && len(opts.Topics) > 0 {
+		step := StepResult{Name: "topics", After: opts.Topics, Action: ActionUpdated}
+		if current != nil {
+			step.Before = current.Topics
+			step.Action = action(false, changed(opts.Topics, current.Topics))
+		}
+
+		var err error
+		if step.Action != ActionUnchanged && !opts.Plan {
+			err = rt.ReplaceTopics(opts.Owner, opts.Name, opts.Topics)
+		}
+		if stop(res.add(step, err)) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Configure pull request template
+	if cfg.PullRequestTemplate != "" {
+		if stop(res.add(CreateOrUpdateContent(rt, opts, PullRequestTemplate, cfg.PullRequestTemplate))) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Configure issue template
+	if cfg.IssueTemplate != "" {
+		if stop(res.add(CreateOrUpdateContent(rt, opts, IssueTemplate, cfg.IssueTemplate))) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Update branch protection rules and merge queues
+	if cfg.BranchProtection != nil || len(cfg.MergeQueue) > 0 {
+		if len(opts.Branches) == 0 {
+			_ = res.add(StepResult{Name: "branch_protection", Action: ActionSkipped, After: cfg.BranchProtection}, nil)
+		}
+
+		// the branches of a repository that does not exist yet can not be read
+		if missing && opts.Plan {
+			for _, branch := range opts.Branches {
+				if cfg.BranchProtection != nil {
+					_ = res.add(StepResult{Name: "branch_protection:" + branch, Action: ActionCreated, After: cfg.BranchProtection}, nil)
+					_ = res.add(StepResult{Name: "required_signed_commits:" + branch, Action: action(false, cfg.RequiredSignedCommits), After: cfg.RequiredSignedCommits}, nil)
+				}
+				if queue := cfg.MergeQueue[branch]; queue != nil {
+					_ = res.add(StepResult{Name: "merge_queue:" + branch, Action: ActionCreated, After: queue.withDefaults()}, nil)
+				}
+			}
+		} else {
+			protection := cfg.BranchProtection
+			if cfg.StatusChecks != nil && len(opts.Branches) > 0 {
+				var step StepResult
+				protection, step, err = rt.StatusChecks(opts, protection, cfg.StatusChecks)
+				if stop(res.add(step, err)) {
+					return res, joinErrors(errs)
+				}
+			}
+
+			steps, err := rt.BranchProtectionRules(opts, protection, cfg.RequiredSignedCommits, cfg.MergeQueue)
+			res.Steps = append(res.Steps, steps...)
+			if stop(err) {
+				return res, joinErrors(errs)
+			}
+		}
+	}
+
+	// Protect the release tags and the releases
+	if cfg.TagProtection != nil {
+		steps, err := rt.TagProtection(opts, cfg.TagProtection, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	if cfg.Releases != nil {
+		steps, err := rt.Releases(opts, cfg.Releases, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Reconcile webhooks
+	if len(cfg.Webhooks) > 0 || cfg.RemoveUnmanagedWebhooks {
+		steps, err := rt.Webhooks(opts, cfg.Webhooks, cfg.RemoveUnmanagedWebhooks, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Reconcile deploy keys
+	if len(cfg.DeployKeys) > 0 || cfg.RemoveUnmanagedDeployKeys {
+		steps, err := rt.DeployKeys(opts, cfg.DeployKeys, cfg.RemoveUnmanagedDeployKeys, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Reconcile autolinks
+	if len(cfg.Autolinks) > 0 {
+		steps, err := rt.Autolinks(opts, cfg.Autolinks, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Configure the GitHub Pages site
+	if cfg.Pages != nil {
+		if stop(res.add(rt.Pages(opts, cfg.Pages, missing))) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Provision Actions secrets and variables, and restrict the Actions
+	if cfg.Actions != nil {
+		if len(cfg.Actions.Secrets) > 0 {
+			steps, err := rt.ActionsSecrets(opts, cfg.Actions.Secrets, missing)
+			res.Steps = append(res.Steps, steps...)
+			if stop(err) {
+				return res, joinErrors(errs)
+			}
+		}
+
+		if len(cfg.Actions.Variables) > 0 {
+			steps, err := rt.ActionsVariables(opts, cfg.Actions.Variables, missing)
+			res.Steps = append(res.Steps, steps...)
+			if stop(err) {
+				return res, joinErrors(errs)
+			}
+		}
+
+		if cfg.Actions.Permissions != nil {
+			steps, err := rt.ActionsPermissions(opts, cfg.Actions.Permissions, missing)
+			res.Steps = append(res.Steps, steps...)
+			if stop(err) {
+				return res, joinErrors(errs)
+			}
+		}
+	}
+
+	// Reconcile deployment environments
+	if len(cfg.Environments) > 0 {
+		steps, err := rt.Environments(opts, cfg.Environments, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Toggle security features
+	if cfg.Security != nil {
+		steps, err := rt.Security(opts, cfg.Security, missing)
+		res.Steps = append(res.Steps, steps...)
+		if stop(err) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	// Configure the code scanning default setup
+	if cfg.CodeScanning != nil {
+		if stop(res.add(rt.CodeScanning(opts, cfg.CodeScanning, missing))) {
+			return res, joinErrors(errs)
+		}
+	}
+
+	return res, joinErrors(errs)
+}
+
+// joinErrors returns nil, the only error or all the errors joined
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errors.Join(errs...)
+	}
+}
+
+// Changed reports whether any step created, updated or deleted a setting
+func (r *RepoResponse) Changed() bool {
+	for _, step := range r.Steps {
+		if step.Action.changes() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateOrUpdateContent creates the file in the repository or updates it when the content differs
+func CreateOrUpdateContent(rt *RepoTemplate, opts *RepoOptions, ghPath, path string) (StepResult, error) {
+	step := StepResult{Name: ghPath}
+
+	data, err := rt.Fetcher().Data(path)
+	if err != nil {
+		return step, err
+	}
+
+	current, err := rt.GetContent(opts.Owner, opts.Name, ghPath)
+	if err != nil {
+		return step, err
+	}
+
+	sha := blobSHA(data)
+	step.After = sha
+	if current != nil {
+		step.Before = current.GetSHA()
+	}
+	step.Action = action(current == nil, current.GetSHA() != sha)
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return step, nil
+	}
+
+	var currentSHA *string
+	if current != nil {
+		currentSHA = current.SHA
+	}
+
+	if err := rt.CreateUpdateContent(opts.Owner, opts.Name, ghPath, data, currentSHA); err != nil {
+		return step, err
+	}
+
+	return step, nil
+}
+
+// blobSHA returns the git blob sha of the content, the same sha GitHub returns for a file
+func blobSHA(data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LoadRepoConfig loads the repository config from a file or url
+func LoadRepoConfig(opts *RepoOptions) (*Config, error) {
+	return DefaultFetcher.LoadRepoConfig(opts)
+}
+
+// LoadRepoConfig loads the repository config from a file or url
+func (f *Fetcher) LoadRepoConfig(opts *RepoOptions) (*Config, error) {
+	data, err := f.Data(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json |→ %w", err)
+	}
+
+	// whoever can change a remote template must not run commands nor read files on this machine
+	if IsRemote(opts.Template) && !opts.AllowRemoteSecretSources {
+		if err := cfg.checkSecretSources(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
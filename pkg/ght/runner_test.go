@@ -1,4 +1,4 @@
-package main
+package ght
 
 import (
 	"encoding/json"
@@ -134,6 +134,22 @@ var mocks = map[string]func() mock.MockBackendOption{
 			},
 		)
 	},
+	"GetBranchProtection_404": func() mock.MockBackendOption {
+		return mock.WithRequestMatchHandler(
+			mock.GetReposBranchesProtectionByOwnerByRepoByBranch,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusNotFound, "Branch not protected")
+			}),
+		)
+	},
+	"GetBranchProtectionSignCommit_404": func() mock.MockBackendOption {
+		return mock.WithRequestMatchHandler(
+			mock.GetReposBranchesProtectionRequiredSignaturesByOwnerByRepoByBranch,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusNotFound, "Branch not protected")
+			}),
+		)
+	},
 	"UpdateBranchProtection": func() mock.MockBackendOption {
 		return mock.WithRequestMatch(
 			mock.PutReposBranchesProtectionByOwnerByRepoByBranch,
@@ -236,7 +252,7 @@ func TestTemplateLocalFileNotFound(t *testing.T) {
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/nonexistent.json",
+		Template: "./testdata/nonexistent.json",
 	}
 
 	_, err := LoadRepoConfig(opts)
@@ -248,7 +264,7 @@ func TestTemplateLocalFile(t *testing.T) {
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/existing-repo.json",
+		Template: "./testdata/existing-repo.json",
 	}
 
 	cfg, err := LoadRepoConfig(opts)
@@ -260,7 +276,7 @@ func TestTemplateInvalidLocalFile(t *testing.T) {
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/invalid-syntax.json",
+		Template: "./testdata/invalid-syntax.json",
 	}
 
 	_, err := LoadRepoConfig(opts)
@@ -281,10 +297,12 @@ func TestTemplateRemoteFileNotFound(t *testing.T) {
 }
 
 func TestTemplateRemoteFile(t *testing.T) {
+	srv := newTLSFileServer(t)
+
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "https://raw.githubusercontent.com/leocomelli/ght/main/testing/existing-repo.json",
+		Template: srv.URL + "/testdata/existing-repo.json",
 	}
 
 	cfg, err := LoadRepoConfig(opts)
@@ -296,7 +314,7 @@ func TestErrorLoadingRepoConfig(t *testing.T) {
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/nonexistent.json",
+		Template: "./testdata/nonexistent.json",
 	}
 
 	_, err := Run(nil, opts)
@@ -310,11 +328,11 @@ func TestInternalServerErrorWhenGetRepo(t *testing.T) {
 		mocks["GetRepo_500"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/empty.json",
+		Template: "./testdata/empty.json",
 	}
 
 	_, err := Run(rt, opts)
@@ -328,11 +346,11 @@ func TestGetRepo(t *testing.T) {
 		mocks["GetRepo"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/empty.json",
+		Template: "./testdata/empty.json",
 	}
 
 	res, err := Run(rt, opts)
@@ -347,11 +365,11 @@ func TestCreateWithNoRepoConfig(t *testing.T) {
 		mocks["GetRepo_404"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/empty.json",
+		Template: "./testdata/empty.json",
 	}
 
 	_, err := Run(rt, opts)
@@ -368,11 +386,11 @@ func TestCreateSimpleRepo(t *testing.T) {
 		mocks["ReplaceTopics"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo.json",
+		Template: "./testdata/simple-repo.json",
 		Topics:   []string{"topic1", "topic2"},
 	}
 
@@ -390,11 +408,11 @@ func TestCreateSimpleUserRepo(t *testing.T) {
 		mocks["CreateRepoUser"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo.json",
+		Template: "./testdata/simple-repo.json",
 		Branches: []string{"main"},
 		Debug:    true,
 	}
@@ -413,11 +431,11 @@ func TestErrorCreatingSimpleRepo(t *testing.T) {
 		mocks["CreateRepo_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo.json",
+		Template: "./testdata/simple-repo.json",
 	}
 
 	_, err := Run(rt, opts)
@@ -435,11 +453,11 @@ func TestCreateSimpleRepoUsingTemplate(t *testing.T) {
 		mocks["ReplaceTopics"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo-template.json",
+		Template: "./testdata/simple-repo-template.json",
 	}
 
 	res, err := Run(rt, opts)
@@ -456,11 +474,11 @@ func TestErrorCreatingSimpleRepoUsingTemplate(t *testing.T) {
 		mocks["ReplaceTopics"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo-template.json",
+		Template: "./testdata/simple-repo-template.json",
 	}
 
 	_, err := Run(rt, opts)
@@ -478,15 +496,17 @@ func TestCreateRepoWithBranchProtection(t *testing.T) {
 		mocks["CreateRepo"](),
 		mocks["ReplaceTopics"](),
 		mocks["GetBranch"](),
+		mocks["GetBranchProtection_404"](),
+		mocks["GetBranchProtectionSignCommit_404"](),
 		mocks["UpdateBranchProtection"](),
 		mocks["DeleteBranchProtectionSignCommit"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/repo-branch-protection.json",
+		Template: "./testdata/repo-branch-protection.json",
 		Branches: []string{"main"},
 	}
 
@@ -495,6 +515,14 @@ func TestCreateRepoWithBranchProtection(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "leocomelli/ght", res.Fullname)
 	assert.Equal(t, true, res.Created)
+
+	assert.Len(t, res.Steps, 3)
+	assert.Equal(t, "repository", res.Steps[0].Name)
+	assert.Equal(t, ActionCreated, res.Steps[0].Action)
+	assert.Equal(t, "branch_protection:main", res.Steps[1].Name)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, "required_signed_commits:main", res.Steps[2].Name)
+	assert.Equal(t, ActionUnchanged, res.Steps[2].Action)
 }
 
 func TestErrorBranchNotFoundCreatingRepoWithBranchProtection(t *testing.T) {
@@ -505,11 +533,11 @@ func TestErrorBranchNotFoundCreatingRepoWithBranchProtection(t *testing.T) {
 		mocks["ReplaceTopics"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/repo-branch-protection.json",
+		Template: "./testdata/repo-branch-protection.json",
 		Branches: []string{"main"},
 	}
 
@@ -525,15 +553,17 @@ func TestErrorCreatingRepoWithBranchProtection(t *testing.T) {
 		mocks["GetOrg"](),
 		mocks["CreateRepo"](),
 		mocks["GetBranch"](),
+		mocks["GetBranchProtection_404"](),
+		mocks["GetBranchProtectionSignCommit_404"](),
 		mocks["UpdateBranchProtection_400"](),
 		mocks["ReplaceTopics"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/repo-branch-protection.json",
+		Template: "./testdata/repo-branch-protection.json",
 		Branches: []string{"main"},
 	}
 
@@ -552,15 +582,17 @@ func TestCreateRepoWithBranchProtectionAndSignedCommit(t *testing.T) {
 		mocks["GetOrg"](),
 		mocks["ReplaceTopics"](),
 		mocks["GetBranch"](),
+		mocks["GetBranchProtection_404"](),
+		mocks["GetBranchProtectionSignCommit_404"](),
 		mocks["UpdateBranchProtection"](),
 		mocks["UpdateBranchProtectionSignCommit"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/repo-branch-protection-complete.json",
+		Template: "./testdata/repo-branch-protection-complete.json",
 		Branches: []string{"main"},
 	}
 
@@ -578,15 +610,17 @@ func TestErrorCreatingRepoWithBranchProtectionAndSignedCommit(t *testing.T) {
 		mocks["GetOrg"](),
 		mocks["ReplaceTopics"](),
 		mocks["GetBranch"](),
+		mocks["GetBranchProtection_404"](),
+		mocks["GetBranchProtectionSignCommit_404"](),
 		mocks["UpdateBranchProtection"](),
 		mocks["UpdateBranchProtectionSignCommit_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/repo-branch-protection-complete.json",
+		Template: "./testdata/repo-branch-protection-complete.json",
 		Branches: []string{"main"},
 	}
 
@@ -606,11 +640,11 @@ func TestErrorUpdatingRepoTopics(t *testing.T) {
 		mocks["ReplaceTopics_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/simple-repo.json",
+		Template: "./testdata/simple-repo.json",
 		Branches: []string{"main"},
 		Topics:   []string{"topic1", "topic2"},
 	}
@@ -630,11 +664,11 @@ func TestCreateContentFile(t *testing.T) {
 		mocks["CreateFileContent"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/pr_template.json",
+		Template: "./testdata/pr_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -652,11 +686,11 @@ func TestErrorCreatingContentFile(t *testing.T) {
 		mocks["CreateFileContent_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/pr_template.json",
+		Template: "./testdata/pr_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -674,11 +708,11 @@ func TestErrorGettingFileContent(t *testing.T) {
 		mocks["GetFileContent_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/pr_template.json",
+		Template: "./testdata/pr_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -697,11 +731,11 @@ func TestUpdatePullRequestTemplate(t *testing.T) {
 		mocks["UpdateFileContent"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/pr_template.json",
+		Template: "./testdata/pr_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -719,11 +753,11 @@ func TestUpdateIssueTemplate(t *testing.T) {
 		mocks["UpdateFileContent"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/issue_template.json",
+		Template: "./testdata/issue_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -741,11 +775,11 @@ func TestErrorUpdatingPullRequestTemplate(t *testing.T) {
 		mocks["UpdateFileContent_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/pr_template.json",
+		Template: "./testdata/pr_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -764,11 +798,11 @@ func TestErrorUpdatingIssueTemplate(t *testing.T) {
 		mocks["UpdateFileContent_400"](),
 	)
 
-	rt := &RepoTemplate{client: github.NewClient(mockedHTTPClient)}
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
 	opts := &RepoOptions{
 		Owner:    "leocomelli",
 		Name:     "ght",
-		Template: "./testing/issue_template.json",
+		Template: "./testdata/issue_template.json",
 		Branches: []string{"main"},
 	}
 
@@ -779,3 +813,266 @@ func TestErrorUpdatingIssueTemplate(t *testing.T) {
 	assert.IsType(t, &github.ErrorResponse{}, err)
 	assert.Equal(t, http.StatusBadRequest, err.(*github.ErrorResponse).Response.StatusCode)
 }
+
+func TestBranchProtectionWithoutBranches(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/existing-repo.json",
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "branch_protection", res.Steps[1].Name)
+	assert.Equal(t, ActionSkipped, res.Steps[1].Action)
+}
+
+func TestBranchProtectionUnchanged(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mocks["GetBranch"](),
+		mock.WithRequestMatch(
+			mock.GetReposBranchesProtectionByOwnerByRepoByBranch,
+			github.Protection{
+				RequiredStatusChecks: &github.RequiredStatusChecks{Strict: true, Checks: []*github.RequiredStatusCheck{}},
+				RequiredPullRequestReviews: &github.PullRequestReviewsEnforcement{
+					DismissStaleReviews:     true,
+					RequireCodeOwnerReviews: true,
+				},
+				EnforceAdmins: &github.AdminEnforcement{Enabled: true},
+			},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposBranchesProtectionRequiredSignaturesByOwnerByRepoByBranch,
+			github.SignaturesProtectedBranch{Enabled: github.Bool(true)},
+		),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/existing-repo.json",
+		Branches: []string{"main"},
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUnchanged, res.Steps[1].Action)
+	assert.Equal(t, ActionUnchanged, res.Steps[2].Action)
+	assert.Equal(t, true, res.Steps[2].Before)
+}
+
+func TestBranchProtectionFailedStep(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mocks["GetBranch"](),
+		mocks["GetBranchProtection_404"](),
+		mocks["UpdateBranchProtection_400"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/existing-repo.json",
+		Branches: []string{"main"},
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.NotNil(t, err)
+	assert.Len(t, res.Steps, 2)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Equal(t, err.Error(), res.Steps[1].Error)
+}
+
+func TestTopicsUnchanged(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposByOwnerByRepo,
+			github.Repository{
+				Name:   github.String("ght"),
+				Topics: []string{"topic1", "topic2"},
+			},
+		),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/empty.json",
+		Topics:   []string{"topic1", "topic2"},
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, StepResult{
+		Name:   "topics",
+		Action: ActionUnchanged,
+		Before: []string{"topic1", "topic2"},
+		After:  []string{"topic1", "topic2"},
+	}, res.Steps[1])
+}
+
+func TestContentFileUnchanged(t *testing.T) {
+	data, _ := os.ReadFile("./testdata/pull_request_template.md")
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mock.WithRequestMatch(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			github.RepositoryContent{
+				Path: github.String(".github/pull_request_template.md"),
+				SHA:  github.String(blobSHA(data)),
+			},
+		),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/pr_template.json",
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, PullRequestTemplate, res.Steps[1].Name)
+	assert.Equal(t, ActionUnchanged, res.Steps[1].Action)
+}
+
+func TestBlobSHA(t *testing.T) {
+	// git hash-object of an empty file
+	assert.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", blobSHA([]byte{}))
+}
+
+func TestContinueOnError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mocks["GetFileContent_404"](),
+		mocks["CreateFileContent_400"](),
+		mocks["ReplaceTopics_400"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:           "leocomelli",
+		Name:            "ght",
+		Template:        "./testdata/templates.json",
+		Topics:          []string{"topic1"},
+		ContinueOnError: true,
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.NotNil(t, err)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+	assert.Len(t, res.Steps, 4)
+	for _, step := range res.Steps[1:] {
+		assert.Equal(t, ActionFailed, step.Action)
+	}
+}
+
+func TestStopOnFirstError(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mocks["GetFileContent_404"](),
+		mocks["CreateFileContent_400"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/templates.json",
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.NotNil(t, err)
+	assert.IsType(t, &github.ErrorResponse{}, errors.Unwrap(err))
+	assert.Len(t, res.Steps, 2)
+}
+
+func TestContinueOnErrorWithBranches(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:           "leocomelli",
+		Name:            "ght",
+		Template:        "./testdata/existing-repo.json",
+		Branches:        []string{"main", "develop"},
+		ContinueOnError: true,
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.NotNil(t, err)
+	assert.Len(t, res.Steps, 3)
+	assert.Equal(t, "branch_protection:main", res.Steps[1].Name)
+	assert.Equal(t, "branch_protection:develop", res.Steps[2].Name)
+}
+
+func TestPlanNewRepo(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo_404"](),
+		mocks["GetFileContent_404"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/repo-branch-protection-complete.json",
+		Branches: []string{"main"},
+		Topics:   []string{"topic1"},
+		Plan:     true,
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.False(t, res.Created)
+	assert.True(t, res.Changed())
+
+	actions := []Action{}
+	for _, step := range res.Steps {
+		actions = append(actions, step.Action)
+	}
+	assert.Equal(t, []Action{ActionCreated, ActionUpdated, ActionCreated, ActionUpdated}, actions)
+}
+
+func TestPlanExistingRepo(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mocks["GetRepo"](),
+		mocks["GetFileContent"](),
+	)
+
+	rt := &RepoTemplate{api: NewGitHubAPI(github.NewClient(mockedHTTPClient))}
+	opts := &RepoOptions{
+		Owner:    "leocomelli",
+		Name:     "ght",
+		Template: "./testdata/pr_template.json",
+		Plan:     true,
+	}
+
+	res, err := Run(rt, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, "a1b2c3d4e5f6g7h8i9j0", res.Steps[1].Before)
+}
@@ -0,0 +1,206 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// SecurityConfig toggles the security features of the repository, the features left empty are not managed
+type SecurityConfig struct {
+	// VulnerabilityAlerts are the Dependabot alerts, with the dependency graph
+	VulnerabilityAlerts *bool `json:"vulnerability_alerts,omitempty"`
+	// AutomatedSecurityFixes are the Dependabot security updates, they require the vulnerability alerts
+	AutomatedSecurityFixes *bool `json:"automated_security_fixes,omitempty"`
+	SecretScanning         *bool `json:"secret_scanning,omitempty"`
+	// SecretScanningPushProtection requires the secret scanning
+	SecretScanningPushProtection  *bool `json:"secret_scanning_push_protection,omitempty"`
+	PrivateVulnerabilityReporting *bool `json:"private_vulnerability_reporting,omitempty"`
+}
+
+// securityFeature is a security feature read and toggled through its own endpoint
+type securityFeature struct {
+	name    string
+	desired *bool
+	get     func() (bool, error)
+	set     func(bool) error
+}
+
+// status returns the status of a security_and_analysis feature
+func status(enabled bool) *string {
+	if enabled {
+		return github.String("enabled")
+	}
+
+	return github.String("disabled")
+}
+
+// features returns the security features, each one after the features it depends on
+func (r *RepoTemplate) features(api SecurityAPI, edit RepositoryEditAPI, owner, repo string, cfg *SecurityConfig) []*securityFeature {
+	ctx := context.Background()
+
+	// the secret scanning is part of the repository, fetched once
+	var analysis *github.SecurityAndAnalysis
+	getAnalysis := func() (*github.SecurityAndAnalysis, error) {
+		if analysis == nil {
+			res, err := r.GetRepo(owner, repo)
+			if err != nil {
+				return nil, err
+			}
+			analysis = res.GetSecurityAndAnalysis()
+			if analysis == nil {
+				analysis = &github.SecurityAndAnalysis{}
+			}
+		}
+		return analysis, nil
+	}
+
+	return []*securityFeature{
+		{
+			name:    "vulnerability_alerts",
+			desired: cfg.VulnerabilityAlerts,
+			get:     func() (bool, error) { return api.GetVulnerabilityAlerts(ctx, owner, repo) },
+			set:     func(on bool) error { return api.SetVulnerabilityAlerts(ctx, owner, repo, on) },
+		},
+		{
+			name:    "automated_security_fixes",
+			desired: cfg.AutomatedSecurityFixes,
+			get: func() (bool, error) {
+				// not found when the vulnerability alerts are disabled
+				on, err := api.GetAutomatedSecurityFixes(ctx, owner, repo)
+				if isNotFound(err) {
+					return false, nil
+				}
+				return on, err
+			},
+			set: func(on bool) error { return api.SetAutomatedSecurityFixes(ctx, owner, repo, on) },
+		},
+		{
+			name:    "secret_scanning",
+			desired: cfg.SecretScanning,
+			get: func() (bool, error) {
+				a, err := getAnalysis()
+				return a.GetSecretScanning().GetStatus() == "enabled", err
+			},
+			set: func(on bool) error {
+				_, err := edit.EditRepository(ctx, owner, repo, &github.Repository{SecurityAndAnalysis: &github.SecurityAndAnalysis{
+					SecretScanning: &github.SecretScanning{Status: status(on)},
+				}})
+				return err
+			},
+		},
+		{
+			name:    "secret_scanning_push_protection",
+			desired: cfg.SecretScanningPushProtection,
+			get: func() (bool, error) {
+				a, err := getAnalysis()
+				return a.GetSecretScanningPushProtection().GetStatus() == "enabled", err
+			},
+			set: func(on bool) error {
+				_, err := edit.EditRepository(ctx, owner, repo, &github.Repository{SecurityAndAnalysis: &github.SecurityAndAnalysis{
+					SecretScanningPushProtection: &github.SecretScanningPushProtection{Status: status(on)},
+				}})
+				return err
+			},
+		},
+		{
+			name:    "private_vulnerability_reporting",
+			desired: cfg.PrivateVulnerabilityReporting,
+			get:     func() (bool, error) { return api.GetPrivateVulnerabilityReporting(ctx, owner, repo) },
+			set:     func(on bool) error { return api.SetPrivateVulnerabilityReporting(ctx, owner, repo, on) },
+		},
+	}
+}
+
+// Security enables or disables the security features of the repository
+// through their dedicated endpoints, reading their current state first. The
+// features are disabled from the last to the first and enabled from the first
+// to the last, so that the features they depend on are enabled.
+func (r *RepoTemplate) Security(opts *RepoOptions, cfg *SecurityConfig, missing bool) ([]StepResult, error) {
+	var (
+		steps    []StepResult
+		errs     []error
+		features []*securityFeature
+	)
+
+	api, err := extension[SecurityAPI](r.api)
+	if err != nil {
+		return []StepResult{{Name: "security", Action: ActionFailed, Error: err.Error()}}, err
+	}
+	edit, err := extension[RepositoryEditAPI](r.api)
+	if err != nil {
+		return []StepResult{{Name: "security", Action: ActionFailed, Error: err.Error()}}, err
+	}
+
+	r.logger.Debug().Msgf("fetching security features of %s/%s", opts.Owner, opts.Name)
+
+	for _, f := range r.features(api, edit, opts.Owner, opts.Name, cfg) {
+		if f.desired == nil {
+			continue
+		}
+
+		step := StepResult{Name: "security:" + f.name, After: *f.desired}
+
+		// the features of a repository that does not exist yet are all disabled
+		current := false
+		if !missing || !opts.Plan {
+			var err error
+			current, err = f.get()
+			if err != nil {
+				err = fmt.Errorf("failed to fetch %s of %s/%s |→ %w", f.name, opts.Owner, opts.Name, err)
+				steps = append(steps, step.Fail(err))
+				errs = append(errs, err)
+				if !opts.ContinueOnError {
+					return steps, joinErrors(errs)
+				}
+				continue
+			}
+			step.Before = current
+		}
+		step.Action = action(false, current != *f.desired)
+
+		steps = append(steps, step)
+		features = append(features, f)
+	}
+
+	if opts.Plan {
+		return steps, joinErrors(errs)
+	}
+
+	// only the features read successfully are written
+	index := map[string]int{}
+	for i, step := range steps {
+		index[step.Name] = i
+	}
+
+	write := func(f *securityFeature) bool {
+		i := index["security:"+f.name]
+		if steps[i].Action == ActionUnchanged {
+			return true
+		}
+
+		r.logger.Debug().Msgf("setting %s to %t on %s/%s", f.name, *f.desired, opts.Owner, opts.Name)
+
+		if err := f.set(*f.desired); err != nil {
+			err = fmt.Errorf("failed to set %s on %s/%s |→ %w", f.name, opts.Owner, opts.Name, err)
+			steps[i] = steps[i].Fail(err)
+			errs = append(errs, err)
+			return opts.ContinueOnError
+		}
+		return true
+	}
+
+	for i := len(features) - 1; i >= 0; i-- {
+		if !*features[i].desired && !write(features[i]) {
+			return steps, joinErrors(errs)
+		}
+	}
+	for _, f := range features {
+		if *f.desired && !write(f) {
+			return steps, joinErrors(errs)
+		}
+	}
+
+	return steps, joinErrors(errs)
+}
@@ -0,0 +1,94 @@
+package ght
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurity(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	enabled := &SecurityConfig{
+		VulnerabilityAlerts:           github.Bool(true),
+		AutomatedSecurityFixes:        github.Bool(true),
+		SecretScanning:                github.Bool(true),
+		SecretScanningPushProtection:  github.Bool(true),
+		PrivateVulnerabilityReporting: github.Bool(true),
+	}
+	opts := writeTemplate(t, &Config{Security: enabled})
+
+	res := plan(t, srv, rt, opts)
+	assert.Len(t, res.Steps, 6)
+	for _, step := range res.Steps[1:] {
+		assert.Equal(t, ActionUpdated, step.Action, step.Name)
+		assert.Equal(t, false, step.Before, step.Name)
+	}
+
+	// the features are enabled after the ones they depend on
+	opts.Plan = false
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.True(t, res.Changed())
+	assert.Equal(t, []string{
+		"PUT /repos/acme/ght/vulnerability-alerts",
+		"PUT /repos/acme/ght/automated-security-fixes",
+		"PATCH /repos/acme/ght",
+		"PATCH /repos/acme/ght",
+		"PUT /repos/acme/ght/private-vulnerability-reporting",
+	}, srv.Writes())
+	assert.Equal(t, []string{
+		`{"security_and_analysis":{"secret_scanning":{"status":"enabled"}}}`,
+		`{"security_and_analysis":{"secret_scanning_push_protection":{"status":"enabled"}}}`,
+	}, srv.Bodies("PATCH /repos/acme/ght"))
+	assert.Equal(t, map[string]bool{
+		"vulnerability_alerts":            true,
+		"automated_security_fixes":        true,
+		"secret_scanning":                 true,
+		"secret_scanning_push_protection": true,
+		"private_vulnerability_reporting": true,
+	}, srv.Security("acme", "ght"))
+
+	assertNoOp(t, srv, rt, opts)
+
+	// and disabled before them
+	opts = writeTemplate(t, &Config{Security: &SecurityConfig{
+		VulnerabilityAlerts:          github.Bool(false),
+		AutomatedSecurityFixes:       github.Bool(false),
+		SecretScanning:               github.Bool(false),
+		SecretScanningPushProtection: github.Bool(false),
+	}})
+	srv.Reset()
+	res, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Len(t, res.Steps, 5)
+	assert.Equal(t, []string{
+		"PATCH /repos/acme/ght",
+		"PATCH /repos/acme/ght",
+		"DELETE /repos/acme/ght/automated-security-fixes",
+		"DELETE /repos/acme/ght/vulnerability-alerts",
+	}, srv.Writes())
+	assert.Equal(t, []string{
+		`{"security_and_analysis":{"secret_scanning_push_protection":{"status":"disabled"}}}`,
+		`{"security_and_analysis":{"secret_scanning":{"status":"disabled"}}}`,
+	}, srv.Bodies("PATCH /repos/acme/ght"))
+	assert.Equal(t, map[string]bool{
+		"vulnerability_alerts":            false,
+		"automated_security_fixes":        false,
+		"secret_scanning":                 false,
+		"secret_scanning_push_protection": false,
+		"private_vulnerability_reporting": true,
+	}, srv.Security("acme", "ght"))
+}
+
+func TestSecurityFailsOnDependency(t *testing.T) {
+	_, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{Security: &SecurityConfig{SecretScanningPushProtection: github.Bool(true)}})
+	res, err := Run(rt, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, ActionFailed, res.Steps[1].Action)
+	assert.Contains(t, res.Steps[1].Error, "secret_scanning_push_protection")
+}
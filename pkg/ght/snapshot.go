@@ -0,0 +1,412 @@
+package ght
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// SnapshotVersion is the version of the snapshot format
+const SnapshotVersion = 1
+
+// Snapshot records the settings a run is about to change, so that they can be restored
+type Snapshot struct {
+	Version int       `json:"version"`
+	Owner   string    `json:"owner"`
+	Name    string    `json:"name"`
+	TakenAt time.Time `json:"taken_at"`
+	// Exists is false when the repository was created by the run
+	Exists bool `json:"exists"`
+	// Repository has only the fields set by the template
+	Repository *github.Repository `json:"repository,omitempty"`
+	// Topics is nil when the run does not replace the topics
+	Topics   []string         `json:"topics"`
+	Files    []FileSnapshot   `json:"files,omitempty"`
+	Branches []BranchSnapshot `json:"branches,omitempty"`
+	// Uncovered are the sections of the template the snapshot does not record, rollback can not restore them
+	Uncovered []string `json:"uncovered,omitempty"`
+}
+
+// FileSnapshot is the state of a file managed by the template
+type FileSnapshot struct {
+	Path    string `json:"path"`
+	Exists  bool   `json:"exists"`
+	SHA     string `json:"sha,omitempty"`
+	Content []byte `json:"content,omitempty"`
+}
+
+// BranchSnapshot is the protection of a branch, nil when the branch is not protected
+type BranchSnapshot struct {
+	Name          string                    `json:"name"`
+	Exists        bool                      `json:"exists"`
+	Protection    *github.ProtectionRequest `json:"protection"`
+	SignedCommits bool                      `json:"signed_commits"`
+}
+
+// Snapshot captures the current state of the settings the run may change
+func (r *RepoTemplate) Snapshot(opts *RepoOptions, cfg *Config, current *github.Repository) (*Snapshot, error) {
+	snap := &Snapshot{
+		Version: SnapshotVersion,
+		Owner:   opts.Owner,
+		Name:    opts.Name,
+		TakenAt: time.Now().UTC(),
+		Exists:  current != nil,
+	}
+	snap.Uncovered = uncoveredSections(cfg)
+
+	// a repository that does not exist has nothing to restore
+	if current == nil {
+		return snap, nil
+	}
+
+	if cfg.Repository != nil {
+		fields, err := repositoryFields(cfg.Repository, current)
+		if err != nil {
+			return nil, err
+		}
+		snap.Repository = fields
+	}
+
+	if len(opts.Topics) > 0 {
+		snap.Topics = append([]string{}, current.Topics...)
+	}
+
+	for _, path := range managedFiles(cfg) {
+		content, err := r.GetContent(opts.Owner, opts.Name, path)
+		if err != nil {
+			return nil, err
+		}
+
+		file := FileSnapshot{Path: path, Exists: content != nil}
+		if content != nil {
+			data, err := content.GetContent()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode file %s |→ %w", path, err)
+			}
+			file.SHA = content.GetSHA()
+			file.Content = []byte(data)
+		}
+		snap.Files = append(snap.Files, file)
+	}
+
+	if cfg.BranchProtection != nil {
+		for _, name := range opts.Branches {
+			branch := BranchSnapshot{Name: name}
+			if _, err := r.GetBranch(opts.Owner, opts.Name, name); err != nil {
+				if !isNotFound(err) {
+					return nil, err
+				}
+				snap.Branches = append(snap.Branches, branch)
+				continue
+			}
+			branch.Exists = true
+
+			protection, err := r.GetBranchProtection(opts.Owner, opts.Name, name)
+			if err != nil {
+				return nil, err
+			}
+			branch.Protection = protection
+
+			signed, err := r.GetBranchCommitSignProtection(opts.Owner, opts.Name, name)
+			if err != nil {
+				return nil, err
+			}
+			branch.SignedCommits = signed
+
+			snap.Branches = append(snap.Branches, branch)
+		}
+	}
+
+	return snap, nil
+}
+
+// managedFiles returns the repository paths of the files set by the template
+func managedFiles(cfg *Config) []string {
+	var paths []string
+	if cfg.PullRequestTemplate != "" {
+		paths = append(paths, PullRequestTemplate)
+	}
+	if cfg.IssueTemplate != "" {
+		paths = append(paths, IssueTemplate)
+	}
+
+	return paths
+}
+
+// uncoveredSections returns the sections of the template whose settings the snapshot does not record
+func uncoveredSections(cfg *Config) []string {
+	var sections []string
+	add := func(set bool, name string) {
+		if set {
+			sections = append(sections, name)
+		}
+	}
+	add(cfg.DefaultBranch != "" || len(cfg.Branches) > 0, "branches")
+	add(len(cfg.MergeQueue) > 0, "merge_queue")
+	add(cfg.TagProtection != nil, "tag_protection")
+	add(cfg.Releases != nil, "releases")
+	add(len(cfg.Webhooks) > 0 || cfg.RemoveUnmanagedWebhooks, "webhooks")
+	add(len(cfg.DeployKeys) > 0 || cfg.RemoveUnmanagedDeployKeys, "deploy_keys")
+	add(len(cfg.Autolinks) > 0, "autolinks")
+	add(cfg.Pages != nil, "pages")
+	add(cfg.Actions != nil, "actions")
+	add(len(cfg.Environments) > 0, "environments")
+	add(cfg.Security != nil, "security")
+	add(cfg.CodeScanning != nil, "code_scanning")
+
+	return sections
+}
+
+// covered reports whether the snapshot records the setting changed by the step
+func covered(step StepResult) bool {
+	switch step.Name {
+	case "repository", "topics", "required_status_checks", PullRequestTemplate, IssueTemplate:
+		return true
+	}
+
+	return strings.HasPrefix(step.Name, "branch_protection") || strings.HasPrefix(step.Name, "required_signed_commits:")
+}
+
+// warnUncovered warns on the steps that change a setting the snapshot does not record
+func warnUncovered(res *RepoResponse) {
+	for i, step := range res.Steps {
+		if step.Action.changes() && !covered(step) && step.Warning == "" {
+			res.Steps[i].Warning = "not recorded by the snapshot, ght rollback can not restore it"
+		}
+	}
+}
+
+// repositoryFields returns the fields of the current repository that the template sets
+func repositoryFields(desired, current *github.Repository) (*github.Repository, error) {
+	var keys, values map[string]json.RawMessage
+	if err := remarshal(desired, &keys); err != nil {
+		return nil, err
+	}
+	if err := remarshal(current, &values); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	for k := range keys {
+		if v, ok := values[k]; ok {
+			fields[k] = v
+		}
+	}
+
+	repo := &github.Repository{}
+	if err := remarshal(fields, repo); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// remarshal converts the value through its JSON encoding
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, to)
+}
+
+// ReadSnapshot reads a snapshot from disk
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s |→ %w", path, err)
+	}
+
+	snap := &Snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot %s |→ %w", path, err)
+	}
+
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s", snap.Version, path)
+	}
+
+	return snap, nil
+}
+
+// Write writes the snapshot to disk, readable only by the user since it may hold private files
+func (s *Snapshot) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot |→ %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot %s |→ %w", path, err)
+	}
+
+	return nil
+}
+
+// Rollback restores the settings recorded in the snapshot, in the reverse order
+// they are applied by Run. Every setting is restored even when some fail, the
+// failures are returned together. In plan mode (opts.Plan) nothing is written.
+// A repository created by the run is never deleted, its step is skipped, and so
+// are the sections the snapshot does not record, with a warning.
+func Rollback(rt *RepoTemplate, snap *Snapshot, opts *RepoOptions) (*RepoResponse, error) {
+	res := &RepoResponse{
+		Fullname: fmt.Sprintf("%s/%s", snap.Owner, snap.Name),
+	}
+
+	if !snap.Exists {
+		_ = res.add(StepResult{Name: "repository", Action: ActionSkipped}, nil)
+		return res, nil
+	}
+
+	current, err := rt.GetRepo(snap.Owner, snap.Name)
+	if err != nil {
+		return res, res.add(StepResult{Name: "repository"}, err)
+	}
+
+	var errs []error
+	collect := func(step StepResult, err error) {
+		if err := res.add(step, err); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, section := range snap.Uncovered {
+		_ = res.add(StepResult{Name: section, Action: ActionSkipped, Warning: "not recorded by the snapshot, restore it by hand"}, nil)
+	}
+
+	for i := len(snap.Branches) - 1; i >= 0; i-- {
+		branch := snap.Branches[i]
+		if !branch.Exists {
+			continue
+		}
+		// removing the protection also removes the signed commits protection,
+		// which can only be restored once the branch is protected again
+		collect(rt.restoreBranchProtection(snap, branch, opts.Plan))
+		if branch.Protection != nil {
+			collect(rt.restoreSignedCommits(snap, branch, opts.Plan))
+		}
+	}
+
+	for i := len(snap.Files) - 1; i >= 0; i-- {
+		collect(rt.restoreFile(snap, snap.Files[i], opts.Plan))
+	}
+
+	if snap.Topics != nil {
+		step := StepResult{Name: "topics", Before: current.Topics, After: snap.Topics}
+		step.Action = action(false, changed(snap.Topics, current.Topics))
+
+		var err error
+		if step.Action != ActionUnchanged && !opts.Plan {
+			err = rt.ReplaceTopics(snap.Owner, snap.Name, snap.Topics)
+		}
+		collect(step, err)
+	}
+
+	if snap.Repository != nil {
+		step := StepResult{Name: "repository", Before: current, After: snap.Repository}
+		step.Action = action(false, changed(snap.Repository, current))
+
+		var err error
+		if step.Action != ActionUnchanged && !opts.Plan {
+			err = rt.EditRepo(snap.Owner, snap.Name, snap.Repository)
+		}
+		collect(step, err)
+	}
+
+	return res, joinErrors(errs)
+}
+
+// restoreSignedCommits restores the signed commits protection of a branch
+func (r *RepoTemplate) restoreSignedCommits(snap *Snapshot, branch BranchSnapshot, plan bool) (StepResult, error) {
+	step := StepResult{Name: "required_signed_commits:" + branch.Name, After: branch.SignedCommits}
+
+	enabled, err := r.GetBranchCommitSignProtection(snap.Owner, snap.Name, branch.Name)
+	if err != nil {
+		return step, err
+	}
+	step.Before = enabled
+	step.Action = action(false, enabled != branch.SignedCommits)
+
+	switch {
+	case step.Action == ActionUnchanged || plan:
+		return step, nil
+	case branch.SignedCommits:
+		return step, r.CreateBranchCommitSignProtection(snap.Owner, snap.Name, branch.Name)
+	default:
+		return step, r.DeleteBranchCommitSignProtection(snap.Owner, snap.Name, branch.Name)
+	}
+}
+
+// restoreBranchProtection restores the protection rules of a branch, removing them when it was not protected
+func (r *RepoTemplate) restoreBranchProtection(snap *Snapshot, branch BranchSnapshot, plan bool) (StepResult, error) {
+	step := StepResult{Name: "branch_protection:" + branch.Name, After: branch.Protection}
+
+	current, err := r.GetBranchProtection(snap.Owner, snap.Name, branch.Name)
+	if err != nil {
+		return step, err
+	}
+	step.Before = current
+
+	switch {
+	case branch.Protection == nil && current == nil:
+		step.Action = ActionUnchanged
+	case branch.Protection == nil:
+		step.Action = ActionUpdated
+		if !plan {
+			return step, r.DeleteBranchProtection(snap.Owner, snap.Name, branch.Name)
+		}
+	default:
+		step.Action = action(current == nil, changed(branch.Protection, current))
+		if step.Action != ActionUnchanged && !plan {
+			_, err := r.api.UpdateBranchProtection(context.Background(), snap.Owner, snap.Name, branch.Name, branch.Protection)
+			if err != nil {
+				return step, fmt.Errorf("failed to set branch protection rules on %s |→ %w", branch.Name, err)
+			}
+		}
+	}
+
+	return step, nil
+}
+
+// restoreFile restores the content of a file, deleting it when it did not exist
+func (r *RepoTemplate) restoreFile(snap *Snapshot, file FileSnapshot, plan bool) (StepResult, error) {
+	step := StepResult{Name: file.Path}
+	if file.Exists {
+		step.After = file.SHA
+	}
+
+	current, err := r.GetContent(snap.Owner, snap.Name, file.Path)
+	if err != nil {
+		return step, err
+	}
+	if current != nil {
+		step.Before = current.GetSHA()
+	}
+
+	switch {
+	case !file.Exists && current == nil:
+		step.Action = ActionUnchanged
+	case !file.Exists:
+		step.Action = ActionUpdated
+		if !plan {
+			return step, r.DeleteContent(snap.Owner, snap.Name, file.Path, current.GetSHA())
+		}
+	default:
+		step.Action = action(current == nil, current.GetSHA() != file.SHA)
+		if step.Action != ActionUnchanged && !plan {
+			var sha *string
+			if current != nil {
+				sha = current.SHA
+			}
+			return step, r.CreateUpdateContent(snap.Owner, snap.Name, file.Path, file.Content, sha)
+		}
+	}
+
+	return step, nil
+}
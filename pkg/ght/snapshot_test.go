@@ -0,0 +1,203 @@
+package ght
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotAndRollback(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght"), HasWiki: github.Bool(true), Topics: []string{"old"}})
+	srv.AddFile("acme", "ght", PullRequestTemplate, []byte("old template"))
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	opts := e2eOptions()
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, srv.Protection("acme", "ght", "main"))
+
+	// someone changes a setting by hand after the run
+	_, _, err = srv.GitHubClient().Repositories.Edit(context.Background(), "acme", "ght", &github.Repository{HasWiki: github.Bool(false)})
+	assert.Nil(t, err)
+
+	snap, err := ReadSnapshot(opts.Snapshot)
+	assert.Nil(t, err)
+	assert.True(t, snap.Exists)
+	assert.Equal(t, []string{"old"}, snap.Topics)
+	assert.Len(t, snap.Files, 2)
+	assert.Len(t, snap.Branches, 1)
+	assert.Nil(t, snap.Branches[0].Protection)
+
+	res, err := Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+	assert.True(t, res.Changed())
+
+	assert.Nil(t, srv.Protection("acme", "ght", "main"))
+	content, _ := srv.Content("acme", "ght", PullRequestTemplate)
+	assert.Equal(t, "old template", string(content))
+	_, ok := srv.Content("acme", "ght", IssueTemplate)
+	assert.False(t, ok)
+	repo := srv.Repository("acme", "ght")
+	assert.Equal(t, []string{"old"}, repo.Topics)
+	assert.True(t, repo.GetHasWiki())
+
+	res, err = Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+	assert.False(t, res.Changed())
+}
+
+func TestRollbackRestoresProtection(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+
+	client := srv.GitHubClient()
+	_, _, err := client.Repositories.UpdateBranchProtection(context.Background(), "acme", "ght", "main", &github.ProtectionRequest{EnforceAdmins: false})
+	assert.Nil(t, err)
+
+	rt, err := NewRepoTemplate(WithClient(client))
+	assert.Nil(t, err)
+
+	opts := e2eOptions()
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+	assert.True(t, srv.Protection("acme", "ght", "main").EnforceAdmins.Enabled)
+
+	snap, err := ReadSnapshot(opts.Snapshot)
+	assert.Nil(t, err)
+
+	srv.Reset()
+	res, err := Rollback(rt, snap, &RepoOptions{Plan: true})
+	assert.Nil(t, err)
+	assert.True(t, res.Changed())
+	assert.Empty(t, srv.Writes())
+
+	_, err = Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+	assert.False(t, srv.Protection("acme", "ght", "main").EnforceAdmins.Enabled)
+
+	signed, err := rt.GetBranchCommitSignProtection("acme", "ght", "main")
+	assert.Nil(t, err)
+	assert.False(t, signed)
+}
+
+func TestSnapshotCreatedRepo(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	opts := e2eOptions()
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+
+	snap, err := ReadSnapshot(opts.Snapshot)
+	assert.Nil(t, err)
+	assert.False(t, snap.Exists)
+
+	res, err := Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, ActionSkipped, res.Steps[0].Action)
+	assert.NotNil(t, srv.Repository("acme", "ght"))
+}
+
+func TestSnapshotWarnsUncoveredSections(t *testing.T) {
+	_, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{
+		Repository: &github.Repository{HasWiki: github.Bool(false)},
+		Webhooks:   []*Webhook{{URL: "https://chat.acme.io/hook"}},
+	})
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "webhooks:https://chat.acme.io/hook", res.Steps[1].Name)
+	assert.Equal(t, "not recorded by the snapshot, ght rollback can not restore it", res.Steps[1].Warning)
+
+	snap, err := ReadSnapshot(opts.Snapshot)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"webhooks"}, snap.Uncovered)
+
+	res, err = Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, StepResult{Name: "webhooks", Action: ActionSkipped, Warning: "not recorded by the snapshot, restore it by hand"}, res.Steps[0])
+}
+
+func TestSnapshotNotWrittenInPlanMode(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	opts := e2eOptions()
+	opts.Plan = true
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+
+	_, err = os.Stat(opts.Snapshot)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadSnapshotUnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"version": 99}`), 0o600))
+
+	_, err := ReadSnapshot(path)
+	assert.NotNil(t, err)
+}
+
+func TestRollbackRestoresInReverseOrder(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght"), HasWiki: github.Bool(true), Topics: []string{"old"}})
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	opts := e2eOptions()
+	opts.Snapshot = filepath.Join(t.TempDir(), "snapshot.json")
+	_, err = Run(rt, opts)
+	assert.Nil(t, err)
+
+	snap, err := ReadSnapshot(opts.Snapshot)
+	assert.Nil(t, err)
+
+	// someone changes a setting by hand after the run
+	_, _, err = srv.GitHubClient().Repositories.Edit(context.Background(), "acme", "ght", &github.Repository{HasWiki: github.Bool(false)})
+	assert.Nil(t, err)
+
+	srv.Reset()
+	_, err = Rollback(rt, snap, &RepoOptions{})
+	assert.Nil(t, err)
+
+	// the protection goes first and the repository fields last, as Run applies them the other way round
+	assert.Equal(t, []string{
+		"DELETE /repos/acme/ght/branches/main/protection",
+		"DELETE /repos/acme/ght/contents/.github/issue_template.md",
+		"DELETE /repos/acme/ght/contents/.github/pull_request_template.md",
+		"PUT /repos/acme/ght/topics",
+		"PATCH /repos/acme/ght",
+	}, srv.Writes())
+	assert.Equal(t, []string{`{"names":["old"]}`}, srv.Bodies("PUT /repos/acme/ght/topics"))
+	assert.Equal(t, []string{`{"has_wiki":true}`}, srv.Bodies("PATCH /repos/acme/ght"))
+}
@@ -0,0 +1,244 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// defaultStatusCheckCommits is the number of recent commits whose checks are read
+const defaultStatusCheckCommits = 10
+
+// StatusChecks resolves the required status checks of the branch protection
+// from the checks reported on the recent commits of the default branch
+type StatusChecks struct {
+	// Auto requires every check seen when required_status_checks declares none
+	Auto bool `json:"auto,omitempty"`
+	// PinAppID requires each check from the app that reported it, unless its app_id is set
+	PinAppID bool `json:"pin_app_id,omitempty"`
+	// Verify warns about the required checks not reported, as Auto and PinAppID do
+	Verify bool `json:"verify,omitempty"`
+	// Commits is the number of recent commits whose checks are read, 10 when empty, at most 100
+	Commits int `json:"commits,omitempty"`
+}
+
+// validateStatusChecks checks the names and the apps of the required status
+// checks, a check that is never reported blocks every merge
+func validateStatusChecks(protection *github.ProtectionRequest) error {
+	if protection == nil || protection.RequiredStatusChecks == nil {
+		return nil
+	}
+	rsc := protection.RequiredStatusChecks
+	if len(rsc.Checks) > 0 && len(rsc.Contexts) > 0 {
+		return fmt.Errorf("required_status_checks must set either checks or contexts, GitHub ignores the contexts when both are set")
+	}
+
+	names := append([]string{}, rsc.Contexts...)
+	for _, c := range rsc.Checks {
+		if c == nil {
+			return fmt.Errorf("required_status_checks checks must not be null")
+		}
+		if id := c.GetAppID(); c.AppID != nil && id != -1 && id < 1 {
+			return fmt.Errorf("required_status_checks check %s app_id must be -1, any app, or the id of an app", c.Context)
+		}
+		names = append(names, c.Context)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		switch {
+		case name == "":
+			return fmt.Errorf("required_status_checks check name is required")
+		case name != strings.TrimSpace(name):
+			return fmt.Errorf("required_status_checks check %q has leading or trailing spaces", name)
+		case seen[name]:
+			return fmt.Errorf("required_status_checks check %s is duplicated", name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
+
+// RecentChecks fetches the names of the check runs and of the commit statuses
+// reported on the recent commits of a branch, with the id of the app that last
+// reported them, 0 for the commit statuses.
+//
+// GitHub API docs: https://docs.github.com/en/rest/checks/runs#list-check-runs-for-a-git-reference
+func (r *RepoTemplate) RecentChecks(owner, repo, branch string, commits int) (map[string]int64, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching checks of the last %d commits of %s on %s/%s", commits, branch, owner, repo)
+
+	api, err := extension[ChecksAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := api.ListCommits(ctx, owner, repo, branch, commits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits of %s on %s/%s |→ %w", branch, owner, repo, err)
+	}
+
+	// the commits are listed from the most recent one
+	checks := map[string]int64{}
+	for _, c := range list {
+		runs, err := api.ListCheckRunsForRef(ctx, owner, repo, c.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list check runs of %s on %s/%s |→ %w", c.GetSHA(), owner, repo, err)
+		}
+		for _, run := range runs {
+			if _, ok := checks[run.GetName()]; !ok {
+				checks[run.GetName()] = run.GetApp().GetID()
+			}
+		}
+
+		statuses, err := api.ListStatuses(ctx, owner, repo, c.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statuses of %s on %s/%s |→ %w", c.GetSHA(), owner, repo, err)
+		}
+		for _, status := range statuses {
+			if _, ok := checks[status.GetContext()]; !ok {
+				checks[status.GetContext()] = 0
+			}
+		}
+	}
+
+	return checks, nil
+}
+
+// lookup reports whether the checks reported must be read: to verify them, to
+// pick them in auto mode or to pin the apps of the checks without an app_id
+func (c *StatusChecks) lookup(rsc *github.RequiredStatusChecks) bool {
+	if c.Verify || (c.Auto && len(rsc.Checks) == 0 && len(rsc.Contexts) == 0) {
+		return true
+	}
+	if c.PinAppID {
+		for _, check := range rsc.Checks {
+			if check.AppID == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// StatusChecks verifies the required status checks of the protection against
+// the checks reported on the recent commits of the default branch, warning
+// about the ones never seen. In auto mode, the checks seen are required when
+// none is declared. The protection is returned with the resolved checks. The
+// checks are only read when there is something to verify, pick or pin.
+func (r *RepoTemplate) StatusChecks(opts *RepoOptions, protection *github.ProtectionRequest, cfg *StatusChecks) (*github.ProtectionRequest, StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+	step := StepResult{Name: "required_status_checks", Action: ActionSkipped}
+
+	if protection == nil || protection.RequiredStatusChecks == nil {
+		step.Warning = "status_checks has no effect without the required_status_checks of the branch_protection"
+		r.logger.Warn().Msgf("required status checks of %s/%s: %s", owner, repo, step.Warning)
+		return protection, step, nil
+	}
+	if !cfg.Auto && !cfg.PinAppID && !cfg.Verify {
+		step.Warning = "status_checks has no effect without auto, pin_app_id or verify"
+		r.logger.Warn().Msgf("required status checks of %s/%s: %s", owner, repo, step.Warning)
+		return protection, step, nil
+	}
+	if err := validateStatusChecks(protection); err != nil {
+		return protection, step, err
+	}
+	commits := cfg.Commits
+	if commits == 0 {
+		commits = defaultStatusCheckCommits
+	}
+	if commits < 1 || commits > 100 {
+		return protection, step, fmt.Errorf("status_checks commits must be between 1 and 100")
+	}
+
+	if !cfg.lookup(protection.RequiredStatusChecks) {
+		return protection, step, nil
+	}
+	step.Action = ActionUnchanged
+
+	res, err := r.GetRepo(owner, repo)
+	if err != nil {
+		return protection, step, err
+	}
+	branch := res.GetDefaultBranch()
+
+	seen, err := r.RecentChecks(owner, repo, branch, commits)
+	if err != nil {
+		return protection, step, err
+	}
+
+	// the template is left as it is
+	rsc := *protection.RequiredStatusChecks
+	rsc.Checks = nil
+	for _, c := range protection.RequiredStatusChecks.Checks {
+		check := *c
+		rsc.Checks = append(rsc.Checks, &check)
+	}
+	step.Before = rsc.Checks
+	if len(rsc.Contexts) > 0 {
+		step.Before = rsc.Contexts
+	}
+
+	var warnings []string
+	if len(seen) == 0 {
+		warnings = append(warnings, fmt.Sprintf("no check was reported on the last %d commits of %s", commits, branch))
+	}
+
+	if cfg.Auto && len(rsc.Checks) == 0 && len(rsc.Contexts) == 0 {
+		for name := range seen {
+			rsc.Checks = append(rsc.Checks, &github.RequiredStatusCheck{Context: name})
+		}
+		sort.Slice(rsc.Checks, func(i, j int) bool { return rsc.Checks[i].Context < rsc.Checks[j].Context })
+	}
+
+	for _, name := range rsc.Contexts {
+		if _, ok := seen[name]; !ok && len(seen) > 0 {
+			warnings = append(warnings, unseenCheck(name, branch, commits, seen))
+		}
+	}
+	for _, c := range rsc.Checks {
+		app, ok := seen[c.Context]
+		switch {
+		case !ok && len(seen) > 0:
+			warnings = append(warnings, unseenCheck(c.Context, branch, commits, seen))
+		case !ok || app == 0:
+		case c.AppID != nil && *c.AppID != -1 && *c.AppID != app:
+			warnings = append(warnings, fmt.Sprintf("check %s is reported by app %d, not by app_id %d", c.Context, app, *c.AppID))
+		case c.AppID == nil && cfg.PinAppID:
+			c.AppID = github.Int64(app)
+		}
+	}
+
+	step.After = rsc.Checks
+	if len(rsc.Contexts) > 0 {
+		step.After = rsc.Contexts
+	}
+	for _, w := range warnings {
+		r.logger.Warn().Msgf("required status checks of %s/%s: %s", owner, repo, w)
+	}
+	step.Warning = strings.Join(warnings, "\n")
+
+	resolved := *protection
+	resolved.RequiredStatusChecks = &rsc
+
+	return &resolved, step, nil
+}
+
+// unseenCheck returns the warning about a required check that was not seen,
+// suggesting a check whose name differs only by its case
+func unseenCheck(name, branch string, commits int, seen map[string]int64) string {
+	msg := fmt.Sprintf("check %s was not reported on the last %d commits of %s, it would block every merge", name, commits, branch)
+	for other := range seen {
+		if strings.EqualFold(other, name) {
+			return msg + ", did you mean " + other + "?"
+		}
+	}
+
+	return msg
+}
@@ -0,0 +1,166 @@
+package ght
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusChecks(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddCheckRun("acme", "ght", "build", 15368)
+	srv.Commit("acme", "ght")
+	srv.AddCheckRun("acme", "ght", "lint", 15368)
+	srv.AddStatus("acme", "ght", "ci/jenkins")
+
+	opts := writeTemplate(t, &Config{
+		BranchProtection: &github.ProtectionRequest{RequiredStatusChecks: &github.RequiredStatusChecks{
+			Strict: true,
+			Checks: []*github.RequiredStatusCheck{{Context: "build"}, {Context: "Lint"}, {Context: "ci/jenkins"}},
+		}},
+		StatusChecks: &StatusChecks{PinAppID: true},
+	})
+	opts.Branches = []string{"main"}
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+
+	// the reported checks are read before the protection is written, with the app ids pinned
+	requests := strings.Join(srv.Requests(), "\n")
+	assert.Less(t, strings.Index(requests, "/check-runs"), strings.Index(requests, "PUT /repos/acme/ght/branches/main/protection"))
+	assert.Contains(t, srv.Bodies("PUT /repos/acme/ght/branches/main/protection")[0],
+		`"required_status_checks":{"strict":true,"checks":[{"context":"build","app_id":15368},{"context":"Lint"},{"context":"ci/jenkins"}]}`)
+	step := res.Steps[1]
+	assert.Equal(t, "required_status_checks", step.Name)
+	assert.Equal(t, ActionUnchanged, step.Action)
+	assert.Equal(t, "check Lint was not reported on the last 10 commits of main, it would block every merge, did you mean lint?", step.Warning)
+	assert.Equal(t, []*github.RequiredStatusCheck{
+		{Context: "build", AppID: github.Int64(15368)},
+		{Context: "Lint"},
+		{Context: "ci/jenkins"},
+	}, step.After)
+
+	// the statuses have no app to pin
+	checks := srv.Protection("acme", "ght", "main").RequiredStatusChecks.Checks
+	assert.Equal(t, int64(15368), checks[0].GetAppID())
+	assert.Nil(t, checks[2].AppID)
+
+	out := &bytes.Buffer{}
+	WriteAnnotations(out, res, nil, opts, nil)
+	assert.Contains(t, out.String(), "::warning title=ght::required_status_checks: check Lint was not reported")
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestStatusChecksAuto(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+	srv.AddCheckRun("acme", "ght", "build", 15368)
+	srv.Commit("acme", "ght")
+	srv.AddCheckRun("acme", "ght", "lint", 15368)
+
+	// only the checks of the last commit are read
+	opts := writeTemplate(t, &Config{
+		BranchProtection: &github.ProtectionRequest{RequiredStatusChecks: &github.RequiredStatusChecks{Strict: true}},
+		StatusChecks:     &StatusChecks{Auto: true, Commits: 1},
+	})
+	opts.Branches = []string{"main"}
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Empty(t, res.Steps[1].Warning)
+	assert.Equal(t, []*github.RequiredStatusCheck{{Context: "lint"}}, res.Steps[1].After)
+	assert.Equal(t, ActionCreated, res.Steps[2].Action)
+
+	checks := srv.Protection("acme", "ght", "main").RequiredStatusChecks.Checks
+	assert.Equal(t, []*github.RequiredStatusCheck{{Context: "lint"}}, checks)
+}
+
+func TestStatusChecksNoneReported(t *testing.T) {
+	_, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{
+		BranchProtection: &github.ProtectionRequest{RequiredStatusChecks: &github.RequiredStatusChecks{
+			Checks: []*github.RequiredStatusCheck{{Context: "build"}},
+		}},
+		StatusChecks: &StatusChecks{Verify: true},
+	})
+	opts.Branches = []string{"main"}
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "no check was reported on the last 10 commits of main", res.Steps[1].Warning)
+}
+
+func TestStatusChecksSkipped(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	// without the required status checks, such as with only a merge queue
+	opts := writeTemplate(t, &Config{
+		BranchProtection: &github.ProtectionRequest{},
+		StatusChecks:     &StatusChecks{Auto: true},
+		MergeQueue:       map[string]*MergeQueue{"main": {}},
+	})
+	opts.Branches = []string{"main"}
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionSkipped, res.Steps[1].Action)
+	assert.Equal(t, "status_checks has no effect without the required_status_checks of the branch_protection", res.Steps[1].Warning)
+	assert.NotNil(t, srv.Protection("acme", "ght", "main"))
+
+	// nothing to verify, pick or pin
+	checks := &github.RequiredStatusChecks{Checks: []*github.RequiredStatusCheck{{Context: "build", AppID: github.Int64(15368)}}}
+	for sc, warning := range map[*StatusChecks]string{
+		{}:               "status_checks has no effect without auto, pin_app_id or verify",
+		{PinAppID: true}: "",
+		{Auto: true}:     "",
+	} {
+		opts = writeTemplate(t, &Config{BranchProtection: &github.ProtectionRequest{RequiredStatusChecks: checks}, StatusChecks: sc})
+		opts.Branches = []string{"main"}
+
+		srv.Reset()
+		res, err = Run(rt, opts)
+		assert.Nil(t, err)
+		assert.Equal(t, ActionSkipped, res.Steps[1].Action)
+		assert.Equal(t, warning, res.Steps[1].Warning)
+		assert.NotContains(t, srv.Requests(), "GET /repos/acme/ght/commits")
+	}
+}
+
+func TestValidateStatusChecks(t *testing.T) {
+	protection := func(contexts []string, checks ...*github.RequiredStatusCheck) *github.ProtectionRequest {
+		return &github.ProtectionRequest{RequiredStatusChecks: &github.RequiredStatusChecks{Contexts: contexts, Checks: checks}}
+	}
+
+	tests := []struct {
+		name       string
+		protection *github.ProtectionRequest
+		err        string
+	}{
+		{"no protection", nil, ""},
+		{"no status checks", &github.ProtectionRequest{}, ""},
+		{"checks", protection(nil, &github.RequiredStatusCheck{Context: "build"}, &github.RequiredStatusCheck{Context: "lint", AppID: github.Int64(-1)}), ""},
+		{"contexts", protection([]string{"build"}), ""},
+		{"both", protection([]string{"build"}, &github.RequiredStatusCheck{Context: "lint"}), "either checks or contexts"},
+		{"empty name", protection(nil, &github.RequiredStatusCheck{}), "check name is required"},
+		{"spaces", protection([]string{"build "}), `check "build " has leading or trailing spaces`},
+		{"duplicated", protection(nil, &github.RequiredStatusCheck{Context: "build"}, &github.RequiredStatusCheck{Context: "build"}), "check build is duplicated"},
+		{"app", protection(nil, &github.RequiredStatusCheck{Context: "build", AppID: github.Int64(0)}), "check build app_id must be -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStatusChecks(tt.protection)
+			if tt.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
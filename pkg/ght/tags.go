@@ -0,0 +1,264 @@
+package ght
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// TagRulesetName is the name of the tag ruleset managed by ght
+const TagRulesetName = "ght tag protection"
+
+// tagRules forbid deleting and moving the protected tags
+var tagRules = []string{"deletion", "non_fast_forward", "update"}
+
+// TagProtection protects the tags matching the patterns, such as v*, from
+// being deleted or moved
+type TagProtection struct {
+	Patterns []string `json:"patterns"`
+}
+
+// ReleaseSettings are the settings of the releases of the repository
+type ReleaseSettings struct {
+	// Immutable forbids changing the tag and the assets of the published releases
+	Immutable *bool `json:"immutable,omitempty"`
+}
+
+// validate checks the patterns
+func (t *TagProtection) validate() error {
+	if len(t.Patterns) == 0 {
+		return fmt.Errorf("tag_protection patterns is required")
+	}
+	for _, p := range t.Patterns {
+		if strings.TrimPrefix(p, "refs/tags/") == "" {
+			return fmt.Errorf("tag_protection pattern %q is empty", p)
+		}
+	}
+
+	return nil
+}
+
+// ruleset returns the tag ruleset protecting the patterns
+func (t *TagProtection) ruleset() *Ruleset {
+	rs := &Ruleset{
+		Name:        TagRulesetName,
+		Target:      "tag",
+		Enforcement: "active",
+		Conditions:  &RulesetConditions{RefName: &RulesetRefName{Include: []string{}, Exclude: []string{}}},
+	}
+	for _, p := range t.Patterns {
+		rs.Conditions.RefName.Include = append(rs.Conditions.RefName.Include, "refs/tags/"+strings.TrimPrefix(p, "refs/tags/"))
+	}
+	for _, typ := range tagRules {
+		rs.Rules = append(rs.Rules, &RulesetRule{Type: typ})
+	}
+	rs.sortRules()
+
+	return rs
+}
+
+// patterns returns the patterns sorted, without the refs/tags/ prefix the legacy tag protection does not use
+func (t *TagProtection) patterns() []string {
+	var patterns []string
+	for _, p := range t.Patterns {
+		patterns = append(patterns, strings.TrimPrefix(p, "refs/tags/"))
+	}
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+// ListTagProtection fetches the legacy tag protection patterns of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/tags#list-tag-protection-states-for-a-repository
+func (r *RepoTemplate) ListTagProtection(owner, repo string) ([]*github.TagProtection, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching tag protection of %s/%s", owner, repo)
+
+	api, err := extension[TagProtectionAPI](r.api)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.ListTagProtection(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag protection of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return res, nil
+}
+
+// TagProtection protects the tags of the template using a tag ruleset, or
+// the legacy tag protection when the rulesets are not available on the
+// repository. The ruleset forbids deleting and moving the tags, the legacy
+// tag protection restricts them to the maintainers and the admins.
+func (r *RepoTemplate) TagProtection(opts *RepoOptions, t *TagProtection, missing bool) ([]StepResult, error) {
+	owner, repo := opts.Owner, opts.Name
+
+	if err := t.validate(); err != nil {
+		return []StepResult{{Name: "tag_protection", Action: ActionFailed, Error: err.Error()}}, err
+	}
+
+	desired := t.ruleset()
+	step := StepResult{Name: "tag_protection:ruleset", After: desired, Action: ActionCreated}
+
+	// the rulesets of a repository that does not exist yet can not be read
+	if missing && opts.Plan {
+		return []StepResult{step}, nil
+	}
+
+	current, err := r.GetRulesetByName(owner, repo, "tag", TagRulesetName)
+	if isUnavailable(err) {
+		r.logger.Debug().Msgf("rulesets are not available on %s/%s, using the legacy tag protection", owner, repo)
+		return r.legacyTagProtection(opts, t)
+	}
+	if err != nil {
+		return []StepResult{step.Fail(err)}, err
+	}
+
+	if current != nil {
+		step.Before = current
+		desired.ID = current.ID
+	}
+	step.Action = action(current == nil, current != nil && changed(desired, current))
+
+	if step.Action != ActionUnchanged && !opts.Plan {
+		if err := r.CreateUpdateRuleset(owner, repo, desired); err != nil {
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+
+	return []StepResult{step}, nil
+}
+
+// legacyTagProtection creates the missing tag protection patterns and deletes the others
+func (r *RepoTemplate) legacyTagProtection(opts *RepoOptions, t *TagProtection) ([]StepResult, error) {
+	ctx := context.Background()
+	owner, repo := opts.Owner, opts.Name
+
+	desired := t.patterns()
+	step := StepResult{Name: "tag_protection:legacy", After: desired}
+
+	api, err := extension[TagProtectionAPI](r.api)
+	if err != nil {
+		return []StepResult{step.Fail(err)}, err
+	}
+	current, err := r.ListTagProtection(owner, repo)
+	if err != nil {
+		return []StepResult{step.Fail(err)}, err
+	}
+
+	before := []string{}
+	existing := map[string]bool{}
+	for _, p := range current {
+		before = append(before, p.GetPattern())
+		existing[p.GetPattern()] = true
+	}
+	sort.Strings(before)
+	step.Before = before
+	step.Action = action(len(current) == 0, strings.Join(before, ",") != strings.Join(desired, ","))
+
+	if step.Action == ActionUnchanged || opts.Plan {
+		return []StepResult{step}, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, p := range desired {
+		wanted[p] = true
+		if existing[p] {
+			continue
+		}
+
+		r.logger.Debug().Msgf("creating tag protection %s on %s/%s", p, owner, repo)
+		if err := api.CreateTagProtection(ctx, owner, repo, p); err != nil {
+			err = fmt.Errorf("failed to create tag protection %s on %s/%s |→ %w", p, owner, repo, err)
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+	for _, p := range current {
+		if wanted[p.GetPattern()] {
+			continue
+		}
+
+		r.logger.Debug().Msgf("deleting tag protection %s on %s/%s", p.GetPattern(), owner, repo)
+		if err := api.DeleteTagProtection(ctx, owner, repo, p.GetID()); err != nil {
+			err = fmt.Errorf("failed to delete tag protection %s on %s/%s |→ %w", p.GetPattern(), owner, repo, err)
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+
+	return []StepResult{step}, nil
+}
+
+// GetImmutableReleases reports whether the releases of a repository are immutable.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/repos#check-if-immutable-releases-are-enabled-for-a-repository
+func (r *RepoTemplate) GetImmutableReleases(owner, repo string) (bool, error) {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("fetching immutable releases of %s/%s", owner, repo)
+
+	api, err := extension[ReleasesAPI](r.api)
+	if err != nil {
+		return false, err
+	}
+
+	on, err := api.GetImmutableReleases(ctx, owner, repo)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch immutable releases of %s/%s |→ %w", owner, repo, err)
+	}
+
+	return on, nil
+}
+
+// SetImmutableReleases enables or disables the immutable releases of a repository.
+//
+// GitHub API docs: https://docs.github.com/en/rest/repos/repos#enable-immutable-releases
+func (r *RepoTemplate) SetImmutableReleases(owner, repo string, on bool) error {
+	ctx := context.Background()
+
+	r.logger.Debug().Msgf("setting immutable releases to %t on %s/%s", on, owner, repo)
+
+	api, err := extension[ReleasesAPI](r.api)
+	if err != nil {
+		return err
+	}
+
+	if err := api.SetImmutableReleases(ctx, owner, repo, on); err != nil {
+		return fmt.Errorf("failed to set immutable releases on %s/%s |→ %w", owner, repo, err)
+	}
+
+	return nil
+}
+
+// Releases applies the release settings of the template, one step per setting, named releases:<setting>
+func (r *RepoTemplate) Releases(opts *RepoOptions, settings *ReleaseSettings, missing bool) ([]StepResult, error) {
+	if settings.Immutable == nil {
+		return nil, nil
+	}
+
+	step := StepResult{Name: "releases:immutable", After: *settings.Immutable}
+
+	// the releases of a repository that does not exist yet are mutable
+	current := false
+	if !missing || !opts.Plan {
+		var err error
+		if current, err = r.GetImmutableReleases(opts.Owner, opts.Name); err != nil {
+			return []StepResult{step.Fail(err)}, err
+		}
+		step.Before = current
+	}
+	step.Action = action(false, current != *settings.Immutable)
+
+	if step.Action != ActionUnchanged && !opts.Plan {
+		if err := r.SetImmutableReleases(opts.Owner, opts.Name, *settings.Immutable); err != nil {
+			return []StepResult{step.Fail(err)}, err
+		}
+	}
+
+	return []StepResult{step}, nil
+}
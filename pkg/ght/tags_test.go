@@ -0,0 +1,102 @@
+package ght
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagProtectionRuleset(t *testing.T) {
+	srv, rt := newTestServer(t, "ght")
+
+	opts := writeTemplate(t, &Config{
+		TagProtection: &TagProtection{Patterns: []string{"v*", "refs/tags/release-*"}},
+		Releases:      &ReleaseSettings{Immutable: github.Bool(true)},
+	})
+
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "tag_protection:ruleset", res.Steps[1].Name)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, "releases:immutable", res.Steps[2].Name)
+	assert.Equal(t, ActionUpdated, res.Steps[2].Action)
+
+	rs := srv.Ruleset("acme", "ght", TagRulesetName)
+	assert.Equal(t, "tag", rs["target"])
+	assert.Equal(t, "active", rs["enforcement"])
+	assert.Equal(t, map[string]interface{}{"ref_name": map[string]interface{}{
+		"include": []interface{}{"refs/tags/release-*", "refs/tags/v*"},
+		"exclude": []interface{}{},
+	}}, rs["conditions"])
+	// the tags can't be deleted, moved nor updated
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "deletion"},
+		map[string]interface{}{"type": "non_fast_forward"},
+		map[string]interface{}{"type": "update"},
+	}, rs["rules"])
+	assert.True(t, srv.ImmutableReleases("acme", "ght"))
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+
+	// the ruleset is updated in place
+	opts = writeTemplate(t, &Config{TagProtection: &TagProtection{Patterns: []string{"v*"}}})
+	srv.Reset()
+	res, err = Run(rt, opts)
+	writes := srv.Writes()
+	assert.Len(t, writes, 1)
+	assert.True(t, strings.HasPrefix(writes[0], "PUT /repos/acme/ght/rulesets/"), writes[0])
+	assert.Nil(t, err)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	rs = srv.Ruleset("acme", "ght", TagRulesetName)
+	assert.Equal(t, []interface{}{"refs/tags/v*"}, rs["conditions"].(map[string]interface{})["ref_name"].(map[string]interface{})["include"])
+}
+
+func TestTagProtectionLegacy(t *testing.T) {
+	srv, rt := newTestServer(t)
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght"), Private: github.Bool(true)})
+	srv.DisableRulesets("acme", "ght")
+	srv.AddTagProtection("acme", "ght", "old-*")
+
+	opts := writeTemplate(t, &Config{TagProtection: &TagProtection{Patterns: []string{"v*", "release-*"}}})
+
+	srv.Reset()
+	res, err := Run(rt, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "tag_protection:legacy", res.Steps[1].Name)
+	assert.Equal(t, ActionUpdated, res.Steps[1].Action)
+	assert.Equal(t, []string{"old-*"}, res.Steps[1].Before)
+	assert.Equal(t, []string{"release-*", "v*"}, srv.TagProtection("acme", "ght"))
+
+	// the new patterns are protected before the old one is removed
+	writes := srv.Writes()
+	assert.Len(t, writes, 3)
+	assert.Equal(t, []string{`{"pattern":"release-*"}`, `{"pattern":"v*"}`}, srv.Bodies("POST /repos/acme/ght/tags/protection"))
+	assert.True(t, strings.HasPrefix(writes[2], "DELETE /repos/acme/ght/tags/protection/"), writes[2])
+
+	// a second run is a no-op
+	assertNoOp(t, srv, rt, opts)
+}
+
+func TestTagProtectionPlanOnMissingRepo(t *testing.T) {
+	srv, rt := newTestServer(t)
+
+	opts := writeTemplate(t, &Config{
+		Repository:    &github.Repository{Name: github.String("ght")},
+		TagProtection: &TagProtection{Patterns: []string{"v*"}},
+		Releases:      &ReleaseSettings{Immutable: github.Bool(true)},
+	})
+
+	res := plan(t, srv, rt, opts)
+	assert.Len(t, res.Steps, 3)
+	assert.Equal(t, ActionCreated, res.Steps[1].Action)
+	assert.Equal(t, ActionUpdated, res.Steps[2].Action)
+}
+
+func TestTagProtectionValidate(t *testing.T) {
+	assert.NotNil(t, (&TagProtection{}).validate())
+	assert.NotNil(t, (&TagProtection{Patterns: []string{"refs/tags/"}}).validate())
+	assert.Nil(t, (&TagProtection{Patterns: []string{"v*"}}).validate())
+}
@@ -0,0 +1,461 @@
+package ght
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/rs/zerolog"
+)
+
+// WebhookSecretEnv is the env var holding the secret of the webhook
+const WebhookSecretEnv = "GHT_WEBHOOK_SECRET"
+
+// Default settings of the webhook server
+const (
+	DefaultWorkers     = 2
+	DefaultMaxAttempts = 3
+	DefaultRetryDelay  = 10 * time.Second
+	DefaultMaxJobs     = 1000
+
+	// maxPayloadSize is the largest payload GitHub delivers
+	maxPayloadSize = 25 << 20
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobRetrying  = "retrying"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped"
+)
+
+var (
+	// ErrInvalidSignature is returned when the X-Hub-Signature-256 of a delivery is missing or wrong
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrQueueFull is returned when a delivery arrives while MaxJobs jobs are waiting
+	ErrQueueFull = errors.New("too many queued jobs")
+)
+
+// ServeConfig selects the template applied to the repositories created or transferred
+type ServeConfig struct {
+	Rules []ServeRule `json:"rules"`
+}
+
+// ServeRule matches a repository when all its criteria match, empty criteria match any repository
+type ServeRule struct {
+	// NamePrefix matches the beginning of the repository name
+	NamePrefix string `json:"name_prefix,omitempty"`
+	// Topic matches one of the repository topics
+	Topic string `json:"topic,omitempty"`
+	// Team matches the repositories created by a member of the organization team, by its slug
+	Team string `json:"team,omitempty"`
+
+	Template string   `json:"template"`
+	Branches []string `json:"branches,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+	// Vars are the values of the ${name} variables of the template
+	Vars map[string]string `json:"vars,omitempty"`
+}
+
+// ReadServeConfig reads the webhook server config from disk
+func ReadServeConfig(path string) (*ServeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read serve config %s |→ %w", path, err)
+	}
+
+	cfg := &ServeConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal serve config %s |→ %w", path, err)
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.Template == "" {
+			return nil, fmt.Errorf("rule %d of serve config %s has no template", i, path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Job is the application of a template triggered by a webhook delivery
+type Job struct {
+	ID         string        `json:"id"`
+	Event      string        `json:"event"`
+	Repository string        `json:"repository"`
+	Template   string        `json:"template,omitempty"`
+	Status     string        `json:"status"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error,omitempty"`
+	Result     *RepoResponse `json:"result,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+
+	owner, name, sender, org string
+	topics                   []string
+}
+
+// WebhookServer applies the templates to the repositories reported by the
+// GitHub repository webhook, created or transferred, in the background.
+//
+// It serves the webhook at POST /webhook, the jobs at GET /status and a
+// single job at GET /status/{id}. The status endpoints expose the settings of
+// the repositories, they require the webhook secret as a bearer token.
+type WebhookServer struct {
+	// Workers is the number of jobs run at the same time
+	Workers int
+	// MaxAttempts is the number of times a failed job is run
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry, doubled on each retry
+	RetryDelay time.Duration
+	// MaxJobs is the number of jobs kept for the status endpoint
+	MaxJobs int
+
+	rt     *RepoTemplate
+	cfg    *ServeConfig
+	secret []byte
+	opts   RepoOptions
+	logger zerolog.Logger
+
+	queue chan *Job
+	wg    sync.WaitGroup
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+	seq   int
+}
+
+// NewWebhookServer creates a webhook server, the base options apply to every run
+func NewWebhookServer(rt *RepoTemplate, cfg *ServeConfig, secret []byte, base *RepoOptions) *WebhookServer {
+	return &WebhookServer{
+		Workers:     DefaultWorkers,
+		MaxAttempts: DefaultMaxAttempts,
+		RetryDelay:  DefaultRetryDelay,
+		MaxJobs:     DefaultMaxJobs,
+		rt:          rt,
+		cfg:         cfg,
+		secret:      secret,
+		opts:        *base,
+		logger:      rt.logger,
+		jobs:        map[string]*Job{},
+	}
+}
+
+// Start starts the workers
+func (s *WebhookServer) Start() {
+	s.queue = make(chan *Job, s.MaxJobs)
+	for i := 0; i < s.Workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for job := range s.queue {
+				s.process(job)
+			}
+		}()
+	}
+}
+
+// Stop waits for the queued jobs to finish, no delivery must be served after it is called
+func (s *WebhookServer) Stop() {
+	close(s.queue)
+	s.wg.Wait()
+}
+
+// Job returns a copy of the job, false when it is unknown
+func (s *WebhookServer) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+
+	return *job, true
+}
+
+// Jobs returns a copy of the jobs, the oldest first
+func (s *WebhookServer) Jobs() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, *s.jobs[id])
+	}
+
+	return jobs
+}
+
+func (s *WebhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/webhook" && r.Method == http.MethodPost:
+		s.webhook(w, r)
+	case (r.URL.Path == "/status" || strings.HasPrefix(r.URL.Path, "/status/")) && !s.authorized(r):
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "the webhook secret is required as a bearer token"})
+	case r.URL.Path == "/status" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string][]Job{"jobs": s.Jobs()})
+	case strings.HasPrefix(r.URL.Path, "/status/") && r.Method == http.MethodGet:
+		job, ok := s.Job(strings.TrimPrefix(r.URL.Path, "/status/"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "job not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	}
+}
+
+// authorized reports whether the request carries the webhook secret as a bearer token
+func (s *WebhookServer) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return len(s.secret) > 0 && subtle.ConstantTimeCompare([]byte(token), s.secret) == 1
+}
+
+// webhook verifies and queues a delivery
+func (s *WebhookServer) webhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	if err := VerifySignature(r.Header.Get("X-Hub-Signature-256"), payload, s.secret); err != nil {
+		s.logger.Warn().Err(err).Msgf("rejected delivery %s", github.DeliveryID(r))
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": err.Error()})
+		return
+	}
+
+	eventType := github.WebHookType(r)
+	if eventType == "ping" {
+		writeJSON(w, http.StatusOK, map[string]string{"message": "pong"})
+		return
+	}
+	if eventType != "repository" {
+		writeJSON(w, http.StatusAccepted, map[string]string{"message": "event ignored"})
+		return
+	}
+
+	event := &github.RepositoryEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	action := event.GetAction()
+	if action != "created" && action != "transferred" {
+		writeJSON(w, http.StatusAccepted, map[string]string{"message": "action ignored"})
+		return
+	}
+
+	job, queued, err := s.enqueue(github.DeliveryID(r), event)
+	if err != nil {
+		s.logger.Warn().Err(err).Msgf("rejected delivery %s", github.DeliveryID(r))
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": err.Error()})
+		return
+	}
+	if !queued {
+		writeJSON(w, http.StatusOK, job)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// enqueue queues a job for the event, a delivery already received is not
+// queued again. It fails with ErrQueueFull rather than wait for the workers,
+// GitHub gives up on a delivery not answered within 10 seconds.
+func (s *WebhookServer) enqueue(delivery string, event *github.RepositoryEvent) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[delivery]; ok && delivery != "" {
+		return *job, false, nil
+	}
+
+	s.seq++
+	id := delivery
+	if id == "" {
+		id = strconv.Itoa(s.seq)
+	}
+
+	now := time.Now().UTC()
+	repo := event.GetRepo()
+	job := &Job{
+		ID:         id,
+		Event:      "repository." + event.GetAction(),
+		Repository: repo.GetFullName(),
+		Status:     JobQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		owner:      repo.GetOwner().GetLogin(),
+		name:       repo.GetName(),
+		sender:     event.GetSender().GetLogin(),
+		org:        event.GetOrg().GetLogin(),
+		topics:     repo.Topics,
+	}
+
+	select {
+	case s.queue <- job:
+	default:
+		return Job{}, false, ErrQueueFull
+	}
+
+	s.jobs[id] = job
+	s.order = append(s.order, id)
+
+	// forget the oldest finished jobs
+	for len(s.order) > s.MaxJobs {
+		oldest := s.jobs[s.order[0]]
+		if oldest.Status == JobQueued || oldest.Status == JobRunning || oldest.Status == JobRetrying {
+			break
+		}
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return *job, true, nil
+}
+
+// process selects the template of the job and runs it, retrying on the
+// transient failures such as the server errors and rate limits of GitHub
+func (s *WebhookServer) process(job *Job) {
+	for attempt := 1; attempt <= s.MaxAttempts; attempt++ {
+		s.update(job, func(j *Job) {
+			j.Status = JobRunning
+			j.Attempts = attempt
+		})
+
+		rule, err := s.match(job)
+		if err == nil && rule == nil {
+			s.update(job, func(j *Job) { j.Status = JobSkipped })
+			s.logger.Info().Msgf("no rule matches %s", job.Repository)
+			return
+		}
+
+		var res *RepoResponse
+		if err == nil {
+			s.update(job, func(j *Job) { j.Template = rule.Template })
+
+			opts := s.opts
+			opts.Owner = job.owner
+			opts.Name = job.name
+			opts.Template = rule.Template
+			opts.Branches = rule.Branches
+			opts.Topics = rule.Topics
+			opts.Vars = rule.Vars
+
+			res, err = Run(s.rt, &opts)
+		}
+
+		if err == nil {
+			s.update(job, func(j *Job) {
+				j.Status = JobSucceeded
+				j.Error = ""
+				j.Result = res
+			})
+			s.logger.Info().Msgf("template %s applied to %s", rule.Template, job.Repository)
+			return
+		}
+
+		s.logger.Warn().Err(err).Msgf("attempt %d of %d failed for %s", attempt, s.MaxAttempts, job.Repository)
+		if attempt == s.MaxAttempts || !IsTransient(err) {
+			s.update(job, func(j *Job) {
+				j.Status = JobFailed
+				j.Error = err.Error()
+				j.Result = res
+			})
+			return
+		}
+
+		s.update(job, func(j *Job) {
+			j.Status = JobRetrying
+			j.Error = err.Error()
+			j.Result = res
+		})
+		time.Sleep(s.RetryDelay << (attempt - 1))
+	}
+}
+
+// update changes the job under the lock
+func (s *WebhookServer) update(job *Job, fn func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(job)
+	job.UpdatedAt = time.Now().UTC()
+}
+
+// match returns the first rule matching the repository of the job, nil when none matches
+func (s *WebhookServer) match(job *Job) (*ServeRule, error) {
+	for i := range s.cfg.Rules {
+		rule := &s.cfg.Rules[i]
+
+		if rule.NamePrefix != "" && !strings.HasPrefix(job.name, rule.NamePrefix) {
+			continue
+		}
+		if rule.Topic != "" && !contains(job.topics, rule.Topic) {
+			continue
+		}
+		if rule.Team != "" {
+			if job.org == "" || job.sender == "" {
+				continue
+			}
+			member, err := s.rt.IsTeamMember(job.org, rule.Team, job.sender)
+			if err != nil {
+				return nil, err
+			}
+			if !member {
+				continue
+			}
+		}
+
+		return rule, nil
+	}
+
+	return nil, nil
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a delivery
+func VerifySignature(signature string, payload, secret []byte) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("%w: X-Hub-Signature-256 is missing", ErrInvalidSignature)
+	}
+
+	if err := github.ValidateSignature(signature, payload, secret); err != nil {
+		return fmt.Errorf("%w |→ %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+// contains reports whether the value is in the list
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
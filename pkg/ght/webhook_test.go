@@ -0,0 +1,243 @@
+package ght
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/leocomelli/ght/pkg/ght/ghtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var webhookSecret = []byte("webhook-secret")
+
+// delivery posts a repository event signed with the secret
+func delivery(t *testing.T, h http.Handler, id, event string, payload interface{}, secret []byte) *httptest.ResponseRecorder {
+	body, err := json.Marshal(payload)
+	assert.Nil(t, err)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", id)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func repositoryEvent(action, name, sender string, topics ...string) *github.RepositoryEvent {
+	return &github.RepositoryEvent{
+		Action: github.String(action),
+		Repo: &github.Repository{
+			Name:     github.String(name),
+			FullName: github.String("acme/" + name),
+			Owner:    &github.User{Login: github.String("acme")},
+			Topics:   topics,
+		},
+		Org:    &github.Organization{Login: github.String("acme")},
+		Sender: &github.User{Login: github.String(sender)},
+	}
+}
+
+func newWebhookServer(t *testing.T, srv *ghtest.Server, rules ...ServeRule) *WebhookServer {
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	wh := NewWebhookServer(rt, &ServeConfig{Rules: rules}, webhookSecret, &RepoOptions{})
+	wh.RetryDelay = time.Millisecond
+	wh.Start()
+
+	return wh
+}
+
+func TestWebhookRejectsInvalidSignature(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+
+	wh := newWebhookServer(t, srv)
+	defer wh.Stop()
+
+	w := delivery(t, wh, "1", "repository", repositoryEvent("created", "ght", "octocat"), []byte("other"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{}`)))
+	w = httptest.NewRecorder()
+	wh.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Empty(t, wh.Jobs())
+	assert.True(t, errors.Is(VerifySignature("", nil, webhookSecret), ErrInvalidSignature))
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+
+	wh := newWebhookServer(t, srv)
+	defer wh.Stop()
+
+	w := delivery(t, wh, "1", "ping", map[string]string{"zen": "Keep it logically awesome."}, webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = delivery(t, wh, "2", "push", map[string]string{"ref": "refs/heads/main"}, webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = delivery(t, wh, "3", "repository", repositoryEvent("deleted", "ght", "octocat"), webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	assert.Empty(t, wh.Jobs())
+}
+
+func TestWebhookAppliesMatchingTemplate(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddTeamMember("acme", "platform", "octocat")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("svc-billing")})
+	srv.AddRepo("acme", &github.Repository{Name: github.String("lib-tagged")})
+	srv.AddRepo("acme", &github.Repository{Name: github.String("tool")})
+	srv.AddRepo("acme", &github.Repository{Name: github.String("other")})
+
+	wh := newWebhookServer(t, srv,
+		ServeRule{NamePrefix: "svc-", Template: "./testdata/full-repo.json", Branches: []string{"main"}},
+		ServeRule{Topic: "library", Template: "./testdata/full-repo.json"},
+		ServeRule{Team: "platform", Template: "./testdata/full-repo.json"},
+	)
+
+	events := map[string]*github.RepositoryEvent{
+		"1": repositoryEvent("created", "svc-billing", "mona"),
+		"2": repositoryEvent("created", "lib-tagged", "mona", "library"),
+		"3": repositoryEvent("transferred", "tool", "octocat"),
+		"4": repositoryEvent("created", "other", "mona"),
+	}
+	for id, event := range events {
+		w := delivery(t, wh, id, "repository", event, webhookSecret)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	// a redelivery is not queued again
+	w := delivery(t, wh, "1", "repository", events["1"], webhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	wh.Stop()
+
+	for _, id := range []string{"1", "2", "3"} {
+		job, ok := wh.Job(id)
+		assert.True(t, ok)
+		assert.Equal(t, JobSucceeded, job.Status, job.Repository)
+		assert.Equal(t, 1, job.Attempts)
+		assert.NotNil(t, job.Result)
+	}
+
+	job, _ := wh.Job("4")
+	assert.Equal(t, JobSkipped, job.Status)
+
+	assert.NotNil(t, srv.Protection("acme", "svc-billing", "main"))
+	_, ok := srv.Content("acme", "tool", PullRequestTemplate)
+	assert.True(t, ok)
+	_, ok = srv.Content("acme", "other", PullRequestTemplate)
+	assert.False(t, ok)
+	assert.Len(t, wh.Jobs(), 4)
+}
+
+func TestWebhookRetriesTransientFailures(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+	srv.AddRepo("acme", &github.Repository{Name: github.String("ght")})
+	srv.Fail("GET /repos/acme/ght", http.StatusBadGateway)
+
+	wh := newWebhookServer(t, srv, ServeRule{Template: "./testdata/full-repo.json"})
+	wh.MaxAttempts = 2
+
+	w := delivery(t, wh, "abc", "repository", repositoryEvent("created", "ght", "octocat"), webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	wh.Stop()
+
+	job, _ := wh.Job("abc")
+	assert.Equal(t, JobSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+}
+
+func TestWebhookReportsStatus(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+	srv.AddOrg("acme")
+
+	wh := newWebhookServer(t, srv, ServeRule{Template: "./testdata/missing.json"})
+	wh.MaxAttempts = 2
+
+	w := delivery(t, wh, "abc", "repository", repositoryEvent("created", "ght", "octocat"), webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	wh.Stop()
+
+	status := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		wh.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, status("/status", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, status("/status/abc", "wrong").Code)
+
+	w = status("/status/abc", string(webhookSecret))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// a missing template is not retried
+	job := Job{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Contains(t, job.Error, "missing.json")
+
+	w = status("/status", string(webhookSecret))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"abc"`)
+
+	assert.Equal(t, http.StatusNotFound, status("/status/unknown", string(webhookSecret)).Code)
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	srv := ghtest.NewServer()
+	defer srv.Close()
+
+	rt, err := NewRepoTemplate(WithClient(srv.GitHubClient()))
+	assert.Nil(t, err)
+
+	// no worker takes the jobs out of the queue
+	wh := NewWebhookServer(rt, &ServeConfig{}, webhookSecret, &RepoOptions{})
+	wh.Workers = 0
+	wh.MaxJobs = 1
+	wh.Start()
+	defer wh.Stop()
+
+	w := delivery(t, wh, "1", "repository", repositoryEvent("created", "ght", "octocat"), webhookSecret)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w = delivery(t, wh, "2", "repository", repositoryEvent("created", "other", "octocat"), webhookSecret)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// GitHub redelivers the rejected delivery later
+	_, ok := wh.Job("2")
+	assert.False(t, ok)
+}